@@ -0,0 +1,103 @@
+// A reference auth server that checks the calls by a policy file, so the whole system
+// could be run locally and in integration tests without an external auth service.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	l "github.com/q-sharafian/file-transfer/pkg/logger"
+	pbAuth "github.com/q-sharafian/file-transfer/pkg/pb/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "Address that the server listens on")
+	policyFile := flag.String("policy", "policy.yaml", "YAML or JSON file of the users and their permissions")
+	certFile := flag.String("cert", "", "PEM file of the server certificate. If it's set, TLS is enabled.")
+	keyFile := flag.String("key", "", "PEM file of the server key")
+	clientCAFile := flag.String("client-ca", "", "PEM file of the CAs that client certificates must be signed by them (mutual TLS)")
+	bearerToken := flag.String("bearer-token", "", "If it's set, calls must have it as their bearer token")
+	debug := flag.Bool("debug", false, "Log the decisions")
+	flag.Parse()
+
+	logger := l.NewSLogger(l.Info, nil, os.Stdout)
+	if *debug {
+		logger.ChangeLogLevel(l.Debug)
+	}
+	users, err := loadPolicy(*policyFile)
+	if err != nil {
+		logger.Fatalf("Failed to load policy file %s: %s", *policyFile, err.Error())
+	}
+
+	var options []grpc.ServerOption
+	if *certFile != "" {
+		tlsConfig, err := serverTLSConfig(*certFile, *keyFile, *clientCAFile)
+		if err != nil {
+			logger.Fatalf("Invalid TLS settings: %s", err.Error())
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if *bearerToken != "" {
+		options = append(options, grpc.UnaryInterceptor(bearerInterceptor(*bearerToken)))
+	}
+	server := grpc.NewServer(options...)
+	pbAuth.RegisterAuthServer(server, &authServer{users: users, logger: logger})
+	// The clients could send the calls just to the serving servers.
+	healthpb.RegisterHealthServer(server, health.NewServer())
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		logger.Fatalf("Failed to listen on %s: %s", *addr, err.Error())
+	}
+	logger.Infof("Auth server is listening on %s with %d tokens of policy %s", listener.Addr(), len(users), *policyFile)
+	if err := server.Serve(listener); err != nil {
+		logger.Fatalf("Auth server failed: %s", err.Error())
+	}
+}
+
+func serverTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA file %s doesn't contain any certificate", clientCAFile)
+		}
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// Reject the calls that don't have the bearer token in their "authorization" metadata
+func bearerInterceptor(bearerToken string) grpc.UnaryServerInterceptor {
+	expected := []byte("Bearer " + bearerToken)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) != 1 || subtle.ConstantTimeCompare([]byte(values[0]), expected) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+		}
+		return handler(ctx, req)
+	}
+}
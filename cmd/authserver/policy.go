@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"gopkg.in/yaml.v3"
+)
+
+// Placeholder of the patterns that is replaced by ID of the user
+const userPlaceholder = "{user}"
+
+// Users and their permissions. The policy could be written in YAML or JSON.
+// Keys of the files are matched with the patterns like path.Match, but a pattern that
+// ends with "/**" matches all the files under its directory.
+type policy struct {
+	Users []policyUser `yaml:"users"`
+}
+
+type policyUser struct {
+	// A stable ID of the user. It couldn't contain any slash or backslash.
+	ID string `yaml:"id"`
+	// Auth tokens of the user
+	Tokens []string `yaml:"tokens"`
+	// Calls with tokens of disabled users are forbidden.
+	Disabled bool `yaml:"disabled"`
+	// A map from file types that the user could upload to their maximum size in Kbytes.
+	// Zero means there's no limit and "*" means any other type.
+	Upload map[string]uint64 `yaml:"upload"`
+	// Files of the user. The user could download, delete, copy and move them, and copy or
+	// move other files to them. (e.g. "{user}/**" if OBJECT_KEY_TEMPLATE of the app is
+	// "{user}/{uuid}.{ext}")
+	Owns []string `yaml:"owns"`
+	// Other files that the user could download, delete, copy or move
+	Download []string `yaml:"download"`
+	Delete   []string `yaml:"delete"`
+	Copy     []string `yaml:"copy"`
+	Move     []string `yaml:"move"`
+}
+
+// Read the policy file and check it. Users are returned by their tokens.
+func loadPolicy(fileName string) (map[string]*policyUser, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var p policy
+	// JSON is valid YAML, so both formats are decoded the same. Unknown fields are
+	// rejected, so misspelled permissions aren't ignored.
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to decode the policy: %w", err)
+	}
+
+	users := make(map[string]*policyUser)
+	ids := make(map[string]bool)
+	for i := range p.Users {
+		user := &p.Users[i]
+		if user.ID == "" || user.ID == "." || user.ID == ".." || strings.ContainsAny(user.ID, "/\\") {
+			return nil, fmt.Errorf("invalid user id %q", user.ID)
+		}
+		if ids[user.ID] {
+			return nil, fmt.Errorf("user %s is repeated", user.ID)
+		}
+		ids[user.ID] = true
+		for _, t := range user.Tokens {
+			if t == "" {
+				return nil, fmt.Errorf("user %s has an empty token", user.ID)
+			}
+			if other, ok := users[t]; ok {
+				return nil, fmt.Errorf("users %s and %s have the same token", other.ID, user.ID)
+			}
+			users[t] = user
+		}
+		user.Owns = user.patterns(user.Owns)
+		user.Download = user.patterns(user.Download)
+		user.Delete = user.patterns(user.Delete)
+		user.Copy = user.patterns(user.Copy)
+		user.Move = user.patterns(user.Move)
+	}
+	return users, nil
+}
+
+// Replace the placeholder of the patterns by ID of the user
+func (u *policyUser) patterns(patterns []string) []string {
+	replaced := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		replaced = append(replaced, strings.ReplaceAll(pattern, userPlaceholder, u.ID))
+	}
+	return replaced
+}
+
+func (u *policyUser) owns(key string) bool {
+	return file.MatchKey(u.Owns, key)
+}
+
+// Whether the user could access the file by the patterns of an action
+func (u *policyUser) canAccess(patterns []string, key string) bool {
+	return u.owns(key) || file.MatchKey(patterns, key)
+}
+
+// The user could transfer the source by the patterns, and the destination must be
+// owned by the user or match the patterns too.
+func (u *policyUser) canTransfer(patterns []string, source, destination string) bool {
+	return u.canAccess(patterns, source) && u.canAccess(patterns, destination)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+
+	l "github.com/q-sharafian/file-transfer/pkg/logger"
+	pbAuth "github.com/q-sharafian/file-transfer/pkg/pb/auth"
+)
+
+// An auth server that checks the calls by the users of the policy
+type authServer struct {
+	pbAuth.UnimplementedAuthServer
+	// Users of the policy by their tokens
+	users  map[string]*policyUser
+	logger l.Logger
+}
+
+// Return the user of the auth token. If it couldn't, status code and message of the
+// error are returned.
+func (s *authServer) user(authToken string) (*policyUser, pbAuth.StatusCode, string) {
+	user, ok := s.users[authToken]
+	if !ok {
+		return nil, pbAuth.StatusCode_ErrUnauthorized, "There's not any user with this auth token"
+	}
+	if user.Disabled {
+		return nil, pbAuth.StatusCode_ErrForbidden, "The user is disabled"
+	}
+	return user, pbAuth.StatusCode_OK, ""
+}
+
+func (s *authServer) IsAllowedDownload(_ context.Context, req *pbAuth.DownloadAccessReq) (*pbAuth.AllowDownloadResult, error) {
+	user, code, errmsg := s.user(req.AuthToken)
+	if user == nil {
+		return &pbAuth.AllowDownloadResult{StatusCode: code, Errmsg: errmsg}, nil
+	}
+	files := make(map[string]bool, len(req.ObjectTokens))
+	for _, key := range req.ObjectTokens {
+		files[key] = user.canAccess(user.Download, key)
+	}
+	s.logger.Debugf("Download access of user %s: %v", user.ID, files)
+	return &pbAuth.AllowDownloadResult{StatusCode: code, Files: files}, nil
+}
+
+func (s *authServer) IsAllowedUpload(_ context.Context, req *pbAuth.UploadAccessReq) (*pbAuth.AllowUploadResult, error) {
+	user, code, errmsg := s.user(req.AuthToken)
+	if user == nil {
+		return &pbAuth.AllowUploadResult{StatusCode: code, Errmsg: errmsg}, nil
+	}
+	fileTypes := make([]*pbAuth.AcceptableType, 0, len(req.ObjectTypes))
+	for fileType := range req.ObjectTypes {
+		maxSize, isAllow := user.Upload[fileType]
+		if !isAllow {
+			maxSize, isAllow = user.Upload["*"]
+		}
+		fileTypes = append(fileTypes, &pbAuth.AcceptableType{FileType: fileType, IsAllow: isAllow, MaxSize: maxSize})
+	}
+	s.logger.Debugf("Upload access of user %s: %v", user.ID, fileTypes)
+	return &pbAuth.AllowUploadResult{StatusCode: code, FileTypes: fileTypes}, nil
+}
+
+func (s *authServer) IsAllowedDelete(_ context.Context, req *pbAuth.DeleteAccessReq) (*pbAuth.AllowDeleteResult, error) {
+	user, code, errmsg := s.user(req.AuthToken)
+	if user == nil {
+		return &pbAuth.AllowDeleteResult{StatusCode: code, Errmsg: errmsg}, nil
+	}
+	files := make(map[string]bool, len(req.ObjectTokens))
+	for _, key := range req.ObjectTokens {
+		files[key] = user.canAccess(user.Delete, key)
+	}
+	s.logger.Debugf("Delete access of user %s: %v", user.ID, files)
+	return &pbAuth.AllowDeleteResult{StatusCode: code, Files: files}, nil
+}
+
+func (s *authServer) IsAllowedCopy(_ context.Context, req *pbAuth.TransferAccessReq) (*pbAuth.AllowTransferResult, error) {
+	return s.isAllowedTransfer("Copy", func(u *policyUser) []string { return u.Copy }, req), nil
+}
+
+func (s *authServer) IsAllowedMove(_ context.Context, req *pbAuth.TransferAccessReq) (*pbAuth.AllowTransferResult, error) {
+	return s.isAllowedTransfer("Move", func(u *policyUser) []string { return u.Move }, req), nil
+}
+
+func (s *authServer) isAllowedTransfer(action string, patterns func(*policyUser) []string,
+	req *pbAuth.TransferAccessReq) *pbAuth.AllowTransferResult {
+	user, code, errmsg := s.user(req.AuthToken)
+	if user == nil {
+		return &pbAuth.AllowTransferResult{StatusCode: code, Errmsg: errmsg}
+	}
+	allowed := make([]bool, 0, len(req.Objects))
+	for _, object := range req.Objects {
+		allowed = append(allowed, user.canTransfer(patterns(user), object.Source, object.Destination))
+	}
+	s.logger.Debugf("%s access of user %s: %v", action, user.ID, allowed)
+	return &pbAuth.AllowTransferResult{StatusCode: code, Allowed: allowed}
+}
+
+func (s *authServer) Identify(_ context.Context, req *pbAuth.IdentifyReq) (*pbAuth.IdentifyResult, error) {
+	user, code, errmsg := s.user(req.AuthToken)
+	if user == nil {
+		return &pbAuth.IdentifyResult{StatusCode: code, Errmsg: errmsg}, nil
+	}
+	return &pbAuth.IdentifyResult{StatusCode: code, UserID: user.ID}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -12,6 +13,7 @@ import (
 	"github.com/q-sharafian/file-transfer/internal/reqhandler"
 	"github.com/q-sharafian/file-transfer/internal/server"
 	"github.com/q-sharafian/file-transfer/internal/storage"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
 	l "github.com/q-sharafian/file-transfer/pkg/logger"
 )
 
@@ -38,17 +40,101 @@ func main() {
 		}
 	}
 
-	maxQueryTime, err := strconv.Atoi(os.Getenv("AUTH_QUERY_MAX_TIME"))
-	if err != nil {
-		logger.Panicf("Failed to parse AUTH_QUERY_MAX_TIME: %s", err.Error())
+	// Stacks of the errors are logged with them
+	e.CaptureStacks(os.Getenv("ERROR_STACKS") == "true")
+
+	var authService auth.Auth
+	switch os.Getenv("AUTH_TYPE") {
+	case "grpc", "":
+		maxQueryTime, err := strconv.Atoi(os.Getenv("AUTH_QUERY_MAX_TIME"))
+		if err != nil {
+			logger.Panicf("Failed to parse AUTH_QUERY_MAX_TIME: %s", err.Error())
+		}
+		serverAddrs := strings.Split(os.Getenv("AUTH_SERVER_ADDR"), ",")
+		authService = auth.NewSimpleAuth(serverAddrs, time.Duration(maxQueryTime)*time.Second, auth.ConnSecurity{
+			TLS:         os.Getenv("AUTH_SERVER_TLS") == "true",
+			CAFile:      os.Getenv("AUTH_SERVER_CA_FILE"),
+			CertFile:    os.Getenv("AUTH_SERVER_CERT_FILE"),
+			KeyFile:     os.Getenv("AUTH_SERVER_KEY_FILE"),
+			ServerName:  os.Getenv("AUTH_SERVER_NAME"),
+			BearerToken: os.Getenv("AUTH_SERVER_BEARER_TOKEN"),
+		}, auth.ConnResilience{
+			MaxAttempts:      envInt(logger, "AUTH_QUERY_MAX_ATTEMPTS", 3),
+			AttemptTimeout:   time.Duration(envInt(logger, "AUTH_QUERY_ATTEMPT_TIME", 0)) * time.Millisecond,
+			InitialBackoff:   time.Duration(envInt(logger, "AUTH_RETRY_BACKOFF", 50)) * time.Millisecond,
+			MaxBackoff:       time.Duration(envInt(logger, "AUTH_RETRY_MAX_BACKOFF", 1000)) * time.Millisecond,
+			BreakerThreshold: envInt(logger, "AUTH_BREAKER_THRESHOLD", 0),
+			BreakerCooldown:  time.Duration(envInt(logger, "AUTH_BREAKER_COOLDOWN", 10)) * time.Second,
+			HealthCheck:      os.Getenv("AUTH_SERVER_HEALTH_CHECK") == "true",
+		}, logger)
+	case "jwt":
+		var secret []byte
+		if s := os.Getenv("JWT_SECRET"); s != "" {
+			secret = []byte(s)
+		}
+		authService = auth.NewJWTAuth(auth.JWTConfig{
+			Algorithms:    envList("JWT_ALGORITHMS"),
+			Secret:        secret,
+			PublicKeyFile: os.Getenv("JWT_PUBLIC_KEY_FILE"),
+			JWKSFile:      os.Getenv("JWT_JWKS_FILE"),
+			Issuer:        os.Getenv("JWT_ISSUER"),
+			Audience:      os.Getenv("JWT_AUDIENCE"),
+		}, logger)
+	default:
+		logger.Panicf("Unknown AUTH_TYPE %s", os.Getenv("AUTH_TYPE"))
 	}
-	authService := auth.NewSimpleAuth(os.Getenv("AUTH_SERVER_ADDR"), time.Duration(maxQueryTime)*time.Second, logger)
 	// authService := auth.NewDummyAuth()
-	storageService := storage.NewS3Storage(logger)
+	if cacheSize := envInt(logger, "AUTH_CACHE_SIZE", 0); cacheSize > 0 {
+		allowTTL := envInt(logger, "AUTH_CACHE_ALLOW_TTL", 30)
+		denyTTL := envInt(logger, "AUTH_CACHE_DENY_TTL", 5)
+		authService = auth.NewCachedAuth(authService, time.Duration(allowTTL)*time.Second,
+			time.Duration(denyTTL)*time.Second, cacheSize)
+	}
 	server := server.NewSimpleServer(logger)
+	var storageService storage.Storage
+	switch os.Getenv("STORAGE_TYPE") {
+	case "local":
+		storageService = storage.NewLocalStorage(server, logger)
+	case "s3", "":
+		storageService = storage.NewS3Storage(logger)
+	default:
+		logger.Panicf("Unknown STORAGE_TYPE %s", os.Getenv("STORAGE_TYPE"))
+	}
 	requestHandler := reqhandler.NewSimpleReqHandler(authService, storageService, logger)
 	endpoints.InitEndpoints(server, logger, requestHandler)
 
 	// Keep the main function running
 	select {}
 }
+
+// Return value of the environment variable as a non-negative integer. If it's not set,
+// def is returned. All of these settings are sizes, times or counts, so a negative value
+// is a mistake.
+func envInt(logger l.Logger, name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Panicf("Failed to parse %s: %s", name, err.Error())
+	}
+	if n < 0 {
+		logger.Panicf("%s can't be negative: %d", name, n)
+	}
+	return n
+}
+
+// Return the comma-separated values of the environment variable. If it's not set, the
+// list is empty instead of having one empty value.
+func envList(name string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	list := strings.Split(value, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list
+}
@@ -4,6 +4,8 @@ Responsible for authentication and authorization
 package auth
 
 import (
+	"strings"
+
 	"github.com/q-sharafian/file-transfer/internal/common/file"
 	"github.com/q-sharafian/file-transfer/internal/common/token"
 	e "github.com/q-sharafian/file-transfer/pkg/error"
@@ -24,6 +26,26 @@ type DownloadAccessReq struct {
 	ObjectTokens []token.Token
 }
 
+type DeleteAccessReq struct {
+	// authentication token. It maybe jwt or something that is agreed upon between two parties.
+	AuthToken token.Token
+	// list of tokens that each represents a file
+	ObjectTokens []token.Token
+}
+
+// Copying or moving a file to another token
+type ObjectTransfer struct {
+	Source      token.Token
+	Destination token.Token
+}
+
+type TransferAccessReq struct {
+	// authentication token. It maybe jwt or something that is agreed upon between two parties.
+	AuthToken token.Token
+	// Files that are going to be copied/moved
+	Objects []ObjectTransfer
+}
+
 type allowType struct {
 	FileType file.FileExtension
 	IsAllow  bool
@@ -34,16 +56,26 @@ type allowType struct {
 // Specified which files are allowed to be downloaded
 type allowDownload map[token.Token]bool
 
-type errTypes int
+// Specified which files are allowed to be deleted
+type allowDelete map[token.Token]bool
+
+// Specified which transfers are allowed, in the same order as the request
+type allowTransfer []bool
 
+// Codes of the auth errors. They're codes of pkg/error, so the clients get the same
+// HTTP status for them in all endpoints.
 const (
 	// An internal error could be database error, network error, etc
-	ErrInternal errTypes = iota
+	ErrInternal = e.Internal
 	// There's not any matched user with this auth token
-	ErrUnauthorized
+	ErrUnauthorized = e.Unauthorized
 	// User with this token exists but can't upload/download any object.
 	// (e.g., it has not download/upload permission or it's disabled)
-	ErrForbidden
+	ErrForbidden = e.Forbidden
+	// The auth service is down or didn't respond in time. The query could be retried later.
+	ErrUnavailable = e.Unavailable
+	// The auth service doesn't support the query. (e.g. auth servers without Identify)
+	ErrNotImplemented = e.NotImplemented
 )
 
 type Auth interface {
@@ -51,7 +83,7 @@ type Auth interface {
 	// client that has 'AuthToken'.
 	//
 	// Possible error codes:
-	// ErrInternal- ErrForbidden- ErrUnauthorized
+	// ErrInternal- ErrUnavailable- ErrForbidden- ErrUnauthorized
 	IsAllowedDownload(accessInfo DownloadAccessReq) (allowDownload, *e.Error)
 
 	// Check if the file type specified in the input is allowed to be uploaded and what
@@ -59,6 +91,39 @@ type Auth interface {
 	// are only usesable for the client with 'AuthToken' not anyone else.
 	//
 	// Possible error codes:
-	// ErrInternal- ErrForbidden- ErrUnauthorized
+	// ErrInternal- ErrUnavailable- ErrForbidden- ErrUnauthorized
 	IsAllowedUpload(accessInfo UploadAccessReq) ([]allowType, *e.Error)
+
+	// Check if each file specified in the input is allowed to be deleted by specified
+	// client that has 'AuthToken'.
+	//
+	// Possible error codes:
+	// ErrInternal- ErrUnavailable- ErrForbidden- ErrUnauthorized
+	IsAllowedDelete(accessInfo DeleteAccessReq) (allowDelete, *e.Error)
+
+	// Check if each file specified in the input is allowed to be copied to its destination
+	// by specified client that has 'AuthToken'.
+	//
+	// Possible error codes:
+	// ErrInternal- ErrUnavailable- ErrForbidden- ErrUnauthorized
+	IsAllowedCopy(accessInfo TransferAccessReq) (allowTransfer, *e.Error)
+
+	// Same as IsAllowedCopy, but the source files are removed after copying them.
+	//
+	// Possible error codes:
+	// ErrInternal- ErrUnavailable- ErrForbidden- ErrUnauthorized
+	IsAllowedMove(accessInfo TransferAccessReq) (allowTransfer, *e.Error)
+
+	// Return ID of the user that owns the auth token. The ID is stable and couldn't
+	// contain any slash or backslash, so files of each user are stored under it.
+	//
+	// Possible error codes:
+	// ErrInternal- ErrUnavailable- ErrForbidden- ErrUnauthorized- ErrNotImplemented
+	Identify(authToken token.Token) (string, *e.Error)
+}
+
+// Files of each user are stored under its ID, so it couldn't be a relative path or
+// contain any slash or backslash.
+func isValidUserID(userID string) bool {
+	return userID != "" && userID != "." && userID != ".." && !strings.ContainsAny(userID, "/\\")
 }
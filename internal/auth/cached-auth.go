@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+	"golang.org/x/sync/singleflight"
+)
+
+// Actions that their decisions are cached separately
+const (
+	cacheDownload = "download"
+	cacheUpload   = "upload"
+	cacheDelete   = "delete"
+	cacheCopy     = "copy"
+	cacheMove     = "move"
+	cacheIdentify = "identify"
+)
+
+// This authentication method wraps another one and caches its decisions, so the same
+// files aren't checked again. (e.g. thumbnails of a page that is loaded repeatedly)
+// Decisions are cached by the auth token and each file token or type, so a request
+// asks the wrapped method just about the files that aren't cached.
+type cachedAuth struct {
+	auth Auth
+	// How long allowed and denied decisions are cached. Zero means they aren't cached.
+	allowTTL time.Duration
+	denyTTL  time.Duration
+	cache    *lruCache
+	// Concurrent identical lookups are sent to the wrapped method once
+	group singleflight.Group
+}
+
+// Cache decisions of the auth in at most maxEntries entries. Internal errors aren't
+// cached, but ErrUnauthorized and ErrForbidden errors are cached as denied decisions.
+func NewCachedAuth(auth Auth, allowTTL, denyTTL time.Duration, maxEntries int) Auth {
+	return &cachedAuth{
+		auth:     auth,
+		allowTTL: allowTTL,
+		denyTTL:  denyTTL,
+		cache:    newLRUCache(maxEntries),
+	}
+}
+
+func (c *cachedAuth) IsAllowedDownload(accessInfo DownloadAccessReq) (allowDownload, *e.Error) {
+	results, err := cachedLookup(c, cacheDownload, accessInfo.AuthToken, tokens2Strings(accessInfo.ObjectTokens),
+		func(allowed bool) bool { return allowed },
+		func(misses []string) (map[string]bool, *e.Error) {
+			allowDownload, err := c.auth.IsAllowedDownload(DownloadAccessReq{
+				AuthToken: accessInfo.AuthToken, ObjectTokens: strings2Tokens(misses),
+			})
+			return tokenMap(allowDownload), err
+		})
+	if err != nil {
+		return nil, err
+	}
+	allowDownload := make(allowDownload, len(results))
+	for k, v := range results {
+		allowDownload[token.Token(k)] = v
+	}
+	return allowDownload, nil
+}
+
+func (c *cachedAuth) IsAllowedUpload(accessInfo UploadAccessReq) ([]allowType, *e.Error) {
+	fileTypes := make([]string, 0, len(accessInfo.ObjectTypes))
+	for fileType := range accessInfo.ObjectTypes {
+		fileTypes = append(fileTypes, fileType.String())
+	}
+	results, err := cachedLookup(c, cacheUpload, accessInfo.AuthToken, fileTypes,
+		func(allowType allowType) bool { return allowType.IsAllow },
+		func(misses []string) (map[string]allowType, *e.Error) {
+			// Maximum size doesn't depend on the number of files
+			objectTypes := make(map[file.FileExtension]uint, len(misses))
+			for _, fileType := range misses {
+				objectTypes[file.FileExtension(fileType)] = accessInfo.ObjectTypes[file.FileExtension(fileType)]
+			}
+			allowTypes, err := c.auth.IsAllowedUpload(UploadAccessReq{AuthToken: accessInfo.AuthToken, ObjectTypes: objectTypes})
+			fetched := make(map[string]allowType, len(allowTypes))
+			for _, allowType := range allowTypes {
+				fetched[allowType.FileType.String()] = allowType
+			}
+			return fetched, err
+		})
+	if err != nil {
+		return nil, err
+	}
+	allowTypes := make([]allowType, 0, len(results))
+	for _, allowType := range results {
+		allowTypes = append(allowTypes, allowType)
+	}
+	return allowTypes, nil
+}
+
+func (c *cachedAuth) IsAllowedDelete(accessInfo DeleteAccessReq) (allowDelete, *e.Error) {
+	results, err := cachedLookup(c, cacheDelete, accessInfo.AuthToken, tokens2Strings(accessInfo.ObjectTokens),
+		func(allowed bool) bool { return allowed },
+		func(misses []string) (map[string]bool, *e.Error) {
+			allowDelete, err := c.auth.IsAllowedDelete(DeleteAccessReq{
+				AuthToken: accessInfo.AuthToken, ObjectTokens: strings2Tokens(misses),
+			})
+			return tokenMap(allowDelete), err
+		})
+	if err != nil {
+		return nil, err
+	}
+	allowDelete := make(allowDelete, len(results))
+	for k, v := range results {
+		allowDelete[token.Token(k)] = v
+	}
+	return allowDelete, nil
+}
+
+func (c *cachedAuth) IsAllowedCopy(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	return c.isAllowedTransfer(cacheCopy, c.auth.IsAllowedCopy, accessInfo)
+}
+
+func (c *cachedAuth) IsAllowedMove(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	return c.isAllowedTransfer(cacheMove, c.auth.IsAllowedMove, accessInfo)
+}
+
+// Each pair of source and destination is cached separately
+func (c *cachedAuth) isAllowedTransfer(action string,
+	isAllowed func(TransferAccessReq) (allowTransfer, *e.Error), accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	pairs := make([]string, 0, len(accessInfo.Objects))
+	objects := make(map[string]ObjectTransfer, len(accessInfo.Objects))
+	for _, object := range accessInfo.Objects {
+		pair := cacheItem(object.Source.String()) + cacheItem(object.Destination.String())
+		pairs = append(pairs, pair)
+		objects[pair] = object
+	}
+	results, err := cachedLookup(c, action, accessInfo.AuthToken, pairs,
+		func(allowed bool) bool { return allowed },
+		func(misses []string) (map[string]bool, *e.Error) {
+			missed := TransferAccessReq{AuthToken: accessInfo.AuthToken, Objects: make([]ObjectTransfer, 0, len(misses))}
+			for _, pair := range misses {
+				missed.Objects = append(missed.Objects, objects[pair])
+			}
+			allowTransfer, err := isAllowed(missed)
+			if err != nil {
+				return nil, err
+			}
+			if len(allowTransfer) != len(misses) {
+				return nil, e.NewErrorP("Failed to check %s access privileges: %d results for %d files",
+					ErrInternal, action, len(allowTransfer), len(misses))
+			}
+			fetched := make(map[string]bool, len(misses))
+			for i, pair := range misses {
+				fetched[pair] = allowTransfer[i]
+			}
+			return fetched, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	allowTransfer := make(allowTransfer, 0, len(pairs))
+	for _, pair := range pairs {
+		allowTransfer = append(allowTransfer, results[pair])
+	}
+	return allowTransfer, nil
+}
+
+func (c *cachedAuth) Identify(authToken token.Token) (string, *e.Error) {
+	results, err := cachedLookup(c, cacheIdentify, authToken, []string{""},
+		func(string) bool { return true },
+		func([]string) (map[string]string, *e.Error) {
+			userID, err := c.auth.Identify(authToken)
+			return map[string]string{"": userID}, err
+		})
+	if err != nil {
+		return "", err
+	}
+	return results[""], nil
+}
+
+// Return decisions of the items that are cached and ask the wrapped method about the
+// others by query. Items that query doesn't return any decision for them are missing
+// from the result.
+func cachedLookup[T any](c *cachedAuth, action string, authToken token.Token, items []string,
+	isAllowed func(T) bool, query func(misses []string) (map[string]T, *e.Error)) (map[string]T, *e.Error) {
+	// Auth tokens may be long (e.g. JWTs) and they're secret, so just their hash is kept.
+	hash := sha256.Sum256([]byte(authToken))
+	prefix := action + "\x00" + string(hash[:])
+	if cached, ok := c.cache.get(prefix); ok {
+		err := cached.(e.Error)
+		return nil, &err
+	}
+
+	results := make(map[string]T, len(items))
+	var misses []string
+	missed := make(map[string]bool)
+	for _, item := range items {
+		if cached, ok := c.cache.get(prefix + cacheItem(item)); ok {
+			results[item] = cached.(T)
+		} else if !missed[item] {
+			missed[item] = true
+			misses = append(misses, item)
+		}
+	}
+	if len(misses) == 0 {
+		return results, nil
+	}
+
+	var flightKey strings.Builder
+	flightKey.WriteString(prefix)
+	for _, item := range misses {
+		flightKey.WriteString(cacheItem(item))
+	}
+	fetched, _, _ := c.group.Do(flightKey.String(), func() (any, error) {
+		decisions, err := query(misses)
+		if err != nil {
+			if code := err.GetCode(); code == ErrUnauthorized || code == ErrForbidden {
+				c.cache.set(prefix, *err, c.denyTTL)
+			}
+			return err, nil
+		}
+		for item, decision := range decisions {
+			ttl := c.denyTTL
+			if isAllowed(decision) {
+				ttl = c.allowTTL
+			}
+			c.cache.set(prefix+cacheItem(item), decision, ttl)
+		}
+		return decisions, nil
+	})
+	// The result is shared with the concurrent lookups, so it's copied.
+	if err, ok := fetched.(*e.Error); ok {
+		errCopy := *err
+		return nil, &errCopy
+	}
+	for item, decision := range fetched.(map[string]T) {
+		results[item] = decision
+	}
+	return results, nil
+}
+
+// Items are prefixed by their length, so joined items couldn't be ambiguous.
+func cacheItem(item string) string {
+	return fmt.Sprintf("\x00%d:%s", len(item), item)
+}
+
+func strings2Tokens(strs []string) []token.Token {
+	tokens := make([]token.Token, 0, len(strs))
+	for _, str := range strs {
+		tokens = append(tokens, token.Token(str))
+	}
+	return tokens
+}
+
+func tokenMap(allowed map[token.Token]bool) map[string]bool {
+	results := make(map[string]bool, len(allowed))
+	for k, v := range allowed {
+		results[k.String()] = v
+	}
+	return results
+}
+
+// A cache that removes the least recently used entry when it's full. Entries are
+// removed after their TTL too.
+type lruCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	// Entries in order of their usage. The most recently used one is at the front.
+	order *list.List
+}
+
+type lruEntry struct {
+	key       string
+	value     any
+	expiresAt time.Time
+}
+
+func newLRUCache(maxEntries int) *lruCache {
+	return &lruCache{maxEntries: maxEntries, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (l *lruCache) get(key string) (any, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.order.Remove(element)
+		delete(l.entries, key)
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (l *lruCache) set(key string, value any, ttl time.Duration) {
+	if ttl <= 0 || l.maxEntries <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := &lruEntry{key, value, time.Now().Add(ttl)}
+	if element, ok := l.entries[key]; ok {
+		element.Value = entry
+		l.order.MoveToFront(element)
+		return
+	}
+	l.entries[key] = l.order.PushFront(entry)
+	if l.order.Len() > l.maxEntries {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+)
+
+// It records the items that each query asks about them
+type recordingAuth struct {
+	*MemoryAuth
+	delay   time.Duration
+	mu      sync.Mutex
+	queries [][]string
+}
+
+func (c *recordingAuth) record(items ...string) {
+	time.Sleep(c.delay)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	slices.Sort(items)
+	c.queries = append(c.queries, items)
+}
+
+// Return the recorded queries and forget them
+func (c *recordingAuth) take() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	queries := c.queries
+	c.queries = nil
+	return queries
+}
+
+func (c *recordingAuth) IsAllowedDownload(accessInfo DownloadAccessReq) (allowDownload, *e.Error) {
+	c.record(tokens2Strings(accessInfo.ObjectTokens)...)
+	return c.MemoryAuth.IsAllowedDownload(accessInfo)
+}
+
+func (c *recordingAuth) IsAllowedDelete(accessInfo DeleteAccessReq) (allowDelete, *e.Error) {
+	c.record(tokens2Strings(accessInfo.ObjectTokens)...)
+	return c.MemoryAuth.IsAllowedDelete(accessInfo)
+}
+
+func (c *recordingAuth) IsAllowedUpload(accessInfo UploadAccessReq) ([]allowType, *e.Error) {
+	var fileTypes []string
+	for fileType := range accessInfo.ObjectTypes {
+		fileTypes = append(fileTypes, fileType.String())
+	}
+	c.record(fileTypes...)
+	return c.MemoryAuth.IsAllowedUpload(accessInfo)
+}
+
+func (c *recordingAuth) IsAllowedCopy(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	var objects []string
+	for _, object := range accessInfo.Objects {
+		objects = append(objects, object.Source.String()+">"+object.Destination.String())
+	}
+	c.record(objects...)
+	return c.MemoryAuth.IsAllowedCopy(accessInfo)
+}
+
+func (c *recordingAuth) Identify(authToken token.Token) (string, *e.Error) {
+	c.record(authToken.String())
+	return c.MemoryAuth.Identify(authToken)
+}
+
+func newRecordingAuth() *recordingAuth {
+	memory := NewMemoryAuth().
+		AllowDownload("alice", "a.pdf", "b.pdf").
+		AllowDelete("alice", "a.pdf").
+		AllowDownload("bob", "c.pdf").
+		AllowUpload("alice", "pdf", 1024).
+		AllowCopy("alice", "a", "a/b").
+		SetUserID("alice", "alice-id")
+	return &recordingAuth{MemoryAuth: memory}
+}
+
+func TestCachedAuthKeying(t *testing.T) {
+	recording := newRecordingAuth()
+	a := NewCachedAuth(recording, time.Hour, time.Hour, 100)
+
+	download := func(authToken token.Token, objectTokens ...token.Token) (map[token.Token]bool, *e.Error) {
+		return a.IsAllowedDownload(DownloadAccessReq{AuthToken: authToken, ObjectTokens: objectTokens})
+	}
+	tests := []struct {
+		name        string
+		call        func() (map[token.Token]bool, *e.Error)
+		want        map[token.Token]bool
+		wantQueries [][]string
+	}{
+		{
+			name:        "first lookup",
+			call:        func() (map[token.Token]bool, *e.Error) { return download("alice", "a.pdf", "c.pdf") },
+			want:        map[token.Token]bool{"a.pdf": true, "c.pdf": false},
+			wantQueries: [][]string{{"a.pdf", "c.pdf"}},
+		},
+		{
+			name:        "cached decisions",
+			call:        func() (map[token.Token]bool, *e.Error) { return download("alice", "c.pdf", "a.pdf") },
+			want:        map[token.Token]bool{"a.pdf": true, "c.pdf": false},
+			wantQueries: nil,
+		},
+		{
+			name:        "just the missed files are queried",
+			call:        func() (map[token.Token]bool, *e.Error) { return download("alice", "a.pdf", "b.pdf", "b.pdf") },
+			want:        map[token.Token]bool{"a.pdf": true, "b.pdf": true},
+			wantQueries: [][]string{{"b.pdf"}},
+		},
+		{
+			name:        "another auth token",
+			call:        func() (map[token.Token]bool, *e.Error) { return download("bob", "a.pdf", "c.pdf") },
+			want:        map[token.Token]bool{"a.pdf": false, "c.pdf": true},
+			wantQueries: [][]string{{"a.pdf", "c.pdf"}},
+		},
+		{
+			name: "another action",
+			call: func() (map[token.Token]bool, *e.Error) {
+				return a.IsAllowedDelete(DeleteAccessReq{AuthToken: "alice", ObjectTokens: []token.Token{"a.pdf", "b.pdf"}})
+			},
+			want:        map[token.Token]bool{"a.pdf": true, "b.pdf": false},
+			wantQueries: [][]string{{"a.pdf", "b.pdf"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.call()
+			if err != nil {
+				t.Fatalf("lookup error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("lookup = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("decision of %s = %v, want %v", k, got[k], v)
+				}
+			}
+			if queries := recording.take(); !slices.EqualFunc(queries, tt.wantQueries, slices.Equal) {
+				t.Errorf("queries = %v, want %v", queries, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestCachedAuthTransferKeying(t *testing.T) {
+	recording := newRecordingAuth()
+	a := NewCachedAuth(recording, time.Hour, time.Hour, 100)
+	// The joined source and destination of these pairs are the same.
+	first := ObjectTransfer{Source: "a", Destination: "b/c"}
+	second := ObjectTransfer{Source: "a/b", Destination: "c"}
+
+	for i := 0; i < 2; i++ {
+		got, err := a.IsAllowedCopy(TransferAccessReq{AuthToken: "alice", Objects: []ObjectTransfer{first, second}})
+		if err != nil || len(got) != 2 || !got[0] || !got[1] {
+			t.Fatalf("IsAllowedCopy() = %v, %v, want [true true]", got, err)
+		}
+	}
+	want := [][]string{{"a/b>c", "a>b/c"}}
+	if queries := recording.take(); !slices.EqualFunc(queries, want, slices.Equal) {
+		t.Errorf("queries = %v, want %v", queries, want)
+	}
+}
+
+func TestCachedAuthUploadAndIdentify(t *testing.T) {
+	recording := newRecordingAuth()
+	a := NewCachedAuth(recording, time.Hour, time.Hour, 100)
+
+	for i := 0; i < 2; i++ {
+		allowTypes, err := a.IsAllowedUpload(UploadAccessReq{AuthToken: "alice",
+			ObjectTypes: map[file.FileExtension]uint{"pdf": uint(i + 1)}})
+		if err != nil || len(allowTypes) != 1 || allowTypes[0] != (allowType{FileType: "pdf", IsAllow: true, MaxSize: 1024}) {
+			t.Fatalf("IsAllowedUpload() = %v, %v", allowTypes, err)
+		}
+		userID, err := a.Identify("alice")
+		if err != nil || userID != "alice-id" {
+			t.Fatalf("Identify() = %q, %v, want alice-id", userID, err)
+		}
+	}
+	// Numbers of the files don't change the decision, so the second lookup is cached.
+	want := [][]string{{"pdf"}, {"alice"}}
+	if queries := recording.take(); !slices.EqualFunc(queries, want, slices.Equal) {
+		t.Errorf("queries = %v, want %v", queries, want)
+	}
+}
+
+func TestCachedAuthTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowTTL time.Duration
+		denyTTL  time.Duration
+		// Files that are queried again after waiting
+		wantQueries [][]string
+	}{
+		{"denied decisions expire first", time.Hour, 50 * time.Millisecond, [][]string{{"c.pdf"}}},
+		{"allowed decisions expire first", 50 * time.Millisecond, time.Hour, [][]string{{"a.pdf"}}},
+		{"denied decisions aren't cached", time.Hour, 0, [][]string{{"c.pdf"}}},
+		{"nothing is cached", 0, 0, [][]string{{"a.pdf", "c.pdf"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recording := newRecordingAuth()
+			a := NewCachedAuth(recording, tt.allowTTL, tt.denyTTL, 100)
+			req := DownloadAccessReq{AuthToken: "alice", ObjectTokens: []token.Token{"a.pdf", "c.pdf"}}
+			if _, err := a.IsAllowedDownload(req); err != nil {
+				t.Fatalf("IsAllowedDownload() error = %v", err)
+			}
+			recording.take()
+			time.Sleep(80 * time.Millisecond)
+			if _, err := a.IsAllowedDownload(req); err != nil {
+				t.Fatalf("IsAllowedDownload() error = %v", err)
+			}
+			if queries := recording.take(); !slices.EqualFunc(queries, tt.wantQueries, slices.Equal) {
+				t.Errorf("queries = %v, want %v", queries, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestCachedAuthErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         *e.Error
+		wantQueries int
+	}{
+		{"unauthorized is cached", e.NewErrorP("expired", ErrUnauthorized), 1},
+		{"forbidden is cached", e.NewErrorP("disabled", ErrForbidden), 1},
+		{"internal isn't cached", e.NewErrorP("database is down", ErrInternal), 2},
+		{"unavailable isn't cached", e.NewErrorP("auth server is down", ErrUnavailable), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recording := newRecordingAuth()
+			recording.SetError("alice", tt.err)
+			a := NewCachedAuth(recording, time.Hour, time.Hour, 100)
+			for i := 0; i < 2; i++ {
+				_, err := a.IsAllowedDownload(DownloadAccessReq{AuthToken: "alice", ObjectTokens: []token.Token{"a.pdf"}})
+				if err == nil || err.GetCode() != tt.err.GetCode() {
+					t.Fatalf("IsAllowedDownload() error = %v, want %v", err, tt.err.GetCode())
+				}
+			}
+			if queries := recording.take(); len(queries) != tt.wantQueries {
+				t.Errorf("got %d queries, want %d", len(queries), tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestCachedAuthCoalescesLookups(t *testing.T) {
+	recording := newRecordingAuth()
+	recording.delay = 100 * time.Millisecond
+	a := NewCachedAuth(recording, time.Hour, time.Hour, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := a.IsAllowedDownload(DownloadAccessReq{AuthToken: "alice", ObjectTokens: []token.Token{"a.pdf"}})
+			if err != nil || !got["a.pdf"] {
+				t.Errorf("IsAllowedDownload() = %v, %v", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if queries := recording.take(); len(queries) != 1 {
+		t.Errorf("got %d queries for concurrent identical lookups, want 1", len(queries))
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache(2)
+	cache.set("a", 1, time.Hour)
+	cache.set("b", 2, time.Hour)
+	cache.get("a")
+	// "b" is the least recently used one, so it's removed.
+	cache.set("c", 3, time.Hour)
+	expiring := newLRUCache(2)
+	expiring.set("a", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	tests := []struct {
+		name   string
+		cache  *lruCache
+		key    string
+		want   any
+		wantOK bool
+	}{
+		{"recently used", cache, "a", 1, true},
+		{"least recently used", cache, "b", nil, false},
+		{"new entry", cache, "c", 3, true},
+		{"expired", expiring, "a", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.cache.get(tt.key)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("get(%q) = %v, %v, want %v, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
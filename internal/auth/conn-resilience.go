@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	// Health checking of the servers by the load balancer
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+// Settings that keep the auth checks working when some auth servers are slow or down
+type ConnResilience struct {
+	// Number of attempts of each call. All calls are retried, because they're just checks.
+	MaxAttempts int
+	// Maximum time of each attempt, so a slow server doesn't spend the whole query time.
+	// Zero means only the query time limits the attempts.
+	AttemptTimeout time.Duration
+	// Waiting time before the second attempt. It's doubled up to MaxBackoff for the next
+	// attempts and a random time up to it is waited.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Number of failed calls in a row that open the circuit breaker. While it's open,
+	// calls fail immediately. Zero disables the breaker.
+	BreakerThreshold int
+	// How long the breaker stays open before letting a call check the servers again
+	BreakerCooldown time.Duration
+	// If it's true, calls are sent just to the servers that report they're serving by
+	// the gRPC health checking service. Servers that don't implement it are healthy.
+	HealthCheck bool
+}
+
+// Return target and options of the gRPC connection that spread the calls over the
+// servers in round robin and retry the failed ones.
+func resilienceOptions(serverAddrs []string, resilience ConnResilience) (string, []grpc.DialOption) {
+	addresses := make([]resolver.Address, 0, len(serverAddrs))
+	for _, addr := range serverAddrs {
+		// The target has the same authority for all servers, so without it, TLS would check
+		// the certificates against "auth". ServerName of the TLS settings still overrides it.
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		addresses = append(addresses, resolver.Address{Addr: addr, ServerName: host})
+	}
+	servers := manual.NewBuilderWithScheme("auth-servers")
+	servers.InitialState(resolver.State{Addresses: addresses})
+
+	serviceConfig := `{"loadBalancingConfig": [{"round_robin": {}}]`
+	if resilience.HealthCheck {
+		serviceConfig += `, "healthCheckConfig": {"serviceName": ""}`
+	}
+	serviceConfig += "}"
+	breaker := &circuitBreaker{threshold: resilience.BreakerThreshold, cooldown: resilience.BreakerCooldown}
+	return servers.Scheme() + ":///auth", []grpc.DialOption{
+		grpc.WithResolvers(servers),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+		grpc.WithUnaryInterceptor(retryInterceptor(resilience, breaker)),
+	}
+}
+
+// Retry failed calls with jittered exponential backoff until the deadline of the call
+func retryInterceptor(resilience ConnResilience, breaker *circuitBreaker) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		probe, ok := breaker.allow()
+		if !ok {
+			// The servers aren't retried until the cooldown ends, so it's an internal error.
+			return status.Errorf(codes.Internal, "circuit breaker is open after %d failed calls", breaker.threshold)
+		}
+		backoff := resilience.InitialBackoff
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = invokeAttempt(ctx, resilience.AttemptTimeout, method, req, reply, cc, invoker, opts...)
+			if err == nil || !isRetryable(ctx, err) || attempt >= resilience.MaxAttempts {
+				break
+			}
+			// Waiting a random time prevents the clients from retrying at the same time.
+			wait := time.Duration(0)
+			if backoff > 0 {
+				wait = rand.N(backoff) + 1
+			}
+			select {
+			case <-ctx.Done():
+				breaker.done(probe, err)
+				return err
+			case <-time.After(wait):
+			}
+			backoff = min(2*backoff, resilience.MaxBackoff)
+		}
+		breaker.done(probe, err)
+		return err
+	}
+}
+
+func invokeAttempt(ctx context.Context, timeout time.Duration, method string, req, reply any,
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// Whether the call may succeed by retrying it on the same or another server
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	// DeadlineExceeded while the call has time means just the attempt timed out.
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// It fails the calls immediately when the servers are failing, instead of waiting for
+// them in each request.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu sync.Mutex
+	// Failed calls in a row
+	failures int
+	// The breaker is open until this time
+	openUntil time.Time
+	// Whether a call is checking the servers after the cooldown
+	probing bool
+}
+
+// Whether the call could be sent, and whether it's the call that checks the servers
+// after the cooldown.
+func (b *circuitBreaker) allow() (probe bool, ok bool) {
+	if b.threshold <= 0 {
+		return false, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return false, true
+	}
+	// Just one call checks the servers after the cooldown
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// Record result of a call that allow let it. Results of the other calls that were sent
+// before the breaker opened don't end the probe.
+func (b *circuitBreaker) done(probe bool, err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	// Other errors don't show whether the servers work.
+	if !isFailure(err) {
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// Whether the error shows the servers are failing. Other errors are caused by the calls
+// themselves. (e.g. canceled calls)
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
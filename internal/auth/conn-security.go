@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Return options of the gRPC connection based on its security settings
+func dialOptions(security ConnSecurity) ([]grpc.DialOption, error) {
+	if !security.TLS {
+		// Otherwise, anyone on the network could read the secret
+		if security.BearerToken != "" {
+			return nil, fmt.Errorf("bearer token can't be sent without TLS")
+		}
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: security.ServerName}
+	if security.CAFile != "" {
+		data, err := os.ReadFile(security.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %s", security.CAFile, err.Error())
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("there isn't any certificate in CA file %s", security.CAFile)
+		}
+	}
+	if security.CertFile != "" || security.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(security.CertFile, security.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	options := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(config))}
+	if security.BearerToken != "" {
+		options = append(options, grpc.WithPerRPCCredentials(bearerCredentials(security.BearerToken)))
+	}
+	return options, nil
+}
+
+// Credentials that are sent with each call
+type bearerCredentials string
+
+func (b bearerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(b)}, nil
+}
+
+func (b bearerCredentials) RequireTransportSecurity() bool {
+	return true
+}
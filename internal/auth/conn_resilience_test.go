@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+	pbAuth "github.com/q-sharafian/file-transfer/pkg/pb/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Auth server that fails the first calls with a code and then identifies everyone as its name
+type flakyServer struct {
+	pbAuth.UnimplementedAuthServer
+	name     string
+	failures int32
+	code     codes.Code
+	delay    time.Duration
+	calls    *atomic.Int32
+}
+
+func (s flakyServer) Identify(ctx context.Context, req *pbAuth.IdentifyReq) (*pbAuth.IdentifyResult, error) {
+	call := s.calls.Add(1)
+	if call <= s.failures {
+		return nil, status.Error(s.code, "failed")
+	}
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &pbAuth.IdentifyResult{StatusCode: pbAuth.StatusCode_OK, UserID: s.name}, nil
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int32
+		code        codes.Code
+		maxAttempts int
+		wantErr     bool
+		wantCode    e.Code
+		wantCalls   int32
+	}{
+		{"succeeds", 0, codes.OK, 3, false, 0, 1},
+		{"retries unavailable", 2, codes.Unavailable, 3, false, 0, 3},
+		{"retries exhausted", 2, codes.ResourceExhausted, 3, false, 0, 3},
+		{"gives up after max attempts", 3, codes.Unavailable, 3, true, ErrUnavailable, 3},
+		{"doesn't retry internal errors", 1, codes.Internal, 3, true, ErrInternal, 1},
+		{"doesn't retry unimplemented", 1, codes.Unimplemented, 3, true, ErrNotImplemented, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := &atomic.Int32{}
+			addr := startAuthServer(t, flakyServer{name: "user", failures: tt.failures, code: tt.code, calls: calls})
+			a := NewSimpleAuth([]string{addr}, 2*time.Second, ConnSecurity{}, ConnResilience{
+				MaxAttempts:    tt.maxAttempts,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     10 * time.Millisecond,
+			}, testLogger())
+			id, err := a.Identify("auth-token")
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Identify() error = %v", err)
+				}
+				if id != "user" {
+					t.Errorf("Identify() = %q, want %q", id, "user")
+				}
+			} else if err == nil || err.GetCode() != tt.wantCode {
+				t.Errorf("Identify() error = %v, want %v", err, tt.wantCode)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("server got %d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryInterceptorAttemptTimeout(t *testing.T) {
+	slowCalls, fastCalls := &atomic.Int32{}, &atomic.Int32{}
+	slow := startAuthServer(t, flakyServer{name: "slow", delay: 5 * time.Second, calls: slowCalls})
+	fast := startAuthServer(t, flakyServer{name: "fast", calls: fastCalls})
+	a := NewSimpleAuth([]string{slow, fast}, 3*time.Second, ConnSecurity{}, ConnResilience{
+		MaxAttempts:    3,
+		AttemptTimeout: 100 * time.Millisecond,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	}, testLogger())
+
+	// Round robin sends some of the calls to the slow server first.
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		id, err := a.Identify("auth-token")
+		if err != nil {
+			t.Fatalf("Identify() error = %v", err)
+		}
+		if id != "fast" {
+			t.Errorf("Identify() = %q, want %q", id, "fast")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Identify() took %v, the slow server must be retried after the attempt timeout", elapsed)
+		}
+	}
+	if slowCalls.Load() == 0 {
+		t.Error("the slow server didn't get any call")
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	calls := &atomic.Int32{}
+	addr := startAuthServer(t, flakyServer{name: "user", failures: 2, code: codes.Unavailable, calls: calls})
+	cooldown := 200 * time.Millisecond
+	a := NewSimpleAuth([]string{addr}, 2*time.Second, ConnSecurity{}, ConnResilience{
+		MaxAttempts:      1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  cooldown,
+	}, testLogger())
+
+	for i := 0; i < 2; i++ {
+		if _, err := a.Identify("auth-token"); err == nil || err.GetCode() != ErrUnavailable {
+			t.Fatalf("call %d: Identify() error = %v, want %v", i+1, err, ErrUnavailable)
+		}
+	}
+	// The open breaker fails the calls without sending them.
+	if _, err := a.Identify("auth-token"); err == nil || err.GetCode() != ErrInternal {
+		t.Fatalf("Identify() with open breaker error = %v, want %v", err, ErrInternal)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("server got %d calls while the breaker is open, want 2", got)
+	}
+
+	time.Sleep(cooldown + 50*time.Millisecond)
+	id, err := a.Identify("auth-token")
+	if err != nil {
+		t.Fatalf("Identify() after cooldown error = %v", err)
+	}
+	if id != "user" {
+		t.Errorf("Identify() after cooldown = %q, want %q", id, "user")
+	}
+	// The successful probe closes the breaker.
+	if _, err := a.Identify("auth-token"); err != nil {
+		t.Errorf("Identify() after the probe error = %v", err)
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	failure := status.Error(codes.Unavailable, "down")
+	b := &circuitBreaker{threshold: 1, cooldown: 20 * time.Millisecond}
+
+	// A call that started before the breaker opened
+	late, ok := b.allow()
+	if late || !ok {
+		t.Fatalf("allow() of the closed breaker = (%v, %v), want (false, true)", late, ok)
+	}
+	b.done(false, failure)
+	if _, ok := b.allow(); ok {
+		t.Fatal("allow() = true during the cooldown")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	probe, ok := b.allow()
+	if !probe || !ok {
+		t.Fatalf("allow() after the cooldown = (%v, %v), want (true, true)", probe, ok)
+	}
+	if _, ok := b.allow(); ok {
+		t.Fatal("allow() = true while another call is probing")
+	}
+	// Results of the other calls don't end the probe.
+	b.done(false, status.Error(codes.Canceled, "canceled"))
+	if _, ok := b.allow(); ok {
+		t.Fatal("allow() = true after a call that isn't the probe is done")
+	}
+
+	b.done(probe, failure)
+	if _, ok := b.allow(); ok {
+		t.Fatal("allow() = true after the failed probe")
+	}
+	time.Sleep(30 * time.Millisecond)
+	probe, ok = b.allow()
+	if !probe || !ok {
+		t.Fatalf("allow() after the second cooldown = (%v, %v), want (true, true)", probe, ok)
+	}
+	b.done(probe, nil)
+	if probe, ok := b.allow(); probe || !ok {
+		t.Errorf("allow() after the successful probe = (%v, %v), want (false, true)", probe, ok)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+		want bool
+	}{
+		{"unavailable", context.Background(), codes.Unavailable, true},
+		{"resource exhausted", context.Background(), codes.ResourceExhausted, true},
+		{"attempt timeout", context.Background(), codes.DeadlineExceeded, true},
+		{"internal", context.Background(), codes.Internal, false},
+		{"permission denied", context.Background(), codes.PermissionDenied, false},
+		{"call is canceled", canceled, codes.Unavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.ctx, status.Error(tt.code, "failed")); got != tt.want {
+				t.Errorf("isRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
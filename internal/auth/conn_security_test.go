@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pbAuth "github.com/q-sharafian/file-transfer/pkg/pb/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const testServerName = "auth.internal"
+
+// Auth server that identifies the clients by their certificates and bearer tokens
+type peerIdentifyServer struct {
+	pbAuth.UnimplementedAuthServer
+}
+
+// User ID is "<common name of the client certificate>.<bearer token>". They're
+// "anonymous" and "none" if they aren't sent.
+func (peerIdentifyServer) Identify(ctx context.Context, req *pbAuth.IdentifyReq) (*pbAuth.IdentifyResult, error) {
+	name, bearer := "anonymous", "none"
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+			name = info.State.PeerCertificates[0].Subject.CommonName
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		bearer = values[0]
+	}
+	return &pbAuth.IdentifyResult{StatusCode: pbAuth.StatusCode_OK, UserID: name + "." + bearer}, nil
+}
+
+// Certificates and keys of the tests in PEM format
+type testPKI struct {
+	ca      *x509.Certificate
+	caKey   *ecdsa.PrivateKey
+	caFile  string
+	dir     string
+	caPool  *x509.CertPool
+	srvCert tls.Certificate
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	pki := &testPKI{dir: t.TempDir()}
+	var caPEM []byte
+	pki.ca, pki.caKey, caPEM, _ = pki.issue(t, "test-ca", true)
+	pki.caFile = pki.writeFile(t, "ca.pem", caPEM)
+	pki.caPool = x509.NewCertPool()
+	pki.caPool.AddCert(pki.ca)
+
+	pki.srvCert = pki.serverCert(t, testServerName)
+	return pki
+}
+
+// Issue a server certificate for the host name or IP address
+func (pki *testPKI) serverCert(t *testing.T, host string) tls.Certificate {
+	t.Helper()
+	_, _, certPEM, keyPEM := pki.issue(t, host, false)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load server certificate: %v", err)
+	}
+	return cert
+}
+
+// Issue a certificate by the CA. If isCA is true, the certificate is self-signed.
+func (pki *testPKI) issue(t *testing.T, name string, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{name}
+	}
+	parent, parentKey := template, key
+	if !isCA {
+		parent, parentKey = pki.ca, pki.caKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// Issue a client certificate and return paths of its certificate and key files
+func (pki *testPKI) clientFiles(t *testing.T, name string) (string, string) {
+	t.Helper()
+	_, _, certPEM, keyPEM := pki.issue(t, name, false)
+	return pki.writeFile(t, name+".pem", certPEM), pki.writeFile(t, name+".key", keyPEM)
+}
+
+func (pki *testPKI) writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(pki.dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// Start an auth server and return its address. It's stopped at the end of the test.
+func startAuthServer(t *testing.T, server pbAuth.AuthServer, options ...grpc.ServerOption) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer(options...)
+	pbAuth.RegisterAuthServer(srv, server)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+	return listener.Addr().String()
+}
+
+func TestConnSecurityIdentify(t *testing.T) {
+	pki := newTestPKI(t)
+	clientCert, clientKey := pki.clientFiles(t, "file-transfer")
+	tlsAddr := startAuthServer(t, peerIdentifyServer{}, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pki.srvCert},
+	})))
+	mtlsAddr := startAuthServer(t, peerIdentifyServer{}, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pki.srvCert},
+		ClientCAs:    pki.caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	// Certificate of the address that is dialed, so the server name isn't needed
+	hostAddr := startAuthServer(t, peerIdentifyServer{}, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pki.serverCert(t, "127.0.0.1")},
+	})))
+
+	tests := []struct {
+		name     string
+		addr     string
+		security ConnSecurity
+		wantID   string
+		wantErr  bool
+	}{
+		{
+			name:     "TLS",
+			addr:     tlsAddr,
+			security: ConnSecurity{TLS: true, CAFile: pki.caFile, ServerName: testServerName},
+			wantID:   "anonymous.none",
+		},
+		{
+			name: "mutual TLS",
+			addr: mtlsAddr,
+			security: ConnSecurity{TLS: true, CAFile: pki.caFile, ServerName: testServerName,
+				CertFile: clientCert, KeyFile: clientKey},
+			wantID: "file-transfer.none",
+		},
+		{
+			name:     "bearer token",
+			addr:     tlsAddr,
+			security: ConnSecurity{TLS: true, CAFile: pki.caFile, ServerName: testServerName, BearerToken: "s3cret"},
+			wantID:   "anonymous.Bearer s3cret",
+		},
+		{
+			name:     "mutual TLS without client certificate",
+			addr:     mtlsAddr,
+			security: ConnSecurity{TLS: true, CAFile: pki.caFile, ServerName: testServerName},
+			wantErr:  true,
+		},
+		{
+			name:     "wrong server name",
+			addr:     tlsAddr,
+			security: ConnSecurity{TLS: true, CAFile: pki.caFile, ServerName: "other.internal"},
+			wantErr:  true,
+		},
+		{
+			name:     "without server name",
+			addr:     hostAddr,
+			security: ConnSecurity{TLS: true, CAFile: pki.caFile},
+			wantID:   "anonymous.none",
+		},
+		{
+			name:     "without server name and certificate of another host",
+			addr:     tlsAddr,
+			security: ConnSecurity{TLS: true, CAFile: pki.caFile},
+			wantErr:  true,
+		},
+		{
+			name:     "untrusted server",
+			addr:     tlsAddr,
+			security: ConnSecurity{TLS: true, ServerName: testServerName},
+			wantErr:  true,
+		},
+		{
+			name:     "plaintext to TLS server",
+			addr:     tlsAddr,
+			security: ConnSecurity{},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewSimpleAuth([]string{tt.addr}, 2*time.Second, tt.security, ConnResilience{MaxAttempts: 1}, testLogger())
+			id, err := a.Identify("auth-token")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Identify() = %q, want error", id)
+				}
+				if code := err.GetCode(); code != ErrUnavailable && code != ErrInternal {
+					t.Errorf("Identify() error code = %v, want %v or %v", code, ErrUnavailable, ErrInternal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Identify() error = %v", err)
+			}
+			if id != tt.wantID {
+				t.Errorf("Identify() = %q, want %q", id, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestDialOptions(t *testing.T) {
+	pki := newTestPKI(t)
+	clientCert, clientKey := pki.clientFiles(t, "file-transfer")
+	notPEM := pki.writeFile(t, "not-pem.txt", []byte("not a certificate"))
+
+	tests := []struct {
+		name     string
+		security ConnSecurity
+		wantErr  bool
+	}{
+		{"plaintext", ConnSecurity{}, false},
+		{"bearer token without TLS", ConnSecurity{BearerToken: "s3cret"}, true},
+		{"TLS with system CAs", ConnSecurity{TLS: true}, false},
+		{"TLS with bearer token", ConnSecurity{TLS: true, CAFile: pki.caFile, BearerToken: "s3cret"}, false},
+		{"mutual TLS", ConnSecurity{TLS: true, CAFile: pki.caFile, CertFile: clientCert, KeyFile: clientKey}, false},
+		{"missing CA file", ConnSecurity{TLS: true, CAFile: filepath.Join(pki.dir, "missing.pem")}, true},
+		{"CA file without certificates", ConnSecurity{TLS: true, CAFile: notPEM}, true},
+		{"certificate without key", ConnSecurity{TLS: true, CertFile: clientCert}, true},
+		{"invalid key", ConnSecurity{TLS: true, CertFile: clientCert, KeyFile: notPEM}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dialOptions(tt.security)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("dialOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBearerCredentials(t *testing.T) {
+	creds := bearerCredentials("s3cret")
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() error = %v", err)
+	}
+	if got := md["authorization"]; got != "Bearer s3cret" {
+		t.Errorf("authorization = %q, want %q", got, "Bearer s3cret")
+	}
+	if !creds.RequireTransportSecurity() {
+		t.Error("bearer token must require transport security")
+	}
+}
@@ -1,6 +1,9 @@
 package auth
 
-import "github.com/q-sharafian/file-transfer/pkg/error"
+import (
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/pkg/error"
+)
 
 // Its purpose is just for testing
 type dummyAuth struct {
@@ -30,3 +33,28 @@ func (d *dummyAuth) IsAllowedUpload(accessInfo UploadAccessReq) ([]allowType, *e
 	}
 	return allowTypes, nil
 }
+
+func (d *dummyAuth) IsAllowedDelete(accessInfo DeleteAccessReq) (allowDelete, *error.Error) {
+	allowDelete := make(allowDelete)
+	for _, t := range accessInfo.ObjectTokens {
+		allowDelete[t] = true
+	}
+	return allowDelete, nil
+}
+
+func (d *dummyAuth) IsAllowedCopy(accessInfo TransferAccessReq) (allowTransfer, *error.Error) {
+	allowTransfer := make(allowTransfer, len(accessInfo.Objects))
+	for i := range allowTransfer {
+		allowTransfer[i] = true
+	}
+	return allowTransfer, nil
+}
+
+func (d *dummyAuth) IsAllowedMove(accessInfo TransferAccessReq) (allowTransfer, *error.Error) {
+	return d.IsAllowedCopy(accessInfo)
+}
+
+// All clients are the same user
+func (d *dummyAuth) Identify(authToken token.Token) (string, *error.Error) {
+	return "dummy", nil
+}
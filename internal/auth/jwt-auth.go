@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+	l "github.com/q-sharafian/file-transfer/pkg/logger"
+)
+
+// Allowed difference between the clock of this app and the token issuer
+const jwtLeeway = 30 * time.Second
+
+type JWTConfig struct {
+	// Algorithms that the tokens could be signed with. (HS256, RS256 and ES256)
+	Algorithms []string
+	// Secret of HS256 tokens
+	Secret []byte
+	// PEM file that contains an RSA or ECDSA (P-256) public key
+	PublicKeyFile string
+	// JSON Web Key Set file. Keys are selected by "kid" header of the tokens.
+	JWKSFile string
+	// If they're set, "iss" and "aud" claims of the tokens must have the same values.
+	Issuer   string
+	Audience string
+}
+
+// Permissions of the client that are in its token. Keys of the files are matched with
+// the patterns like path.Match, but a pattern that ends with "/**" matches all the
+// files under its directory (e.g. "alice/**") and "**" matches all the files.
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	// A map from file types that the client could upload to their maximum size in Kbytes.
+	// Zero means there's no limit and "*" means any other type.
+	Upload map[string]uint64 `json:"upload"`
+	// Files that the client could download
+	Download []string `json:"download"`
+	// Files that the client could delete
+	Delete []string `json:"delete"`
+	// Files that the client could copy, and the destinations it could copy them to
+	Copy []string `json:"copy"`
+	// Same as Copy, but for moving the files
+	Move []string `json:"move"`
+}
+
+// This authentication method checks JWTs locally, so the auth server isn't needed.
+// ID of the user is "sub" claim of its token.
+type jwtAuth struct {
+	keys   *jwtKeys
+	parser *jwt.Parser
+	logger l.Logger
+}
+
+// Create a JWT authentication method with the keys of the configuration. If the
+// configuration isn't valid, it panics.
+func NewJWTAuth(config JWTConfig, logger l.Logger) Auth {
+	if len(config.Algorithms) == 0 {
+		logger.Panicf("At least one JWT algorithm is needed")
+	}
+	for _, alg := range config.Algorithms {
+		if alg != jwt.SigningMethodHS256.Alg() && alg != jwt.SigningMethodRS256.Alg() &&
+			alg != jwt.SigningMethodES256.Alg() {
+			logger.Panicf("JWT algorithm %s isn't supported", alg)
+		}
+	}
+	keys, err := loadJWTKeys(config)
+	if err != nil {
+		logger.Panicf("Failed to load JWT keys: %s", err.Error())
+	}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(config.Algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	return &jwtAuth{keys, jwt.NewParser(options...), logger}
+}
+
+// Verify the token and return its claims
+func (j *jwtAuth) parse(authToken token.Token) (*jwtClaims, *e.Error) {
+	var claims jwtClaims
+	if _, err := j.parser.ParseWithClaims(authToken.String(), &claims, j.keys.find); err != nil {
+		return nil, e.Wrap(err, "Invalid auth token", ErrUnauthorized)
+	}
+	return &claims, nil
+}
+
+func (j *jwtAuth) IsAllowedDownload(accessInfo DownloadAccessReq) (allowDownload, *e.Error) {
+	claims, err := j.parse(accessInfo.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+	allowDownload := make(allowDownload)
+	for _, t := range accessInfo.ObjectTokens {
+		allowDownload[t] = file.MatchKey(claims.Download, t.String())
+	}
+	return allowDownload, nil
+}
+
+func (j *jwtAuth) IsAllowedUpload(accessInfo UploadAccessReq) ([]allowType, *e.Error) {
+	claims, err := j.parse(accessInfo.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+	allowTypes := make([]allowType, 0, len(accessInfo.ObjectTypes))
+	for fileType := range accessInfo.ObjectTypes {
+		maxSize, isAllow := claims.Upload[fileType.String()]
+		if !isAllow {
+			maxSize, isAllow = claims.Upload["*"]
+		}
+		allowTypes = append(allowTypes, allowType{FileType: fileType, IsAllow: isAllow, MaxSize: maxSize})
+	}
+	return allowTypes, nil
+}
+
+func (j *jwtAuth) IsAllowedDelete(accessInfo DeleteAccessReq) (allowDelete, *e.Error) {
+	claims, err := j.parse(accessInfo.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+	allowDelete := make(allowDelete)
+	for _, t := range accessInfo.ObjectTokens {
+		allowDelete[t] = file.MatchKey(claims.Delete, t.String())
+	}
+	return allowDelete, nil
+}
+
+func (j *jwtAuth) IsAllowedCopy(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	claims, err := j.parse(accessInfo.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+	return allowTransfers(claims.Copy, accessInfo.Objects), nil
+}
+
+func (j *jwtAuth) IsAllowedMove(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	claims, err := j.parse(accessInfo.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+	return allowTransfers(claims.Move, accessInfo.Objects), nil
+}
+
+func (j *jwtAuth) Identify(authToken token.Token) (string, *e.Error) {
+	claims, err := j.parse(authToken)
+	if err != nil {
+		return "", err
+	}
+	if !isValidUserID(claims.Subject) {
+		return "", e.NewErrorP("Failed to identify the user: invalid sub claim %q", ErrUnauthorized, claims.Subject)
+	}
+	return claims.Subject, nil
+}
+
+// Both the source and the destination of each transfer must match the patterns
+func allowTransfers(patterns []string, objects []ObjectTransfer) allowTransfer {
+	allowTransfer := make(allowTransfer, 0, len(objects))
+	for _, object := range objects {
+		allowTransfer = append(allowTransfer,
+			file.MatchKey(patterns, object.Source.String()) && file.MatchKey(patterns, object.Destination.String()))
+	}
+	return allowTransfer
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Keys that JWTs are verified with them
+type jwtKeys struct {
+	secret []byte
+	rsaKey *rsa.PublicKey
+	ecKey  *ecdsa.PublicKey
+	// A map from IDs of the keys in the JWKS file to the keys
+	jwks map[string]jwk
+}
+
+type jwk struct {
+	// Algorithm of the key. If it's empty, the key could be used for any algorithm that
+	// matches its type.
+	alg string
+	key any
+}
+
+// A key of a JSON Web Key Set. (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// Symmetric keys
+	K string `json:"k"`
+}
+
+func loadJWTKeys(config JWTConfig) (*jwtKeys, error) {
+	keys := &jwtKeys{secret: config.Secret, jwks: make(map[string]jwk)}
+	if config.PublicKeyFile != "" {
+		data, err := os.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key file %s: %s", config.PublicKeyFile, err.Error())
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("public key file %s isn't in PEM format", config.PublicKeyFile)
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key file %s: %s", config.PublicKeyFile, err.Error())
+		}
+		switch publicKey := publicKey.(type) {
+		case *rsa.PublicKey:
+			keys.rsaKey = publicKey
+		case *ecdsa.PublicKey:
+			if publicKey.Curve != elliptic.P256() {
+				return nil, fmt.Errorf("curve of public key file %s isn't P-256", config.PublicKeyFile)
+			}
+			keys.ecKey = publicKey
+		default:
+			return nil, fmt.Errorf("public key file %s isn't an RSA or ECDSA key", config.PublicKeyFile)
+		}
+	}
+	if config.JWKSFile != "" {
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file %s: %s", config.JWKSFile, err.Error())
+		}
+		var jwks struct {
+			Keys []jsonWebKey `json:"keys"`
+		}
+		if err := json.Unmarshal(data, &jwks); err != nil {
+			return nil, fmt.Errorf("failed to parse JWKS file %s: %s", config.JWKSFile, err.Error())
+		}
+		for _, webKey := range jwks.Keys {
+			// Keys of encryption aren't needed
+			if webKey.Use != "" && webKey.Use != "sig" {
+				continue
+			}
+			key, err := parseJSONWebKey(webKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse key %q of JWKS file %s: %s", webKey.Kid, config.JWKSFile, err.Error())
+			}
+			keys.jwks[webKey.Kid] = jwk{webKey.Alg, key}
+		}
+	}
+	if keys.secret == nil && keys.rsaKey == nil && keys.ecKey == nil && len(keys.jwks) == 0 {
+		return nil, fmt.Errorf("there isn't any key")
+	}
+	return keys, nil
+}
+
+func parseJSONWebKey(webKey jsonWebKey) (any, error) {
+	switch webKey.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(webKey.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(webKey.E)
+		if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("n or e of the RSA key is invalid")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if webKey.Crv != "P-256" {
+			return nil, fmt.Errorf("curve %s isn't supported", webKey.Crv)
+		}
+		x, err1 := base64.RawURLEncoding.DecodeString(webKey.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(webKey.Y)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("x or y of the EC key is invalid")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("the EC key isn't on the curve")
+		}
+		return key, nil
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(webKey.K)
+		if err != nil || len(k) == 0 {
+			return nil, fmt.Errorf("k of the symmetric key is invalid")
+		}
+		return k, nil
+	default:
+		return nil, fmt.Errorf("key type %s isn't supported", webKey.Kty)
+	}
+}
+
+// Return the key that the token must be verified with it. If the token has "kid"
+// header, its key is selected from the JWKS file.
+func (k *jwtKeys) find(t *jwt.Token) (any, error) {
+	alg := t.Method.Alg()
+	if kid, ok := t.Header["kid"].(string); ok && len(k.jwks) > 0 {
+		key, found := k.jwks[kid]
+		if !found {
+			return nil, fmt.Errorf("key %q is unknown", kid)
+		}
+		if key.alg != "" && key.alg != alg {
+			return nil, fmt.Errorf("key %q isn't for %s algorithm", kid, alg)
+		}
+		return key.key, nil
+	}
+	var key any
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		if k.secret != nil {
+			key = k.secret
+		}
+	case jwt.SigningMethodRS256.Alg():
+		if k.rsaKey != nil {
+			key = k.rsaKey
+		}
+	case jwt.SigningMethodES256.Alg():
+		if k.ecKey != nil {
+			key = k.ecKey
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("there isn't any key for %s algorithm", alg)
+	}
+	return key, nil
+}
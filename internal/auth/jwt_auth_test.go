@@ -0,0 +1,314 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	l "github.com/q-sharafian/file-transfer/pkg/logger"
+)
+
+func testLogger() l.Logger {
+	return l.NewSLogger(l.Error, nil, os.Stderr)
+}
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// Keys that the test tokens are signed with them
+type jwtTestKeys struct {
+	rsa      *rsa.PrivateKey
+	ec       *ecdsa.PrivateKey
+	otherEC  *ecdsa.PrivateKey
+	dir      string
+	rsaFile  string
+	ecFile   string
+	jwksFile string
+}
+
+func newJWTTestKeys(t *testing.T) *jwtTestKeys {
+	t.Helper()
+	keys := &jwtTestKeys{dir: t.TempDir()}
+	var err error
+	if keys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	if keys.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	if keys.otherEC, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	keys.rsaFile = keys.writePublicKey(t, "rsa.pem", &keys.rsa.PublicKey)
+	keys.ecFile = keys.writePublicKey(t, "ec.pem", &keys.ec.PublicKey)
+
+	b64 := base64.RawURLEncoding
+	jwks := map[string][]map[string]string{"keys": {
+		{"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "use": "sig",
+			"n": b64.EncodeToString(keys.rsa.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(keys.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256",
+			"x": b64.EncodeToString(keys.ec.X.FillBytes(make([]byte, 32))), "y": b64.EncodeToString(keys.ec.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "oct", "kid": "hs-1", "alg": "HS256", "k": b64.EncodeToString(testSecret)},
+		// Keys of encryption are skipped.
+		{"kty": "oct", "kid": "enc-1", "use": "enc", "k": b64.EncodeToString(testSecret)},
+	}}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatalf("failed to marshal JWKS: %v", err)
+	}
+	keys.jwksFile = keys.writeFile(t, "jwks.json", data)
+	return keys
+}
+
+func (k *jwtTestKeys) writePublicKey(t *testing.T, name string, publicKey crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	return k.writeFile(t, name, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func (k *jwtTestKeys) writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(k.dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// Claims of a valid token of alice
+func aliceClaims() *jwtClaims {
+	return &jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "issuer",
+			Audience:  jwt.ClaimStrings{"file-transfer"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Upload:   map[string]uint64{"pdf": 1024, "*": 0},
+		Download: []string{"alice/**", "public/*.png"},
+	}
+}
+
+func signJWT(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.Claims) token.Token {
+	t.Helper()
+	jwtToken := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		jwtToken.Header["kid"] = kid
+	}
+	signed, err := jwtToken.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign the token: %v", err)
+	}
+	return token.Token(signed)
+}
+
+func TestJWTAuthAlgorithms(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	allAlgorithms := []string{"HS256", "RS256", "ES256"}
+	staticKeys := JWTConfig{Algorithms: allAlgorithms, Secret: testSecret, PublicKeyFile: keys.rsaFile}
+	expired := aliceClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	withoutExp := aliceClaims()
+	withoutExp.ExpiresAt = nil
+	inLeeway := aliceClaims()
+	inLeeway.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-jwtLeeway / 2))
+	rsaPEM, err := os.ReadFile(keys.rsaFile)
+	if err != nil {
+		t.Fatalf("failed to read RSA key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config JWTConfig
+		token  token.Token
+		wantOK bool
+	}{
+		{"HS256", staticKeys, signJWT(t, jwt.SigningMethodHS256, testSecret, "", aliceClaims()), true},
+		{"RS256", staticKeys, signJWT(t, jwt.SigningMethodRS256, keys.rsa, "", aliceClaims()), true},
+		{"ES256", JWTConfig{Algorithms: allAlgorithms, PublicKeyFile: keys.ecFile},
+			signJWT(t, jwt.SigningMethodES256, keys.ec, "", aliceClaims()), true},
+		{"algorithm isn't allowed", JWTConfig{Algorithms: []string{"RS256"}, Secret: testSecret, PublicKeyFile: keys.rsaFile},
+			signJWT(t, jwt.SigningMethodHS256, testSecret, "", aliceClaims()), false},
+		{"none algorithm", staticKeys,
+			signJWT(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", aliceClaims()), false},
+		// The public key mustn't be used as an HMAC secret.
+		{"HS256 signed by the public key", JWTConfig{Algorithms: allAlgorithms, PublicKeyFile: keys.rsaFile},
+			signJWT(t, jwt.SigningMethodHS256, rsaPEM, "", aliceClaims()), false},
+		{"ES256 without its key", staticKeys, signJWT(t, jwt.SigningMethodES256, keys.ec, "", aliceClaims()), false},
+		{"wrong secret", staticKeys, signJWT(t, jwt.SigningMethodHS256, []byte("another secret of 32 bytes long."), "", aliceClaims()), false},
+		{"expired", staticKeys, signJWT(t, jwt.SigningMethodHS256, testSecret, "", expired), false},
+		{"expired in the leeway", staticKeys, signJWT(t, jwt.SigningMethodHS256, testSecret, "", inLeeway), true},
+		{"without exp", staticKeys, signJWT(t, jwt.SigningMethodHS256, testSecret, "", withoutExp), false},
+		{"matched issuer and audience", JWTConfig{Algorithms: allAlgorithms, Secret: testSecret, Issuer: "issuer", Audience: "file-transfer"},
+			signJWT(t, jwt.SigningMethodHS256, testSecret, "", aliceClaims()), true},
+		{"other issuer", JWTConfig{Algorithms: allAlgorithms, Secret: testSecret, Issuer: "other"},
+			signJWT(t, jwt.SigningMethodHS256, testSecret, "", aliceClaims()), false},
+		{"other audience", JWTConfig{Algorithms: allAlgorithms, Secret: testSecret, Audience: "other"},
+			signJWT(t, jwt.SigningMethodHS256, testSecret, "", aliceClaims()), false},
+		{"malformed", staticKeys, "not-a-jwt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewJWTAuth(tt.config, testLogger())
+			userID, err := a.Identify(tt.token)
+			if !tt.wantOK {
+				if err == nil || err.GetCode() != ErrUnauthorized {
+					t.Errorf("Identify() = %q, %v, want %v", userID, err, ErrUnauthorized)
+				}
+				return
+			}
+			if err != nil || userID != "alice" {
+				t.Errorf("Identify() = %q, %v, want alice", userID, err)
+			}
+		})
+	}
+}
+
+func TestJWTAuthKeyID(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	config := JWTConfig{Algorithms: []string{"HS256", "RS256", "ES256"}, JWKSFile: keys.jwksFile}
+
+	tests := []struct {
+		name   string
+		config JWTConfig
+		token  token.Token
+		wantOK bool
+	}{
+		{"RSA key", config, signJWT(t, jwt.SigningMethodRS256, keys.rsa, "rsa-1", aliceClaims()), true},
+		// The key doesn't have alg, so it's used for the algorithm of its type.
+		{"EC key without alg", config, signJWT(t, jwt.SigningMethodES256, keys.ec, "ec-1", aliceClaims()), true},
+		{"symmetric key", config, signJWT(t, jwt.SigningMethodHS256, testSecret, "hs-1", aliceClaims()), true},
+		{"unknown kid", config, signJWT(t, jwt.SigningMethodRS256, keys.rsa, "rsa-2", aliceClaims()), false},
+		{"encryption key", config, signJWT(t, jwt.SigningMethodHS256, testSecret, "enc-1", aliceClaims()), false},
+		{"without kid", config, signJWT(t, jwt.SigningMethodRS256, keys.rsa, "", aliceClaims()), false},
+		{"alg of the key differs", config, signJWT(t, jwt.SigningMethodHS256, testSecret, "rsa-1", aliceClaims()), false},
+		{"type of the key differs", config, signJWT(t, jwt.SigningMethodHS256, testSecret, "ec-1", aliceClaims()), false},
+		{"signed by another key", config, signJWT(t, jwt.SigningMethodES256, keys.otherEC, "ec-1", aliceClaims()), false},
+		// Without a JWKS file, kid is ignored and the static keys are used.
+		{"kid without JWKS", JWTConfig{Algorithms: []string{"HS256"}, Secret: testSecret},
+			signJWT(t, jwt.SigningMethodHS256, testSecret, "any", aliceClaims()), true},
+		{"static key with JWKS", JWTConfig{Algorithms: []string{"RS256"}, PublicKeyFile: keys.rsaFile, JWKSFile: keys.jwksFile},
+			signJWT(t, jwt.SigningMethodRS256, keys.rsa, "", aliceClaims()), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewJWTAuth(tt.config, testLogger())
+			userID, err := a.Identify(tt.token)
+			if !tt.wantOK {
+				if err == nil || err.GetCode() != ErrUnauthorized {
+					t.Errorf("Identify() = %q, %v, want %v", userID, err, ErrUnauthorized)
+				}
+				return
+			}
+			if err != nil || userID != "alice" {
+				t.Errorf("Identify() = %q, %v, want alice", userID, err)
+			}
+		})
+	}
+}
+
+func TestJWTAuthPermissions(t *testing.T) {
+	a := NewJWTAuth(JWTConfig{Algorithms: []string{"HS256"}, Secret: testSecret}, testLogger())
+	claims := aliceClaims()
+	claims.Upload = map[string]uint64{"pdf": 1024, "png": 0}
+	claims.Copy = []string{"alice/**"}
+	authToken := signJWT(t, jwt.SigningMethodHS256, testSecret, "", claims)
+
+	download, err := a.IsAllowedDownload(DownloadAccessReq{AuthToken: authToken,
+		ObjectTokens: []token.Token{"alice/a.pdf", "alice/dir/b.pdf", "public/a.png", "public/dir/a.png", "bob/a.pdf"}})
+	if err != nil {
+		t.Fatalf("IsAllowedDownload() error = %v", err)
+	}
+	wantDownload := map[token.Token]bool{"alice/a.pdf": true, "alice/dir/b.pdf": true, "public/a.png": true,
+		"public/dir/a.png": false, "bob/a.pdf": false}
+	for objectToken, want := range wantDownload {
+		if download[objectToken] != want {
+			t.Errorf("download of %s = %v, want %v", objectToken, download[objectToken], want)
+		}
+	}
+
+	upload, err := a.IsAllowedUpload(UploadAccessReq{AuthToken: authToken,
+		ObjectTypes: map[file.FileExtension]uint{"pdf": 1, "png": 1, "exe": 1}})
+	if err != nil {
+		t.Fatalf("IsAllowedUpload() error = %v", err)
+	}
+	wantUpload := map[file.FileExtension]allowType{
+		"pdf": {FileType: "pdf", IsAllow: true, MaxSize: 1024},
+		"png": {FileType: "png", IsAllow: true, MaxSize: 0},
+		"exe": {FileType: "exe", IsAllow: false},
+	}
+	if len(upload) != len(wantUpload) {
+		t.Fatalf("IsAllowedUpload() = %v, want %d types", upload, len(wantUpload))
+	}
+	for _, got := range upload {
+		if got != wantUpload[got.FileType] {
+			t.Errorf("upload of %s = %+v, want %+v", got.FileType, got, wantUpload[got.FileType])
+		}
+	}
+
+	copies, err := a.IsAllowedCopy(TransferAccessReq{AuthToken: authToken, Objects: []ObjectTransfer{
+		{Source: "alice/a.pdf", Destination: "alice/b.pdf"},
+		{Source: "alice/a.pdf", Destination: "bob/a.pdf"},
+	}})
+	if err != nil || len(copies) != 2 || !copies[0] || copies[1] {
+		t.Errorf("IsAllowedCopy() = %v, %v, want [true false]", copies, err)
+	}
+	moves, err := a.IsAllowedMove(TransferAccessReq{AuthToken: authToken, Objects: []ObjectTransfer{
+		{Source: "alice/a.pdf", Destination: "alice/b.pdf"},
+	}})
+	if err != nil || len(moves) != 1 || moves[0] {
+		t.Errorf("IsAllowedMove() = %v, %v, want [false]", moves, err)
+	}
+
+	invalidSub := aliceClaims()
+	invalidSub.Subject = "../alice"
+	if _, err := a.Identify(signJWT(t, jwt.SigningMethodHS256, testSecret, "", invalidSub)); err == nil || err.GetCode() != ErrUnauthorized {
+		t.Errorf("Identify() with invalid sub error = %v, want %v", err, ErrUnauthorized)
+	}
+}
+
+func TestLoadJWTKeysErrors(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	notPEM := keys.writeFile(t, "not-pem.txt", []byte("not a key"))
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	p384File := keys.writePublicKey(t, "p384.pem", &p384.PublicKey)
+	invalidJWKS := keys.writeFile(t, "invalid-jwks.json", []byte(`{"keys": [{"kty": "EC", "kid": "x", "crv": "P-384"}]}`))
+
+	tests := []struct {
+		name   string
+		config JWTConfig
+	}{
+		{"no keys", JWTConfig{}},
+		{"missing public key file", JWTConfig{PublicKeyFile: filepath.Join(keys.dir, "missing.pem")}},
+		{"public key isn't PEM", JWTConfig{PublicKeyFile: notPEM}},
+		{"EC key isn't P-256", JWTConfig{PublicKeyFile: p384File}},
+		{"JWKS isn't JSON", JWTConfig{JWKSFile: notPEM}},
+		{"JWKS with unsupported curve", JWTConfig{JWKSFile: invalidJWKS}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadJWTKeys(tt.config); err == nil {
+				t.Error("loadJWTKeys() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"sync"
+
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+)
+
+// Its purpose is just for testing. Unlike dummyAuth, permissions of each auth token
+// are configurable. An auth token that has not any permission or error is unknown and
+// gets ErrUnauthorized. It's safe for concurrent use.
+type MemoryAuth struct {
+	mu sync.RWMutex
+	// Allowed file types of each auth token and maximum size of them in Kbytes
+	uploads map[token.Token]map[file.FileExtension]uint64
+	// Files that each auth token could download
+	downloads map[token.Token]map[token.Token]bool
+	// Files that each auth token could delete
+	deletes map[token.Token]map[token.Token]bool
+	// Files that each auth token could copy/move to any destination
+	copies map[token.Token]map[token.Token]bool
+	moves  map[token.Token]map[token.Token]bool
+	// ID of the user of each auth token. If it's not set, the auth token itself is the ID.
+	userIDs map[token.Token]string
+	// Errors that are returned for the auth token instead of checking its permissions
+	errs map[token.Token]*e.Error
+}
+
+func NewMemoryAuth() *MemoryAuth {
+	return &MemoryAuth{
+		uploads:   make(map[token.Token]map[file.FileExtension]uint64),
+		downloads: make(map[token.Token]map[token.Token]bool),
+		deletes:   make(map[token.Token]map[token.Token]bool),
+		copies:    make(map[token.Token]map[token.Token]bool),
+		moves:     make(map[token.Token]map[token.Token]bool),
+		userIDs:   make(map[token.Token]string),
+		errs:      make(map[token.Token]*e.Error),
+	}
+}
+
+// Allow the auth token to upload files with the extension up to maxSize Kbytes.
+func (m *MemoryAuth) AllowUpload(authToken token.Token, fileType file.FileExtension, maxSize uint64) *MemoryAuth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.uploads[authToken] == nil {
+		m.uploads[authToken] = make(map[file.FileExtension]uint64)
+	}
+	m.uploads[authToken][fileType] = maxSize
+	return m
+}
+
+// Allow the auth token to download the files.
+func (m *MemoryAuth) AllowDownload(authToken token.Token, objectTokens ...token.Token) *MemoryAuth {
+	return m.allow(m.downloads, authToken, objectTokens)
+}
+
+// Allow the auth token to delete the files.
+func (m *MemoryAuth) AllowDelete(authToken token.Token, objectTokens ...token.Token) *MemoryAuth {
+	return m.allow(m.deletes, authToken, objectTokens)
+}
+
+// Allow the auth token to copy the files to any destination.
+func (m *MemoryAuth) AllowCopy(authToken token.Token, objectTokens ...token.Token) *MemoryAuth {
+	return m.allow(m.copies, authToken, objectTokens)
+}
+
+// Allow the auth token to move the files to any destination.
+func (m *MemoryAuth) AllowMove(authToken token.Token, objectTokens ...token.Token) *MemoryAuth {
+	return m.allow(m.moves, authToken, objectTokens)
+}
+
+func (m *MemoryAuth) allow(permissions map[token.Token]map[token.Token]bool, authToken token.Token,
+	objectTokens []token.Token) *MemoryAuth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if permissions[authToken] == nil {
+		permissions[authToken] = make(map[token.Token]bool)
+	}
+	for _, t := range objectTokens {
+		permissions[authToken][t] = true
+	}
+	return m
+}
+
+// Set ID of the user of the auth token.
+func (m *MemoryAuth) SetUserID(authToken token.Token, userID string) *MemoryAuth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.userIDs[authToken] = userID
+	return m
+}
+
+// Return the error for all queries of the auth token. (e.g. an error with ErrForbidden
+// code) Passing nil removes the error.
+func (m *MemoryAuth) SetError(authToken token.Token, err *e.Error) *MemoryAuth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		delete(m.errs, authToken)
+	} else {
+		m.errs[authToken] = err
+	}
+	return m
+}
+
+// Return the error of the auth token if it's set or it's unknown.
+func (m *MemoryAuth) checkToken(authToken token.Token) *e.Error {
+	if err, ok := m.errs[authToken]; ok {
+		copied := *err
+		return &copied
+	}
+	_, canUpload := m.uploads[authToken]
+	_, canDownload := m.downloads[authToken]
+	_, canDelete := m.deletes[authToken]
+	_, canCopy := m.copies[authToken]
+	_, canMove := m.moves[authToken]
+	_, hasID := m.userIDs[authToken]
+	if !canUpload && !canDownload && !canDelete && !canCopy && !canMove && !hasID {
+		return e.NewErrorP("There's not any matched user with this auth token", ErrUnauthorized)
+	}
+	return nil
+}
+
+func (m *MemoryAuth) IsAllowedDownload(accessInfo DownloadAccessReq) (allowDownload, *e.Error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.checkToken(accessInfo.AuthToken); err != nil {
+		return nil, err
+	}
+	allowDownload := make(allowDownload)
+	for _, t := range accessInfo.ObjectTokens {
+		allowDownload[t] = m.downloads[accessInfo.AuthToken][t]
+	}
+	return allowDownload, nil
+}
+
+func (m *MemoryAuth) IsAllowedUpload(accessInfo UploadAccessReq) ([]allowType, *e.Error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.checkToken(accessInfo.AuthToken); err != nil {
+		return nil, err
+	}
+	allowTypes := make([]allowType, 0, len(accessInfo.ObjectTypes))
+	for fe := range accessInfo.ObjectTypes {
+		maxSize, ok := m.uploads[accessInfo.AuthToken][fe]
+		allowTypes = append(allowTypes, allowType{
+			FileType: fe,
+			IsAllow:  ok,
+			MaxSize:  maxSize,
+		})
+	}
+	return allowTypes, nil
+}
+
+func (m *MemoryAuth) IsAllowedDelete(accessInfo DeleteAccessReq) (allowDelete, *e.Error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.checkToken(accessInfo.AuthToken); err != nil {
+		return nil, err
+	}
+	allowDelete := make(allowDelete)
+	for _, t := range accessInfo.ObjectTokens {
+		allowDelete[t] = m.deletes[accessInfo.AuthToken][t]
+	}
+	return allowDelete, nil
+}
+
+func (m *MemoryAuth) IsAllowedCopy(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	return m.isAllowedTransfer(m.copies, accessInfo)
+}
+
+func (m *MemoryAuth) IsAllowedMove(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	return m.isAllowedTransfer(m.moves, accessInfo)
+}
+
+func (m *MemoryAuth) isAllowedTransfer(permissions map[token.Token]map[token.Token]bool,
+	accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.checkToken(accessInfo.AuthToken); err != nil {
+		return nil, err
+	}
+	allowTransfer := make(allowTransfer, 0, len(accessInfo.Objects))
+	for _, object := range accessInfo.Objects {
+		allowTransfer = append(allowTransfer, permissions[accessInfo.AuthToken][object.Source])
+	}
+	return allowTransfer, nil
+}
+
+func (m *MemoryAuth) Identify(authToken token.Token) (string, *e.Error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.checkToken(authToken); err != nil {
+		return "", err
+	}
+	if userID, ok := m.userIDs[authToken]; ok {
+		return userID, nil
+	}
+	return authToken.String(), nil
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+)
+
+func TestMemoryAuthDownload(t *testing.T) {
+	a := NewMemoryAuth().AllowDownload("alice", "a.pdf", "b.pdf").AllowDownload("bob", "c.pdf")
+	tests := []struct {
+		name      string
+		authToken token.Token
+		want      allowDownload
+	}{
+		{"allowed files", "alice", allowDownload{"a.pdf": true, "b.pdf": true, "c.pdf": false}},
+		{"files of another token", "bob", allowDownload{"a.pdf": false, "b.pdf": false, "c.pdf": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.IsAllowedDownload(DownloadAccessReq{tt.authToken, []token.Token{"a.pdf", "b.pdf", "c.pdf"}})
+			if err != nil {
+				t.Fatalf("IsAllowedDownload() error = %v", err)
+			}
+			for objectToken, want := range tt.want {
+				if got[objectToken] != want {
+					t.Errorf("IsAllowedDownload()[%s] = %v, want %v", objectToken, got[objectToken], want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryAuthUpload(t *testing.T) {
+	a := NewMemoryAuth().AllowUpload("alice", "pdf", 1024).AllowUpload("alice", "png", 10)
+	got, err := a.IsAllowedUpload(UploadAccessReq{"alice", map[file.FileExtension]uint{"pdf": 1, "png": 2, "exe": 1}})
+	if err != nil {
+		t.Fatalf("IsAllowedUpload() error = %v", err)
+	}
+	want := map[file.FileExtension]allowType{
+		"pdf": {FileType: "pdf", IsAllow: true, MaxSize: 1024},
+		"png": {FileType: "png", IsAllow: true, MaxSize: 10},
+		"exe": {FileType: "exe", IsAllow: false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("IsAllowedUpload() returned %d types, want %d", len(got), len(want))
+	}
+	for _, allowType := range got {
+		if allowType != want[allowType.FileType] {
+			t.Errorf("IsAllowedUpload() = %+v, want %+v", allowType, want[allowType.FileType])
+		}
+	}
+}
+
+func TestMemoryAuthDelete(t *testing.T) {
+	a := NewMemoryAuth().AllowDownload("alice", "a.pdf", "b.pdf").AllowDelete("alice", "a.pdf").AllowDelete("bob", "b.pdf")
+	tests := []struct {
+		name      string
+		authToken token.Token
+		want      allowDelete
+	}{
+		// Downloading a file doesn't allow deleting it
+		{"allowed files", "alice", allowDelete{"a.pdf": true, "b.pdf": false}},
+		{"files of another token", "bob", allowDelete{"a.pdf": false, "b.pdf": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.IsAllowedDelete(DeleteAccessReq{tt.authToken, []token.Token{"a.pdf", "b.pdf"}})
+			if err != nil {
+				t.Fatalf("IsAllowedDelete() error = %v", err)
+			}
+			for objectToken, want := range tt.want {
+				if got[objectToken] != want {
+					t.Errorf("IsAllowedDelete()[%s] = %v, want %v", objectToken, got[objectToken], want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryAuthTransfer(t *testing.T) {
+	a := NewMemoryAuth().AllowCopy("alice", "a.pdf", "b.pdf").AllowMove("alice", "a.pdf")
+	objects := []ObjectTransfer{{"a.pdf", "x.pdf"}, {"b.pdf", "y.pdf"}, {"c.pdf", "z.pdf"}}
+	copies, err := a.IsAllowedCopy(TransferAccessReq{"alice", objects})
+	if err != nil {
+		t.Fatalf("IsAllowedCopy() error = %v", err)
+	}
+	moves, err := a.IsAllowedMove(TransferAccessReq{"alice", objects})
+	if err != nil {
+		t.Fatalf("IsAllowedMove() error = %v", err)
+	}
+	if !slices.Equal(copies, allowTransfer{true, true, false}) {
+		t.Errorf("IsAllowedCopy() = %v, want the allowed sources in order", copies)
+	}
+	if !slices.Equal(moves, allowTransfer{true, false, false}) {
+		t.Errorf("IsAllowedMove() = %v, want the allowed sources in order", moves)
+	}
+}
+
+func TestMemoryAuthIdentify(t *testing.T) {
+	a := NewMemoryAuth().AllowDownload("alice", "a.pdf").SetUserID("bob", "user-2")
+	tests := []struct {
+		authToken token.Token
+		want      string
+		wantErr   bool
+	}{
+		// The auth token itself is the ID if it's not set
+		{"alice", "alice", false},
+		{"bob", "user-2", false},
+		{"unknown", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.authToken.String(), func(t *testing.T) {
+			got, err := a.Identify(tt.authToken)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Identify() = %q, %v, want %q, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryAuthErrors(t *testing.T) {
+	a := NewMemoryAuth().AllowDownload("alice", "a.pdf").
+		SetError("disabled", e.NewErrorP("user is disabled", ErrForbidden)).
+		SetError("alice", e.NewErrorP("auth service failed", ErrInternal)).
+		SetError("alice", nil)
+
+	tests := []struct {
+		name      string
+		authToken token.Token
+		wantErr   bool
+		wantCode  e.Code
+	}{
+		{"known token", "alice", false, 0},
+		{"unknown token", "unknown", true, ErrUnauthorized},
+		{"token with error", "disabled", true, ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, downloadErr := a.IsAllowedDownload(DownloadAccessReq{tt.authToken, []token.Token{"a.pdf"}})
+			_, uploadErr := a.IsAllowedUpload(UploadAccessReq{tt.authToken, map[file.FileExtension]uint{"pdf": 1}})
+			_, deleteErr := a.IsAllowedDelete(DeleteAccessReq{tt.authToken, []token.Token{"a.pdf"}})
+			for _, err := range []*e.Error{downloadErr, uploadErr, deleteErr} {
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if err != nil && err.GetCode() != tt.wantCode {
+					t.Errorf("error code = %v, want %v", err.GetCode(), tt.wantCode)
+				}
+			}
+		})
+	}
+}
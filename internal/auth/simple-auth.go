@@ -10,7 +10,7 @@ import (
 	l "github.com/q-sharafian/file-transfer/pkg/logger"
 	pbAuth "github.com/q-sharafian/file-transfer/pkg/pb/auth"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 // This authentication method create a gRPC connection to the auth server and
@@ -22,11 +22,34 @@ type simpleAuth struct {
 	logger       l.Logger
 }
 
-// Connect to the authentication server to query whether an auth query is allowed or not.
-// Any query time must be less than maxQueryTime or it will be rejected.
-func NewSimpleAuth(serverAddr string, maxQueryTime time.Duration, logger l.Logger) Auth {
-	logger.Infof("Connecting to gRPC server with address %s", serverAddr)
-	conn, err := grpc.NewClient(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// Security settings of the connection to the auth server
+type ConnSecurity struct {
+	// If it's false, the connection isn't encrypted and the other settings are ignored.
+	TLS bool
+	// PEM file of the CA certificates that the server certificate is verified with them.
+	// If it's empty, CAs of the system are used.
+	CAFile string
+	// PEM files of the client certificate and its key for mutual TLS. (Optional)
+	CertFile string
+	KeyFile  string
+	// The server certificate is verified with this name instead of the host of the address.
+	ServerName string
+	// A shared secret that is sent in "authorization" metadata of each call as a bearer token.
+	BearerToken string
+}
+
+// Connect to the authentication servers to query whether an auth query is allowed or not.
+// Queries are spread over the servers. Any query time must be less than maxQueryTime
+// or it will be rejected.
+func NewSimpleAuth(serverAddrs []string, maxQueryTime time.Duration, security ConnSecurity,
+	resilience ConnResilience, logger l.Logger) Auth {
+	logger.Infof("Connecting to gRPC servers with addresses %v", serverAddrs)
+	options, err := dialOptions(security)
+	if err != nil {
+		logger.Panicf("Invalid security settings of auth server: %s", err.Error())
+	}
+	target, resilienceOpts := resilienceOptions(serverAddrs, resilience)
+	conn, err := grpc.NewClient(target, append(options, resilienceOpts...)...)
 	if err != nil {
 		logger.Panicf("Failed to create gRPC connection to auth server: %s", err.Error())
 	}
@@ -48,7 +71,7 @@ func (s *simpleAuth) IsAllowedDownload(accessInfo DownloadAccessReq) (allowDownl
 		ObjectTokens: objectTokens,
 	})
 	if err != nil {
-		return nil, e.NewErrorP("Failed to check download access privileges: %s", ErrInternal, err.Error())
+		return nil, e.Wrap(err, "Failed to check download access privileges", callErrCode(err))
 	}
 	switch result.GetStatusCode() {
 	case pbAuth.StatusCode_ErrForbidden:
@@ -82,7 +105,7 @@ func (s *simpleAuth) IsAllowedUpload(accessInfo UploadAccessReq) ([]allowType, *
 		ObjectTypes: fileTypes,
 	})
 	if err != nil {
-		return nil, e.NewErrorP("failed to check upload access privileges: %s", ErrInternal, err.Error())
+		return nil, e.Wrap(err, "failed to check upload access privileges", callErrCode(err))
 	}
 	switch result.GetStatusCode() {
 	case pbAuth.StatusCode_ErrForbidden:
@@ -107,6 +130,111 @@ func (s *simpleAuth) IsAllowedUpload(accessInfo UploadAccessReq) ([]allowType, *
 	}
 }
 
+func (s *simpleAuth) IsAllowedDelete(accessInfo DeleteAccessReq) (allowDelete, *e.Error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.maxQueryTime)
+	defer cancel()
+	objectTokens := tokens2Strings(accessInfo.ObjectTokens)
+
+	result, err := s.authClient.IsAllowedDelete(ctx, &pbAuth.DeleteAccessReq{
+		AuthToken:    accessInfo.AuthToken.String(),
+		ObjectTokens: objectTokens,
+	})
+	if err != nil {
+		return nil, e.Wrap(err, "Failed to check delete access privileges", callErrCode(err))
+	}
+	switch result.GetStatusCode() {
+	case pbAuth.StatusCode_ErrForbidden:
+		return nil, e.NewErrorP("Delete access is forbidden for this specified user: %s", ErrForbidden, result.GetErrmsg())
+	case pbAuth.StatusCode_ErrUnauthorized:
+		return nil, e.NewErrorP("There's not any matched user with this auth token: %s", ErrUnauthorized, result.GetErrmsg())
+	case pbAuth.StatusCode_ErrInternal:
+		return nil, e.NewErrorP("Failed to check delete access privileges: %s", ErrInternal, result.GetErrmsg())
+	case pbAuth.StatusCode_OK:
+		allowDelete := make(allowDelete)
+		for k, v := range result.GetFiles() {
+			allowDelete[token.Token(k)] = v
+		}
+		return allowDelete, nil
+	default:
+		s.logger.Panicf("Unknown status code %d: %s", result.GetStatusCode(), result.GetErrmsg())
+		return nil, nil
+	}
+}
+
+func (s *simpleAuth) IsAllowedCopy(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	return s.isAllowedTransfer("copy", s.authClient.IsAllowedCopy, accessInfo)
+}
+
+func (s *simpleAuth) IsAllowedMove(accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	return s.isAllowedTransfer("move", s.authClient.IsAllowedMove, accessInfo)
+}
+
+// Check the transfers by the RPC. Copying and moving have the same messages.
+func (s *simpleAuth) isAllowedTransfer(action string,
+	rpc func(context.Context, *pbAuth.TransferAccessReq, ...grpc.CallOption) (*pbAuth.AllowTransferResult, error),
+	accessInfo TransferAccessReq) (allowTransfer, *e.Error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.maxQueryTime)
+	defer cancel()
+	objects := make([]*pbAuth.ObjectTransfer, 0, len(accessInfo.Objects))
+	for _, object := range accessInfo.Objects {
+		objects = append(objects, &pbAuth.ObjectTransfer{
+			Source:      object.Source.String(),
+			Destination: object.Destination.String(),
+		})
+	}
+
+	result, err := rpc(ctx, &pbAuth.TransferAccessReq{
+		AuthToken: accessInfo.AuthToken.String(),
+		Objects:   objects,
+	})
+	if err != nil {
+		return nil, e.Wrap(err, "Failed to check %s access privileges", callErrCode(err), action)
+	}
+	switch result.GetStatusCode() {
+	case pbAuth.StatusCode_ErrForbidden:
+		return nil, e.NewErrorP("%s access is forbidden for this specified user: %s", ErrForbidden, action, result.GetErrmsg())
+	case pbAuth.StatusCode_ErrUnauthorized:
+		return nil, e.NewErrorP("There's not any matched user with this auth token: %s", ErrUnauthorized, result.GetErrmsg())
+	case pbAuth.StatusCode_ErrInternal:
+		return nil, e.NewErrorP("Failed to check %s access privileges: %s", ErrInternal, action, result.GetErrmsg())
+	case pbAuth.StatusCode_OK:
+		if len(result.GetAllowed()) != len(accessInfo.Objects) {
+			return nil, e.NewErrorP("Failed to check %s access privileges: auth server returned %d results for %d files",
+				ErrInternal, action, len(result.GetAllowed()), len(accessInfo.Objects))
+		}
+		return allowTransfer(result.GetAllowed()), nil
+	default:
+		s.logger.Panicf("Unknown status code %d: %s", result.GetStatusCode(), result.GetErrmsg())
+		return nil, nil
+	}
+}
+
+func (s *simpleAuth) Identify(authToken token.Token) (string, *e.Error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.maxQueryTime)
+	defer cancel()
+
+	result, err := s.authClient.Identify(ctx, &pbAuth.IdentifyReq{AuthToken: authToken.String()})
+	if err != nil {
+		return "", e.Wrap(err, "Failed to identify the user", callErrCode(err))
+	}
+	switch result.GetStatusCode() {
+	case pbAuth.StatusCode_ErrForbidden:
+		return "", e.NewErrorP("The user is forbidden: %s", ErrForbidden, result.GetErrmsg())
+	case pbAuth.StatusCode_ErrUnauthorized:
+		return "", e.NewErrorP("There's not any matched user with this auth token: %s", ErrUnauthorized, result.GetErrmsg())
+	case pbAuth.StatusCode_ErrInternal:
+		return "", e.NewErrorP("Failed to identify the user: %s", ErrInternal, result.GetErrmsg())
+	case pbAuth.StatusCode_OK:
+		if !isValidUserID(result.GetUserID()) {
+			return "", e.NewErrorP("Failed to identify the user: invalid user ID %q", ErrInternal, result.GetUserID())
+		}
+		return result.GetUserID(), nil
+	default:
+		s.logger.Panicf("Unknown status code %d: %s", result.GetStatusCode(), result.GetErrmsg())
+		return "", nil
+	}
+}
+
 func tokens2Strings(tokens []token.Token) []string {
 	var strs []string
 	for _, token := range tokens {
@@ -114,3 +242,17 @@ func tokens2Strings(tokens []token.Token) []string {
 	}
 	return strs
 }
+
+// Code of a failed call. Failures of the auth servers are internal errors, except
+// outages that the query could be retried after them and queries the server doesn't
+// implement. (e.g. Identify on old auth servers)
+func callErrCode(err error) e.Code {
+	switch e.CodeFromGRPC(status.Code(err)) {
+	case e.Unavailable, e.Timeout:
+		return ErrUnavailable
+	case e.NotImplemented:
+		return ErrNotImplemented
+	default:
+		return ErrInternal
+	}
+}
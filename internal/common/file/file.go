@@ -1,8 +1,44 @@
 package file
 
+import (
+	"path"
+	"strings"
+)
+
 // Another its name is file type
 type FileExtension string
 
 func (f FileExtension) String() string {
 	return string(f)
 }
+
+// Return extension of the file name. Everything after the first dot of the base name
+// is the extension, so extension of "a/b.tar.gz" is "tar.gz".
+func ExtensionOf(fileName string) FileExtension {
+	_, ext, _ := strings.Cut(path.Base(fileName), ".")
+	return FileExtension(ext)
+}
+
+// Whether the key matches any of the patterns. Patterns are like path.Match, but a
+// pattern that ends with "/**" matches all the files under its directory (e.g. "alice/**")
+// and "**" matches all the files.
+func MatchKey(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if pattern == "**" {
+			if path.Clean(key) == key && key != ".." && !strings.HasPrefix(key, "../") {
+				return true
+			}
+			continue
+		}
+		if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if strings.HasPrefix(key, dir+"/") && path.Clean(key) == key {
+				return true
+			}
+			continue
+		}
+		if matched, err := path.Match(pattern, key); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
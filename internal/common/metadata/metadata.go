@@ -1,9 +1,15 @@
 package metadata
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/q-sharafian/file-transfer/internal/common/file"
 	"github.com/q-sharafian/file-transfer/internal/common/token"
 )
 
@@ -14,37 +20,148 @@ const (
 	// Real name of the file without any extension
 	fileRealName = "RealName"
 	uploadedAt   = "UploadedAt"
-	// token represents the user who uploaded the file. (e.g. auth token)
-	uploadedBy = "CreatedBy"
-	// token represents the user who downloaded the file
-	downloadedBy = "DownloadedBy"
-	// Time the file is downloaded
-	downloadedAt = "DownloadedAt"
+	// ID of the user who uploaded the file. It isn't set if the auth service couldn't
+	// identify the user.
+	uploadedBy = "UploaderID"
+	// SHA-256 of the auth token of the uploader in hex. It's set just if ID of the user
+	// isn't known, so the uploader could be recognized without storing its token.
+	uploaderTokenHash = "CreatedByToken"
+	// Prefix of the keys of labels that the user assigns to the file
+	labelPrefix = "Label-"
+	// Maximum size of all metadata keys and values in bytes. (It's the S3 limit)
+	maxMetadataSize = 2048
 )
 
-type RequiredDownloadMetadata struct {
-	DownloadedBy token.Token
-}
-
-// Remove all metadata, except the one that is needed to download the file
-func (m *Metadata) PrepareDownloadMetadata(downloadBy token.Token) {
+// Remove all metadata, except the ones that clients could read. (i.e. real name,
+// upload time, uploader and labels)
+func (m *Metadata) PrepareReadMetadata() {
 	newMetadata := Metadata{}
 
-	newMetadata[fileRealName] = (*m)[fileRealName]
-	newMetadata[uploadedAt] = (*m)[uploadedAt]
-	newMetadata[uploadedBy] = (*m)[uploadedBy]
-	newMetadata[downloadedBy] = downloadBy.String()
-	newMetadata[downloadedAt] = fmt.Sprintf("%d", time.Now().UTC().Unix())
+	for _, key := range []string{fileRealName, uploadedAt, uploadedBy} {
+		if v := m.Get(key); v != "" {
+			newMetadata[key] = v
+		}
+	}
+	for k, v := range m.Labels() {
+		newMetadata[labelPrefix+k] = url.PathEscape(v)
+	}
 
 	*m = newMetadata
 }
 
-func (m *Metadata) PrepareUploadMetadata(uploadBy token.Token, realFileName string) {
+// Create metadata of a file that the user with userID uploads it by the auth token. If
+// ID of the user isn't known, userID is empty and a hash of the token is kept instead.
+func (m *Metadata) PrepareUploadMetadata(userID string, uploadBy token.Token, realFileName string) {
 	newMetadata := Metadata{}
 
-	newMetadata[fileRealName] = realFileName
+	if realFileName != "" {
+		newMetadata[fileRealName] = url.PathEscape(realFileName)
+	}
 	newMetadata[uploadedAt] = fmt.Sprintf("%d", time.Now().UTC().Unix())
-	newMetadata[uploadedBy] = uploadBy.String()
+	if userID != "" {
+		newMetadata[uploadedBy] = userID
+	} else {
+		newMetadata[uploaderTokenHash] = hashToken(uploadBy)
+	}
 
 	*m = newMetadata
 }
+
+// Return value of the key. Keys are case-insensitive, because some storages (e.g. S3)
+// change case of metadata keys.
+func (m Metadata) Get(key string) string {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// Return ID of the user who uploaded the file. It's empty if it's unknown.
+func (m Metadata) UploadedBy() string {
+	return m.Get(uploadedBy)
+}
+
+// Return whether the file is uploaded by the user with userID or, if ID of the uploader
+// isn't known, by the auth token.
+func (m Metadata) IsUploadedBy(userID string, authToken token.Token) bool {
+	if uploader := m.UploadedBy(); uploader != "" {
+		return uploader == userID
+	}
+	tokenHash := m.Get(uploaderTokenHash)
+	return tokenHash != "" && tokenHash == hashToken(authToken)
+}
+
+func hashToken(authToken token.Token) string {
+	sum := sha256.Sum256([]byte(authToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// Return time the file is uploaded. It's zero if it's unknown.
+func (m Metadata) UploadedAt() time.Time {
+	sec, err := strconv.ParseInt(m.Get(uploadedAt), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+// Return real name of the file without any extension.
+func (m Metadata) RealName() string {
+	return unescapeValue(m.Get(fileRealName))
+}
+
+// Return real name of the file with the extension. It's empty if it's unknown.
+func (m Metadata) RealFileName(fileType file.FileExtension) string {
+	realName := m.RealName()
+	if realName == "" || fileType == "" {
+		return realName
+	}
+	return realName + "." + fileType.String()
+}
+
+// Add the labels to the metadata. Label keys are case-insensitive and could contain
+// just English letters, digits, "-" and "_".
+func (m *Metadata) AddLabels(labels map[string]string) error {
+	if *m == nil {
+		*m = Metadata{}
+	}
+	for k, v := range labels {
+		if k == "" || strings.TrimLeft(strings.ToLower(k), "abcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+			return fmt.Errorf("label key %q is invalid. It could contain just English letters, digits, \"-\" and \"_\"", k)
+		}
+		(*m)[labelPrefix+strings.ToLower(k)] = url.PathEscape(v)
+	}
+	size := 0
+	for k, v := range *m {
+		size += len(k) + len(v)
+	}
+	if size > maxMetadataSize {
+		return fmt.Errorf("size of the labels and file name is more than %d bytes", maxMetadataSize)
+	}
+	return nil
+}
+
+// Return labels that the user assigned to the file.
+func (m Metadata) Labels() map[string]string {
+	labels := make(map[string]string)
+	for k, v := range m {
+		if len(k) > len(labelPrefix) && strings.EqualFold(k[:len(labelPrefix)], labelPrefix) {
+			labels[strings.ToLower(k[len(labelPrefix):])] = unescapeValue(v)
+		}
+	}
+	return labels
+}
+
+// Values that users specify are escaped, because metadata are sent as HTTP headers
+// that couldn't contain any non-ASCII characters.
+func unescapeValue(value string) string {
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
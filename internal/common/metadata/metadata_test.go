@@ -0,0 +1,115 @@
+package metadata
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+)
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"no labels", nil, map[string]string{}, false},
+		{"keys are lowercased", map[string]string{"Project-ID": "12", "year_2": "x"},
+			map[string]string{"project-id": "12", "year_2": "x"}, false},
+		{"non-ASCII value", map[string]string{"city": "تهران / Tehran"}, map[string]string{"city": "تهران / Tehran"}, false},
+		{"empty key", map[string]string{"": "x"}, nil, true},
+		{"invalid key", map[string]string{"a b": "x"}, nil, true},
+		{"non-ASCII key", map[string]string{"شهر": "x"}, nil, true},
+		{"too large", map[string]string{"a": strings.Repeat("x", maxMetadataSize)}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Metadata
+			m.PrepareUploadMetadata("alice", "alice", "report")
+			err := m.AddLabels(tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddLabels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// Metadata are sent as HTTP headers, so they must be printable ASCII
+			for k, v := range m {
+				for _, c := range []byte(k + v) {
+					if c < ' ' || c > '~' {
+						t.Errorf("metadata %s = %q could not be sent as a header", k, v)
+						break
+					}
+				}
+			}
+			if got := m.Labels(); !maps.Equal(got, tt.want) {
+				t.Errorf("Labels() = %v, want %v", got, tt.want)
+			}
+			if m.RealName() != "report" || m.UploadedBy() != "alice" {
+				t.Errorf("RealName() = %q, UploadedBy() = %q", m.RealName(), m.UploadedBy())
+			}
+		})
+	}
+}
+
+func TestRealName(t *testing.T) {
+	var m Metadata
+	m.PrepareUploadMetadata("alice", "alice", "گزارش 100%")
+	if got := m.RealName(); got != "گزارش 100%" {
+		t.Errorf("RealName() = %q, want the given name", got)
+	}
+	// Keys of S3 metadata are lowercased
+	if got := (Metadata{"realname": "a%20b", "label-x": "y"}).RealName(); got != "a b" {
+		t.Errorf("RealName() of lowercase keys = %q, want %q", got, "a b")
+	}
+}
+
+func TestPrepareReadMetadata(t *testing.T) {
+	var m Metadata
+	m.PrepareUploadMetadata("alice", "alice", "report")
+	if err := m.AddLabels(map[string]string{"project": "x"}); err != nil {
+		t.Fatalf("AddLabels() error = %v", err)
+	}
+	m["Internal"] = "secret"
+	m.PrepareReadMetadata()
+	if _, ok := m["Internal"]; ok || len(m) != 4 {
+		t.Errorf("PrepareReadMetadata() = %v, want just the name, upload time, uploader and labels", m)
+	}
+	if m.RealName() != "report" || m.UploadedBy() != "alice" || m.UploadedAt().IsZero() || m.Labels()["project"] != "x" {
+		t.Errorf("PrepareReadMetadata() = %v, want the readable metadata unchanged", m)
+	}
+}
+
+func TestIsUploadedBy(t *testing.T) {
+	var known, unknown Metadata
+	known.PrepareUploadMetadata("alice", "alice-token", "")
+	unknown.PrepareUploadMetadata("", "alice-token", "")
+	if got := unknown.Get("CreatedByToken"); got == "" || strings.Contains(got, "alice-token") {
+		t.Errorf("uploader token hash = %q, want a hash of the token", got)
+	}
+
+	tests := []struct {
+		name      string
+		md        Metadata
+		userID    string
+		authToken token.Token
+		want      bool
+	}{
+		{"same user", known, "alice", "alice-token", true},
+		{"another token of the user", known, "alice", "new-token", true},
+		{"another user with the token", known, "bob", "alice-token", false},
+		{"same token of an unknown user", unknown, "", "alice-token", true},
+		{"another token of an unknown user", unknown, "", "bob-token", false},
+		{"no uploader", Metadata{}, "", "", false},
+		{"raw token of the uploader", Metadata{"CreatedBy": "alice-token"}, "", "alice-token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.md.IsUploadedBy(tt.userID, tt.authToken); got != tt.want {
+				t.Errorf("IsUploadedBy(%q, %q) = %v, want %v", tt.userID, tt.authToken, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Names of the files in the storage are created from a template, so files could be
+grouped by prefixes. (e.g. to apply lifecycle rules or access policies of the storage)
+*/
+package objectkey
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+)
+
+// Placeholders that could be used in the templates
+const (
+	// ID of the user that uploads the file
+	userPlaceholder = "{user}"
+	// A fixed prefix that is set by the configuration
+	tenantPlaceholder = "{tenant}"
+	// Upload date in UTC
+	yearPlaceholder  = "{yyyy}"
+	monthPlaceholder = "{mm}"
+	dayPlaceholder   = "{dd}"
+	uuidPlaceholder  = "{uuid}"
+	// SHA-256 hash of the content of the file in hex. Its client must send it before uploading.
+	sha256Placeholder = "{sha256}"
+	extPlaceholder    = "{ext}"
+)
+
+// Default template. It's the layout of the files before templates were added and
+// doesn't need Identify of the auth service. Files of each user could be stored under
+// its ID by "{user}/{uuid}.{ext}".
+const DefaultTemplate = uuidPlaceholder + "." + extPlaceholder
+
+var placeholderRegex = regexp.MustCompile(`\{[a-z0-9]+\}`)
+
+type Template struct {
+	pattern string
+	tenant  string
+}
+
+// Details of a file that its name is created for it
+type KeyInfo struct {
+	UserID   string
+	FileType file.FileExtension
+	// SHA-256 checksum of the file in base64. It's needed just if the template contains {sha256}.
+	ChecksumSHA256 string
+	UploadedAt     time.Time
+}
+
+// Create a template like "{tenant}/{user}/{yyyy}/{mm}/{uuid}.{ext}". The last segment
+// of the template must end with ".{ext}" and contain {uuid} or {sha256} to make names
+// unique. It couldn't contain any other dot, because everything after the first dot of
+// the name is its extension.
+func NewTemplate(pattern, tenant string) (*Template, error) {
+	for _, placeholder := range placeholderRegex.FindAllString(pattern, -1) {
+		switch placeholder {
+		case userPlaceholder, tenantPlaceholder, yearPlaceholder, monthPlaceholder, dayPlaceholder,
+			uuidPlaceholder, sha256Placeholder, extPlaceholder:
+		default:
+			return nil, fmt.Errorf("unknown placeholder %s in key template %s", placeholder, pattern)
+		}
+	}
+	segments := strings.Split(pattern, "/")
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("key template %s has an empty or relative segment", pattern)
+		}
+	}
+	last := segments[len(segments)-1]
+	if !strings.HasSuffix(last, "."+extPlaceholder) || strings.Count(last, ".") != 1 {
+		return nil, fmt.Errorf("last segment of key template %s must end with .%s and contain no other dot", pattern, extPlaceholder)
+	}
+	if !strings.Contains(last, uuidPlaceholder) && !strings.Contains(last, sha256Placeholder) {
+		return nil, fmt.Errorf("last segment of key template %s must contain %s or %s", pattern, uuidPlaceholder, sha256Placeholder)
+	}
+	if strings.Contains(last, userPlaceholder) || strings.Contains(last, tenantPlaceholder) {
+		return nil, fmt.Errorf("last segment of key template %s couldn't contain %s or %s", pattern, userPlaceholder, tenantPlaceholder)
+	}
+	if strings.Contains(pattern, tenantPlaceholder) && (tenant == "" || strings.ContainsAny(tenant, "/\\.")) {
+		return nil, fmt.Errorf("tenant of key template %s must be set and couldn't contain any slash, backslash or dot", pattern)
+	}
+	return &Template{pattern, tenant}, nil
+}
+
+// Whether the ID of the uploader is needed to create names
+func (t *Template) NeedsUser() bool {
+	return strings.Contains(t.pattern, userPlaceholder)
+}
+
+// Whether checksum of the files is needed to create their names
+func (t *Template) NeedsChecksum() bool {
+	return strings.Contains(t.pattern, sha256Placeholder)
+}
+
+// Create the name of the file in the storage without its extension.
+func (t *Template) FileName(info KeyInfo) (string, error) {
+	replacements := []string{
+		tenantPlaceholder, t.tenant,
+		yearPlaceholder, info.UploadedAt.UTC().Format("2006"),
+		monthPlaceholder, info.UploadedAt.UTC().Format("01"),
+		dayPlaceholder, info.UploadedAt.UTC().Format("02"),
+		userPlaceholder, info.UserID,
+	}
+	if t.NeedsUser() && info.UserID == "" {
+		return "", fmt.Errorf("ID of the user is needed to create the name of the file")
+	}
+	if strings.Contains(t.pattern, uuidPlaceholder) {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return "", fmt.Errorf("can't create uuid: %s", err.Error())
+		}
+		replacements = append(replacements, uuidPlaceholder, id.String())
+	}
+	if t.NeedsChecksum() {
+		checksum, err := base64.StdEncoding.DecodeString(info.ChecksumSHA256)
+		if err != nil || len(checksum) != 32 {
+			return "", fmt.Errorf("a valid SHA-256 checksum of the file in base64 is needed to create its name")
+		}
+		replacements = append(replacements, sha256Placeholder, hex.EncodeToString(checksum))
+	}
+	name := strings.NewReplacer(replacements...).Replace(t.pattern)
+	return strings.TrimSuffix(name, "."+extPlaceholder), nil
+}
+
+// Return the prefix that names of all files of the user begin with it. It's false if
+// the template doesn't separate files of each user by a directory.
+func (t *Template) UserPrefix(userID string) (string, bool) {
+	index := strings.Index(t.pattern, userPlaceholder+"/")
+	if index < 0 {
+		return "", false
+	}
+	prefix := t.pattern[:index+len(userPlaceholder)+1]
+	// Placeholders before the user (e.g. dates) change, so the prefix couldn't be fixed
+	if strings.Count(prefix, "{") != strings.Count(prefix, tenantPlaceholder)+1 {
+		return "", false
+	}
+	return strings.NewReplacer(tenantPlaceholder, t.tenant, userPlaceholder, userID).Replace(prefix), true
+}
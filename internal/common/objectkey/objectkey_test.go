@@ -0,0 +1,167 @@
+package objectkey
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		tenant  string
+		wantErr bool
+	}{
+		{"default", DefaultTemplate, "", false},
+		{"user directory", "{user}/{uuid}.{ext}", "", false},
+		{"all placeholders", "{tenant}/{user}/{yyyy}/{mm}/{dd}/{sha256}-{uuid}.{ext}", "acme", false},
+		{"checksum name", "{sha256}.{ext}", "", false},
+		{"unknown placeholder", "{team}/{uuid}.{ext}", "", true},
+		{"empty segment", "{user}//{uuid}.{ext}", "", true},
+		{"relative segment", "../{uuid}.{ext}", "", true},
+		{"without extension", "{user}/{uuid}", "", true},
+		{"another dot in the name", "{uuid}.v1.{ext}", "", true},
+		{"name isn't unique", "{user}/file.{ext}", "", true},
+		{"user in the name", "{user}-{uuid}.{ext}", "", true},
+		{"tenant isn't set", "{tenant}/{uuid}.{ext}", "", true},
+		{"tenant with slash", "{tenant}/{uuid}.{ext}", "a/b", true},
+		{"tenant with dot", "{tenant}/{uuid}.{ext}", "..", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTemplate(tt.pattern, tt.tenant)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTemplate(%q, %q) error = %v, wantErr %v", tt.pattern, tt.tenant, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateFileName(t *testing.T) {
+	sum := sha256.Sum256([]byte("content"))
+	checksum := base64.StdEncoding.EncodeToString(sum[:])
+	uploadedAt := time.Date(2024, 3, 7, 23, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	uuidPattern := `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`
+
+	tests := []struct {
+		name    string
+		pattern string
+		tenant  string
+		info    KeyInfo
+		// Regular expression of the name
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "default",
+			pattern: DefaultTemplate,
+			info:    KeyInfo{FileType: "pdf"},
+			want:    uuidPattern,
+		},
+		{
+			name:    "user and date in UTC",
+			pattern: "{tenant}/{user}/{yyyy}/{mm}/{dd}/{uuid}.{ext}",
+			tenant:  "acme",
+			info:    KeyInfo{UserID: "alice", UploadedAt: uploadedAt},
+			want:    `acme/alice/2024/03/07/` + uuidPattern,
+		},
+		{
+			name:    "checksum",
+			pattern: "{user}/{sha256}.{ext}",
+			info:    KeyInfo{UserID: "alice", ChecksumSHA256: checksum},
+			want:    `alice/` + hex.EncodeToString(sum[:]),
+		},
+		{
+			name:    "user isn't known",
+			pattern: "{user}/{uuid}.{ext}",
+			info:    KeyInfo{},
+			wantErr: true,
+		},
+		{
+			name:    "checksum isn't sent",
+			pattern: "{sha256}.{ext}",
+			info:    KeyInfo{},
+			wantErr: true,
+		},
+		{
+			name:    "checksum isn't SHA-256",
+			pattern: "{sha256}.{ext}",
+			info:    KeyInfo{ChecksumSHA256: base64.StdEncoding.EncodeToString([]byte("short"))},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := NewTemplate(tt.pattern, tt.tenant)
+			if err != nil {
+				t.Fatalf("NewTemplate() error = %v", err)
+			}
+			name, err := template.FileName(tt.info)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FileName() = %q, error = %v, wantErr %v", name, err, tt.wantErr)
+			}
+			if !tt.wantErr && !regexp.MustCompile(`^`+tt.want+`$`).MatchString(name) {
+				t.Errorf("FileName() = %q, want it to match %s", name, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateUserPrefix(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		tenant     string
+		wantPrefix string
+		wantOK     bool
+	}{
+		{"user directory", "{user}/{uuid}.{ext}", "", "alice/", true},
+		{"tenant and user", "{tenant}/{user}/{yyyy}/{uuid}.{ext}", "acme", "acme/alice/", true},
+		{"fixed directory", "files/{user}/{uuid}.{ext}", "", "files/alice/", true},
+		{"date before user", "{yyyy}/{user}/{uuid}.{ext}", "", "", false},
+		{"without user", DefaultTemplate, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := NewTemplate(tt.pattern, tt.tenant)
+			if err != nil {
+				t.Fatalf("NewTemplate() error = %v", err)
+			}
+			prefix, ok := template.UserPrefix("alice")
+			if prefix != tt.wantPrefix || ok != tt.wantOK {
+				t.Errorf("UserPrefix() = %q, %v, want %q, %v", prefix, ok, tt.wantPrefix, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTemplateNeeds(t *testing.T) {
+	tests := []struct {
+		pattern      string
+		wantUser     bool
+		wantChecksum bool
+	}{
+		{DefaultTemplate, false, false},
+		{"{user}/{uuid}.{ext}", true, false},
+		{"{sha256}.{ext}", false, true},
+		{"{user}/{sha256}.{ext}", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			template, err := NewTemplate(tt.pattern, "")
+			if err != nil {
+				t.Fatalf("NewTemplate() error = %v", err)
+			}
+			if got := template.NeedsUser(); got != tt.wantUser {
+				t.Errorf("NeedsUser() = %v, want %v", got, tt.wantUser)
+			}
+			if got := template.NeedsChecksum(); got != tt.wantChecksum {
+				t.Errorf("NeedsChecksum() = %v, want %v", got, tt.wantChecksum)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Clients refer to the files by object tokens instead of their keys in the storage, so
+the keys couldn't be guessed and the layout of the storage isn't exposed.
+*/
+package objecttoken
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+)
+
+// Convert keys of the files to the object tokens that clients use and vice versa
+type Codec interface {
+	// Return the token that clients use to refer to the file
+	Encode(key string) (token.Token, error)
+	// Return key of the file that the token refers to. If the token isn't valid,
+	// the returned error wraps ErrInvalidToken.
+	Decode(objectToken token.Token) (string, error)
+}
+
+// It's returned (wrapped) if the token isn't issued by the codec
+var ErrInvalidToken = errors.New("invalid object token")
+
+type rawCodec struct{}
+
+// Keys of the files are used as the object tokens. (It's the old behavior)
+func NewRawCodec() Codec {
+	return rawCodec{}
+}
+
+func (rawCodec) Encode(key string) (token.Token, error) {
+	return token.Token(key), nil
+}
+
+func (rawCodec) Decode(objectToken token.Token) (string, error) {
+	if objectToken == "" {
+		return "", fmt.Errorf("%w: it's empty", ErrInvalidToken)
+	}
+	return objectToken.String(), nil
+}
+
+// A secret key that tokens are signed (and encrypted) with it
+type Key struct {
+	// It's put in the tokens to find their key after rotating keys
+	ID     string
+	Secret []byte
+}
+
+// Minimum size of the secrets in bytes
+const minSecretSize = 32
+
+// Types of the payload of the tokens
+const (
+	plainPayload     byte = 0
+	encryptedPayload byte = 1
+)
+
+var b64 = base64.RawURLEncoding
+
+type signedKey struct {
+	id     string
+	macKey []byte
+	aead   cipher.AEAD
+}
+
+type signedCodec struct {
+	// The first key issues new tokens and all of them are accepted
+	keys    []signedKey
+	encrypt bool
+}
+
+// Create a codec that its tokens are like "<key id>.<payload>.<signature>" and signed by
+// HMAC-SHA256. If encrypt is true, keys of the files are encrypted by AES-GCM too.
+// New tokens are issued by the first key, but tokens of all keys are accepted, so keys
+// could be rotated by adding a new key to the beginning and removing the old one later.
+func NewSignedCodec(keys []Key, encrypt bool) (Codec, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is needed")
+	}
+	codec := &signedCodec{encrypt: encrypt}
+	ids := make(map[string]bool)
+	for _, key := range keys {
+		if key.ID == "" || strings.Trim(key.ID, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+			return nil, fmt.Errorf("key ID %q is invalid. It could contain just English letters, digits, \"-\" and \"_\"", key.ID)
+		}
+		if ids[key.ID] {
+			return nil, fmt.Errorf("key ID %s is repeated", key.ID)
+		}
+		ids[key.ID] = true
+		if len(key.Secret) < minSecretSize {
+			return nil, fmt.Errorf("secret of key %s must be at least %d bytes", key.ID, minSecretSize)
+		}
+		// Separate keys are derived for signing and encrypting
+		block, err := aes.NewCipher(deriveKey(key.Secret, "encrypt"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher of key %s: %s", key.ID, err.Error())
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher of key %s: %s", key.ID, err.Error())
+		}
+		codec.keys = append(codec.keys, signedKey{key.ID, deriveKey(key.Secret, "sign"), aead})
+	}
+	return codec, nil
+}
+
+// Parse keys like "<id>:<secret in base64>,<id>:<secret in base64>".
+func ParseKeys(keys string) ([]Key, error) {
+	var parsed []Key
+	for _, key := range strings.Split(keys, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(key), ":")
+		if !ok {
+			return nil, fmt.Errorf("key %q must be like <id>:<secret in base64>", key)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, fmt.Errorf("secret of key %s isn't in base64: %s", id, err.Error())
+		}
+		parsed = append(parsed, Key{ID: id, Secret: decoded})
+	}
+	return parsed, nil
+}
+
+func (c *signedCodec) Encode(key string) (token.Token, error) {
+	signer := c.keys[0]
+	payload := append([]byte{plainPayload}, key...)
+	if c.encrypt {
+		nonce := make([]byte, signer.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("failed to create nonce: %s", err.Error())
+		}
+		payload = append([]byte{encryptedPayload}, nonce...)
+		payload = signer.aead.Seal(payload, nonce, []byte(key), []byte(signer.id))
+	}
+	signed := signer.id + "." + b64.EncodeToString(payload)
+	return token.Token(signed + "." + b64.EncodeToString(sign(signer.macKey, signed))), nil
+}
+
+func (c *signedCodec) Decode(objectToken token.Token) (string, error) {
+	parts := strings.Split(objectToken.String(), ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: it's malformed", ErrInvalidToken)
+	}
+	var signer *signedKey
+	for i := range c.keys {
+		if c.keys[i].id == parts[0] {
+			signer = &c.keys[i]
+			break
+		}
+	}
+	if signer == nil {
+		return "", fmt.Errorf("%w: key %s is unknown", ErrInvalidToken, parts[0])
+	}
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(signer.macKey, parts[0]+"."+parts[1])) {
+		return "", fmt.Errorf("%w: its signature is wrong", ErrInvalidToken)
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil || len(payload) < 2 {
+		return "", fmt.Errorf("%w: its payload is malformed", ErrInvalidToken)
+	}
+	switch payload[0] {
+	case plainPayload:
+		return string(payload[1:]), nil
+	case encryptedPayload:
+		nonceSize := signer.aead.NonceSize()
+		if len(payload) < 1+nonceSize {
+			return "", fmt.Errorf("%w: its payload is malformed", ErrInvalidToken)
+		}
+		key, err := signer.aead.Open(nil, payload[1:1+nonceSize], payload[1+nonceSize:], []byte(signer.id))
+		if err != nil || len(key) == 0 {
+			return "", fmt.Errorf("%w: failed to decrypt it", ErrInvalidToken)
+		}
+		return string(key), nil
+	default:
+		return "", fmt.Errorf("%w: its payload type is unknown", ErrInvalidToken)
+	}
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	return sign(secret, "object-token-"+purpose)
+}
+
+func sign(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,219 @@
+package objecttoken
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+)
+
+func testKey(id string, fill byte) Key {
+	return Key{ID: id, Secret: bytes.Repeat([]byte{fill}, minSecretSize)}
+}
+
+func mustCodec(t *testing.T, keys []Key, encrypt bool) Codec {
+	t.Helper()
+	codec, err := NewSignedCodec(keys, encrypt)
+	if err != nil {
+		t.Fatalf("NewSignedCodec() error = %v", err)
+	}
+	return codec
+}
+
+func TestSignedCodecRoundTrip(t *testing.T) {
+	keys := []string{"a.pdf", "user-1/2024/01/x.tar.gz", "dir/with spaces/ü.png", "a.b.c"}
+	for _, encrypt := range []bool{false, true} {
+		codec := mustCodec(t, []Key{testKey("k1", 1)}, encrypt)
+		for _, key := range keys {
+			objectToken, err := codec.Encode(key)
+			if err != nil {
+				t.Fatalf("Encode(%q) error = %v", key, err)
+			}
+			if !strings.HasPrefix(objectToken.String(), "k1.") {
+				t.Errorf("Encode(%q) = %q, want the key ID as prefix", key, objectToken)
+			}
+			if encrypt && strings.Contains(objectToken.String(), b64.EncodeToString([]byte(key))) {
+				t.Errorf("Encode(%q) = %q exposes the key", key, objectToken)
+			}
+			got, err := codec.Decode(objectToken)
+			if err != nil {
+				t.Fatalf("Decode(Encode(%q)) error = %v", key, err)
+			}
+			if got != key {
+				t.Errorf("Decode(Encode(%q)) = %q", key, got)
+			}
+		}
+	}
+}
+
+func TestSignedCodecEncryptedTokensDiffer(t *testing.T) {
+	codec := mustCodec(t, []Key{testKey("k1", 1)}, true)
+	first, _ := codec.Encode("a.pdf")
+	second, _ := codec.Encode("a.pdf")
+	if first == second {
+		t.Error("encrypted tokens of the same key must differ by their nonces")
+	}
+}
+
+func TestSignedCodecRotation(t *testing.T) {
+	oldKey, newKey := testKey("old", 1), testKey("new", 2)
+	oldCodec := mustCodec(t, []Key{oldKey}, true)
+	rotated := mustCodec(t, []Key{newKey, oldKey}, true)
+	retired := mustCodec(t, []Key{newKey}, true)
+
+	oldToken, err := oldCodec.Encode("a.pdf")
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	newToken, err := rotated.Encode("a.pdf")
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !strings.HasPrefix(newToken.String(), "new.") {
+		t.Errorf("rotated codec issued %q, want it signed by the first key", newToken)
+	}
+
+	tests := []struct {
+		name        string
+		codec       Codec
+		objectToken token.Token
+		wantErr     bool
+	}{
+		{"old token before rotation", oldCodec, oldToken, false},
+		{"old token during rotation", rotated, oldToken, false},
+		{"new token during rotation", rotated, newToken, false},
+		{"new token after rotation", retired, newToken, false},
+		{"old token after removing its key", retired, oldToken, true},
+		{"new token before rotation", oldCodec, newToken, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.codec.Decode(tt.objectToken)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Decode() = %q, %v, want ErrInvalidToken", key, err)
+				}
+				return
+			}
+			if err != nil || key != "a.pdf" {
+				t.Errorf("Decode() = %q, %v, want %q", key, err, "a.pdf")
+			}
+		})
+	}
+}
+
+func TestSignedCodecRejectsInvalidTokens(t *testing.T) {
+	codec := mustCodec(t, []Key{testKey("k1", 1)}, false)
+	valid, _ := codec.Encode("a.pdf")
+	parts := strings.Split(valid.String(), ".")
+	otherKey, _ := mustCodec(t, []Key{testKey("k1", 9)}, false).Encode("a.pdf")
+	forgedPayload := parts[0] + "." + b64.EncodeToString(append([]byte{plainPayload}, "b.pdf"...)) + "." + parts[2]
+	encrypted, _ := mustCodec(t, []Key{testKey("k1", 1)}, true).Encode("a.pdf")
+	encryptedParts := strings.Split(encrypted.String(), ".")
+
+	tests := []struct {
+		name        string
+		objectToken token.Token
+	}{
+		{"empty", ""},
+		{"raw key", "a.pdf"},
+		{"too many parts", valid + ".x"},
+		{"unknown key", token.Token("k2." + parts[1] + "." + parts[2])},
+		{"signed by another secret", otherKey},
+		{"changed payload", token.Token(forgedPayload)},
+		{"signature isn't base64", token.Token(parts[0] + "." + parts[1] + ".!!!")},
+		{"truncated signature", token.Token(parts[0] + "." + parts[1] + "." + parts[2][:10])},
+		// Its signature is valid, but it couldn't be decrypted.
+		{"encrypted with wrong nonce", resign(t, encryptedParts[0], flipByte(encryptedParts[1], 2))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := codec.Decode(tt.objectToken)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Decode(%q) = %q, %v, want ErrInvalidToken", tt.objectToken, key, err)
+			}
+		})
+	}
+}
+
+// Sign the payload by the test key with fill 1
+func resign(t *testing.T, id, payload string) token.Token {
+	t.Helper()
+	signed := id + "." + payload
+	return token.Token(signed + "." + b64.EncodeToString(sign(deriveKey(testKey(id, 1).Secret, "sign"), signed)))
+}
+
+func flipByte(payload string, i int) string {
+	data, _ := b64.DecodeString(payload)
+	data[i] ^= 0xff
+	return b64.EncodeToString(data)
+}
+
+func TestNewSignedCodecErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []Key
+	}{
+		{"no keys", nil},
+		{"empty ID", []Key{testKey("", 1)}},
+		{"ID with dot", []Key{testKey("k.1", 1)}},
+		{"repeated ID", []Key{testKey("k1", 1), testKey("k1", 2)}},
+		{"short secret", []Key{{ID: "k1", Secret: make([]byte, minSecretSize-1)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSignedCodec(tt.keys, false); err == nil {
+				t.Error("NewSignedCodec() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := bytes.Repeat([]byte{7}, minSecretSize)
+	encoded := base64.StdEncoding.EncodeToString(secret)
+	tests := []struct {
+		name    string
+		keys    string
+		wantIDs []string
+		wantErr bool
+	}{
+		{"one key", "k1:" + encoded, []string{"k1"}, false},
+		{"rotated keys", "new:" + encoded + ", old:" + encoded, []string{"new", "old"}, false},
+		{"without secret", "k1", nil, true},
+		{"secret isn't base64", "k1:!!!", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeys(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(keys) != len(tt.wantIDs) {
+				t.Fatalf("ParseKeys() returned %d keys, want %d", len(keys), len(tt.wantIDs))
+			}
+			for i, key := range keys {
+				if key.ID != tt.wantIDs[i] || !bytes.Equal(key.Secret, secret) {
+					t.Errorf("key %d = %q, want %q with the secret", i, key.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRawCodec(t *testing.T) {
+	codec := NewRawCodec()
+	objectToken, err := codec.Encode("dir/a.pdf")
+	if err != nil || objectToken != "dir/a.pdf" {
+		t.Errorf("Encode() = %q, %v, want the key", objectToken, err)
+	}
+	if key, err := codec.Decode("dir/a.pdf"); err != nil || key != "dir/a.pdf" {
+		t.Errorf("Decode() = %q, %v, want the token", key, err)
+	}
+	if _, err := codec.Decode(""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Decode(\"\") error = %v, want ErrInvalidToken", err)
+	}
+}
@@ -1,7 +1,9 @@
 package endpoints
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	reqh "github.com/q-sharafian/file-transfer/internal/reqhandler"
 	s "github.com/q-sharafian/file-transfer/internal/server"
@@ -49,4 +51,82 @@ func InitEndpoints(server s.Server, logger l.Logger, reqHandler reqh.ReqHandler)
 			Type: reqh.Download, ResponseWriter: w, Request: r,
 		})
 	})
+	// Single files are redirected to their download links. (e.g. /download/<object token>)
+	redirectPath := fmt.Sprintf("%s/{%s...}", strings.TrimSuffix(downloadPath, "/"), reqh.ObjectTokenWildcard)
+	server.AddHandler(redirectPath, func(w s.ResponseWriter, r *s.Request) {
+		reqHandler.HandleRequest(&reqh.ReqDetails{
+			Type: reqh.DownloadRedirect, ResponseWriter: w, Request: r,
+		})
+	})
+
+	if filesPath := os.Getenv("FILES_PATH"); filesPath != "" {
+		server.AddHandler(filesPath, func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.Files, ResponseWriter: w, Request: r,
+			})
+		})
+	}
+
+	if deletePath := os.Getenv("DELETE_PATH"); deletePath != "" {
+		server.AddHandler(deletePath, func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.Delete, ResponseWriter: w, Request: r,
+			})
+		})
+	}
+
+	if copyPath := os.Getenv("COPY_PATH"); copyPath != "" {
+		server.AddHandler(copyPath, func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.Copy, ResponseWriter: w, Request: r,
+			})
+		})
+	}
+	if movePath := os.Getenv("MOVE_PATH"); movePath != "" {
+		server.AddHandler(movePath, func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.Move, ResponseWriter: w, Request: r,
+			})
+		})
+	}
+
+	if finalizePath := os.Getenv("FINALIZE_PATH"); finalizePath != "" {
+		server.AddHandler(finalizePath, func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.Finalize, ResponseWriter: w, Request: r,
+			})
+		})
+	}
+
+	if metadataPath := os.Getenv("METADATA_PATH"); metadataPath != "" {
+		server.AddHandler(metadataPath, func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.Metadata, ResponseWriter: w, Request: r,
+			})
+		})
+	}
+
+	// Endpoints of uploading big files in multiple parts
+	if multipartPath := os.Getenv("MULTIPART_PATH"); multipartPath != "" {
+		server.AddHandler(multipartPath+"/create", func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.MultipartCreate, ResponseWriter: w, Request: r,
+			})
+		})
+		server.AddHandler(multipartPath+"/parts", func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.MultipartParts, ResponseWriter: w, Request: r,
+			})
+		})
+		server.AddHandler(multipartPath+"/complete", func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.MultipartComplete, ResponseWriter: w, Request: r,
+			})
+		})
+		server.AddHandler(multipartPath+"/abort", func(w s.ResponseWriter, r *s.Request) {
+			reqHandler.HandleRequest(&reqh.ReqDetails{
+				Type: reqh.MultipartAbort, ResponseWriter: w, Request: r,
+			})
+		})
+	}
 }
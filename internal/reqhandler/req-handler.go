@@ -14,8 +14,34 @@ type ioType int
 const (
 	Upload   ioType = 1
 	Download ioType = 2
+	// Start a multipart upload and create links of its parts
+	MultipartCreate ioType = 3
+	// Create links of some parts of a multipart upload again. (e.g. to resume uploading)
+	MultipartParts ioType = 4
+	// Complete a multipart upload and store the file
+	MultipartComplete ioType = 5
+	// Cancel a multipart upload
+	MultipartAbort ioType = 6
+	// Check an uploaded file and mark it as finalized
+	Finalize ioType = 7
+	// Read metadata of files without downloading them
+	Metadata ioType = 8
+	Delete   ioType = 9
+	// Copy files to other tokens in the storage
+	Copy ioType = 10
+	// Move files to other tokens in the storage
+	Move ioType = 11
+	// List files that the client uploaded
+	Files ioType = 12
+	// Redirect the client to the download link of one file. Its object token is the path
+	// wildcard named ObjectTokenWildcard.
+	DownloadRedirect ioType = 13
 )
 
+// Name of the path wildcard that contains the object token of DownloadRedirect requests.
+// (e.g. "/download/{token...}")
+const ObjectTokenWildcard = "token"
+
 type ReqDetails struct {
 	Type ioType
 	server.ResponseWriter
@@ -28,6 +54,33 @@ type downlaodResponse struct {
 	// A map from file tokens to file urls. If the client hasn't permission to access
 	// a file, set value of its corresponding token to an empty string.
 	Tokens2URLs map[string]string `json:"tokens2urls"`
+	// A map from file tokens to the result of creating their download links. A failed
+	// file doesn't fail the others.
+	Tokens2Results map[string]fileResult `json:"tokens2results,omitempty"`
+	// Problems of the fields of an invalid request. (e.g. "object-tokens: is required")
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Status of processing a file in a request that contains multiple files
+type fileStatus string
+
+const (
+	fileOK fileStatus = "ok"
+	// The client hasn't permission to access the file
+	fileForbidden fileStatus = "forbidden"
+	fileNotFound  fileStatus = "not_found"
+	// The destination of copying/moving the file already exists
+	fileExists fileStatus = "already_exists"
+	// Processing the file failed. (e.g. the storage is unavailable)
+	fileError fileStatus = "error"
+)
+
+type fileResult struct {
+	Status fileStatus `json:"status"`
+	// Download link of the file. It's set just if the status is ok
+	URL string `json:"url,omitempty"`
+	// It's set if the status isn't ok
+	Error string `json:"error,omitempty"`
 }
 
 type uploadResponse struct {
@@ -36,5 +89,114 @@ type uploadResponse struct {
 	// A map from file types to file urls. If the client hasn't permission to access
 	// a file, set value of its corresponding token to an empty string.
 	// If we want to upload 5 png files, we have a key called png that has 5 uplaod link as the key.
+	// It's empty if files must be uploaded by POST forms.
 	Tokens2URLs map[string][]string `json:"tokens2urls"`
+	// Same as Tokens2URLs, but each link contains everything needed to upload the file.
+	Tokens2Links map[string][]uploadLink `json:"tokens2links"`
+}
+
+// Details of a file that the client is going to upload
+type uploadFileReq struct {
+	// Real name of the file. (e.g. invoice.pdf)
+	Name string `json:"name"`
+	// Arbitrary labels of the file
+	Labels map[string]string `json:"labels"`
+	// SHA-256 checksum of the file in base64. If it's set, the storage rejects the file
+	// with another content. (Just for PUT links)
+	ChecksumSHA256 string `json:"checksum-sha256"`
+}
+
+type uploadLink struct {
+	URL string `json:"url"`
+	// HTTP method the file must be uploaded with. (PUT or POST)
+	Method string `json:"method"`
+	// Fields of the form. It's set just for POST method and the file must be sent
+	// as a multipart/form-data field named "file" after all of them.
+	Fields map[string]string `json:"fields,omitempty"`
+	// Headers that must be sent with the same values. It's set just for PUT method.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Token of the file that is needed to finalize or download it
+	ObjectToken string `json:"object-token"`
+}
+
+type multipartResponse struct {
+	StatusCode int    `json:"status-code"`
+	Message    string `json:"message"`
+	// Token of the file that is being uploaded
+	ObjectToken string `json:"object-token,omitempty"`
+	UploadID    string `json:"upload-id,omitempty"`
+	// Upload links of the parts. Each part must be uploaded by PUT method and its ETag
+	// response header is needed to complete the upload.
+	Parts []partLink `json:"parts,omitempty"`
+}
+
+type partLink struct {
+	PartNumber int32  `json:"part-number"`
+	URL        string `json:"url"`
+}
+
+type finalizeResponse struct {
+	StatusCode  int    `json:"status-code"`
+	Message     string `json:"message"`
+	ObjectToken string `json:"object-token,omitempty"`
+	// Size of the file in bytes
+	Size uint64 `json:"size,omitempty"`
+	// Reasons the file is rejected. Rejected files are deleted.
+	Problems []string `json:"problems,omitempty"`
+}
+
+type metadataResponse struct {
+	StatusCode int    `json:"status-code"`
+	Message    string `json:"message"`
+	// A map from file tokens to their metadata. If the client hasn't permission to access
+	// a file or it doesn't exist, set value of its corresponding token to null.
+	Tokens2Metadata map[string]*fileMetadata `json:"tokens2metadata"`
+}
+
+type fileMetadata struct {
+	// Real name of the file with its extension. It's empty if it's unknown.
+	RealName string `json:"real-name"`
+	// Unix time the file is uploaded. It's zero if it's unknown.
+	UploadedAt int64 `json:"uploaded-at"`
+	// ID of the user who uploaded the file. It's empty if it's unknown. (e.g. the auth
+	// service doesn't implement Identify)
+	UploadedBy string            `json:"uploaded-by,omitempty"`
+	Labels     map[string]string `json:"labels"`
+}
+
+type deleteResponse struct {
+	StatusCode int    `json:"status-code"`
+	Message    string `json:"message"`
+	// A map from file tokens to the result of deleting them. A failed file doesn't
+	// fail the others.
+	Tokens2Results map[string]fileResult `json:"tokens2results"`
+}
+
+type transferResponse struct {
+	StatusCode int    `json:"status-code"`
+	Message    string `json:"message"`
+	// Result of copying/moving each file in the same order as the request. A failed
+	// file doesn't fail the others.
+	Results []transferResult `json:"results"`
+}
+
+type transferResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	fileResult
+}
+
+type filesResponse struct {
+	StatusCode int          `json:"status-code"`
+	Message    string       `json:"message"`
+	Files      []listedFile `json:"files"`
+	// It's sent to read the next page. It's empty if it's the last page.
+	NextCursor string `json:"next-cursor,omitempty"`
+}
+
+type listedFile struct {
+	ObjectToken string `json:"object-token"`
+	// Size of the file in bytes
+	Size uint64 `json:"size"`
+	fileMetadata
 }
@@ -0,0 +1,74 @@
+package reqhandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+type deleteReq struct {
+	AuthToken    token.Token   `json:"auth-token" validate:"required"`
+	ObjectTokens []token.Token `json:"object-tokens" validate:"required,min=1,maxfiles,dive,required"`
+}
+
+// Delete the files that the client is allowed to delete.
+func (rq *simpleReqHandler) deleteHandler(req *ReqDetails) {
+	var delReq deleteReq
+	if !rq.readRequest(req, &delReq, &delReq.AuthToken) {
+		return
+	}
+	// The auth server checks keys of the files
+	keys, validKeys := rq.objectKeys(delReq.ObjectTokens)
+	allowInfo, err := rq.auth.IsAllowedDelete(auth.DeleteAccessReq{
+		AuthToken:    delReq.AuthToken,
+		ObjectTokens: validKeys,
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Checking delete permission error: %s", err.Error())
+		rq.prepareAuthErrResponse(req, err, msg, "Failed to check delete permission")
+		return
+	}
+
+	var res deleteResponse
+	res.Tokens2Results = make(map[string]fileResult)
+	for i, objectToken := range delReq.ObjectTokens {
+		var result fileResult
+		switch {
+		case keys[i] == "":
+			result = invalidTokenResult
+		case !allowInfo[keys[i]]:
+			result = fileResult{Status: fileForbidden, Error: "Deleting the file is not allowed"}
+		default:
+			result = rq.deleteFile(keys[i])
+		}
+		res.Tokens2Results[objectToken.String()] = result
+	}
+	res.Message = "OK"
+	res.StatusCode = http.StatusOK
+	rq.setResponse(req, res, http.StatusOK)
+}
+
+// Delete the file that the client is allowed to delete
+func (rq *simpleReqHandler) deleteFile(key token.Token) fileResult {
+	// Storages don't report missing files on deleting them
+	_, err := rq.storage.GetMetadata(key.String())
+	if err == nil {
+		err = rq.storage.DeleteFile(key.String())
+		if err == nil {
+			return fileResult{Status: fileOK}
+		}
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return fileResult{Status: fileNotFound, Error: "File not found"}
+	}
+	msg := fmt.Sprintf("Deleting the file failed: %s", err.Error())
+	rq.logger.Debugf(msg)
+	if !rq.isDevEnv {
+		msg = "Failed to delete the file"
+	}
+	return fileResult{Status: fileError, Error: msg}
+}
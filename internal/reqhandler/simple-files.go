@@ -0,0 +1,67 @@
+package reqhandler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+)
+
+// Number of files in each page if the client doesn't specify it. (It's at most 1000)
+const defaultFilesLimit = 100
+
+type filesReq struct {
+	AuthToken token.Token `json:"auth-token" validate:"required"`
+	// next-cursor of the previous page. It's empty for the first page.
+	Cursor string `json:"cursor"`
+	// Maximum number of files in the page
+	Limit int32 `json:"limit" validate:"min=0,max=1000"`
+}
+
+// List the files that the client uploaded, page by page.
+func (rq *simpleReqHandler) filesHandler(req *ReqDetails) {
+	var fReq filesReq
+	if !rq.readRequest(req, &fReq, &fReq.AuthToken) {
+		return
+	}
+	if fReq.Limit == 0 {
+		fReq.Limit = defaultFilesLimit
+	}
+	userID, ok := rq.identify(req, fReq.AuthToken)
+	if !ok {
+		return
+	}
+
+	prefix, ok := rq.keyTemplate.UserPrefix(userID)
+	if !ok {
+		msg := "Listing files isn't possible, because files of each user aren't stored under its ID"
+		rq.prepareErrResponse(req, http.StatusNotImplemented, msg, msg)
+		return
+	}
+	list, err := rq.storage.ListFiles(prefix, fReq.Cursor, fReq.Limit)
+	if err != nil {
+		msg := fmt.Sprintf("Listing files failed: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to list files")
+		return
+	}
+	res := filesResponse{Files: make([]listedFile, 0, len(list.Files)), NextCursor: list.NextCursor}
+	for _, stat := range list.Files {
+		objectToken, err := rq.tokenCodec.Encode(stat.Key)
+		if err != nil {
+			msg := fmt.Sprintf("Creating object token failed: %s", err.Error())
+			rq.logger.Debugf(msg)
+			rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to list files")
+			return
+		}
+		stat.PrepareReadMetadata()
+		res.Files = append(res.Files, listedFile{
+			ObjectToken:  objectToken.String(),
+			Size:         stat.Size,
+			fileMetadata: *newFileMetadata(token.Token(stat.Key), stat.Metadata),
+		})
+	}
+	res.Message = "OK"
+	res.StatusCode = http.StatusOK
+	rq.setResponse(req, res, http.StatusOK)
+}
@@ -0,0 +1,136 @@
+package reqhandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+type finalizeReq struct {
+	AuthToken   token.Token `json:"auth-token" validate:"required"`
+	ObjectToken token.Token `json:"object-token" validate:"required"`
+	// Size of the uploaded file in bytes. (Optional)
+	Size *uint64 `json:"size"`
+	// MD5 hash of the uploaded file in hex. (Optional)
+	ChecksumMD5 string `json:"checksum-md5" validate:"omitempty,len=32,hexadecimal"`
+	// SHA-256 checksum of the uploaded file in base64. (Optional)
+	ChecksumSHA256 string `json:"checksum-sha256" validate:"omitempty,base64"`
+}
+
+// The client reports it uploaded a file. The file is checked without downloading it
+// and if it's valid, it's marked as finalized. Otherwise, it's deleted.
+func (rq *simpleReqHandler) finalizeHandler(req *ReqDetails) {
+	var finReq finalizeReq
+	if !rq.readRequest(req, &finReq, &finReq.AuthToken) {
+		return
+	}
+
+	key, ok := rq.objectKey(req, finReq.ObjectToken)
+	if !ok {
+		return
+	}
+	stat, err := rq.storage.StatFile(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		msg := "File not found. It's not uploaded yet"
+		rq.prepareErrResponse(req, http.StatusNotFound, msg, msg)
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Getting details of the file failed: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to get details of the file")
+		return
+	}
+	// Just the uploader could finalize the file, because invalid files are deleted.
+	isUploader, ok := rq.isUploader(req, stat.Metadata, finReq.AuthToken)
+	if !ok {
+		return
+	}
+	if !isUploader {
+		msg := "The file is not uploaded by this client"
+		rq.prepareErrResponse(req, http.StatusForbidden, msg, msg)
+		return
+	}
+	res := finalizeResponse{ObjectToken: finReq.ObjectToken.String(), Size: stat.Size}
+	if stat.Finalized {
+		res.Message = "The file is already finalized"
+		res.StatusCode = http.StatusOK
+		rq.setResponse(req, res, http.StatusOK)
+		return
+	}
+
+	fileType := file.ExtensionOf(key)
+	maxSize, isAllow, err2 := rq.allowedUploadSize(finReq.AuthToken, fileType)
+	if err2 != nil {
+		msg := fmt.Sprintf("Checking upload permission error: %s", err2.Error())
+		rq.prepareAuthErrResponse(req, err2, msg, "Failed to check upload permission")
+		return
+	}
+	problems, verifiable := checkUploadedFile(&finReq, &stat, fileType, isAllow, maxSize)
+
+	if len(problems) > 0 {
+		if err := rq.storage.DeleteFile(key); err != nil {
+			msg := fmt.Sprintf("Deleting the rejected file failed: %s", err.Error())
+			rq.logger.Errorf(msg)
+			rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to delete the rejected file")
+			return
+		}
+		res.Problems = problems
+		res.Message = "The file is rejected and deleted"
+		res.StatusCode = http.StatusUnprocessableEntity
+		rq.setResponse(req, res, http.StatusUnprocessableEntity)
+		return
+	}
+	// The file may be valid, so it's not deleted
+	if !verifiable {
+		msg := "The storage doesn't have any checksum of the file to compare with the given one"
+		rq.prepareErrResponse(req, http.StatusUnprocessableEntity, msg, msg)
+		return
+	}
+
+	if err := rq.storage.FinalizeFile(key); err != nil {
+		msg := fmt.Sprintf("Finalizing the file failed: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to finalize the file")
+		return
+	}
+	res.Message = "OK"
+	res.StatusCode = http.StatusOK
+	rq.setResponse(req, res, http.StatusOK)
+}
+
+// Compare the uploaded file with the allowed type and size, and the details the client
+// reported. If a given checksum can't be compared, verifiable is false.
+func checkUploadedFile(finReq *finalizeReq, stat *storage.FileStat, fileType file.FileExtension,
+	isAllow bool, maxSize uint64) (problems []string, verifiable bool) {
+	verifiable = true
+	if !isAllow {
+		problems = append(problems, fmt.Sprintf("Uploading files with type %s is not allowed", fileType))
+	}
+	if maxSize > 0 && stat.Size > maxSize {
+		problems = append(problems, fmt.Sprintf("File size %d bytes is larger than the allowed size %d bytes", stat.Size, maxSize))
+	}
+	if finReq.Size != nil && *finReq.Size != stat.Size {
+		problems = append(problems, fmt.Sprintf("File size is %d bytes, not %d bytes", stat.Size, *finReq.Size))
+	}
+	if finReq.ChecksumMD5 != "" {
+		if stat.ETag == "" {
+			verifiable = false
+		} else if !strings.EqualFold(stat.ETag, finReq.ChecksumMD5) {
+			problems = append(problems, "MD5 hash of the file doesn't match")
+		}
+	}
+	if finReq.ChecksumSHA256 != "" {
+		if stat.ChecksumSHA256 == "" {
+			verifiable = false
+		} else if stat.ChecksumSHA256 != finReq.ChecksumSHA256 {
+			problems = append(problems, "SHA-256 checksum of the file doesn't match")
+		}
+	}
+	return problems, verifiable
+}
@@ -0,0 +1,64 @@
+package reqhandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+// Return metadata of the files that the client could download, without downloading them.
+func (rq *simpleReqHandler) metadataHandler(req *ReqDetails) {
+	downloadReq, _, ok := rq.extractDownloadInfo(req)
+	if !ok {
+		return
+	}
+	objectTokens := downloadReq.ObjectTokens
+	var keys []token.Token
+	keys, downloadReq.ObjectTokens = rq.objectKeys(objectTokens)
+	allowInfo, err2 := rq.auth.IsAllowedDownload(*downloadReq)
+	if err2 != nil {
+		msg := fmt.Sprintf("Checking download permission error: %s", err2.Error())
+		rq.prepareAuthErrResponse(req, err2, msg, "Failed to check download permission")
+		return
+	}
+
+	var res metadataResponse
+	res.Tokens2Metadata = make(map[string]*fileMetadata)
+	for i, objectToken := range objectTokens {
+		res.Tokens2Metadata[objectToken.String()] = nil
+		if keys[i] == "" || !allowInfo[keys[i]] {
+			continue
+		}
+		md, err := rq.storage.GetMetadata(keys[i].String())
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			msg := fmt.Sprintf("Getting metadata of the file failed: %s", err.Error())
+			rq.logger.Debugf(msg)
+			rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to get metadata of the file")
+			return
+		}
+		md.PrepareReadMetadata()
+		res.Tokens2Metadata[objectToken.String()] = newFileMetadata(keys[i], md)
+	}
+	res.Message = "OK"
+	res.StatusCode = http.StatusOK
+	rq.setResponse(req, res, http.StatusOK)
+}
+
+// Convert metadata of a file to the response. Clients that could read the file see
+// ID of its uploader too.
+func newFileMetadata(key token.Token, md metadata.Metadata) *fileMetadata {
+	fileMD := &fileMetadata{RealName: md.RealFileName(file.ExtensionOf(key.String())), Labels: md.Labels()}
+	if uploadedAt := md.UploadedAt(); !uploadedAt.IsZero() {
+		fileMD.UploadedAt = uploadedAt.Unix()
+	}
+	fileMD.UploadedBy = md.UploadedBy()
+	return fileMD
+}
@@ -0,0 +1,306 @@
+package reqhandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/objectkey"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+)
+
+// Fields of each step of the upload. Part numbers are between 1 and 10000, the S3 limit
+// of the parts of a multipart upload.
+type multipartReq struct {
+	AuthToken token.Token `json:"auth-token" validate:"required"`
+	// Type of the file. (Just for creating the upload)
+	ObjectType file.FileExtension `json:"object-type" validate:"omitempty,fileext"`
+	// Size of the whole file in bytes. (Just for creating the upload)
+	Size uint64 `json:"size"`
+	// Number of parts the file is uploaded in. (Just for creating the upload)
+	PartCount int32 `json:"part-count" validate:"min=0,max=10000"`
+	// Real name and labels of the file. (Just for creating the upload)
+	uploadFileReq
+	// Token of the file that is being uploaded. (Not needed for creating the upload)
+	ObjectToken token.Token `json:"object-token"`
+	// Not needed for creating the upload
+	UploadID string `json:"upload-id"`
+	// Parts that their links must be created again.
+	PartNumbers []int32 `json:"part-numbers" validate:"max=10000,dive,min=1,max=10000"`
+	// Uploaded parts to complete the upload
+	Parts []struct {
+		PartNumber int32  `json:"part-number" validate:"min=1,max=10000"`
+		ETag       string `json:"etag" validate:"required"`
+	} `json:"parts" validate:"max=10000,dive"`
+}
+
+// Process requests of the multipart uploads. Each step of the upload checks whether
+// the client is still allowed to upload the file type and it created the upload.
+func (rq *simpleReqHandler) multipartHandler(req *ReqDetails) {
+	var mpReq multipartReq
+	if !rq.readRequest(req, &mpReq, &mpReq.AuthToken) {
+		return
+	}
+	if req.Type == MultipartCreate {
+		rq.multipartCreateHandler(req, &mpReq)
+		return
+	}
+
+	if mpReq.ObjectToken == "" || mpReq.UploadID == "" {
+		msg := "Both object-token and upload-id are required"
+		rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+		return
+	}
+	key, ok := rq.objectKey(req, mpReq.ObjectToken)
+	if !ok {
+		return
+	}
+	fileType := file.ExtensionOf(key)
+	maxSize, ok := rq.checkUploadType(req, mpReq.AuthToken, fileType)
+	if !ok {
+		return
+	}
+	upload := storage.MultipartUpload{Key: key, UploadID: mpReq.UploadID}
+	if !rq.checkMultipartUploader(req, upload, mpReq.AuthToken) {
+		return
+	}
+	res := multipartResponse{ObjectToken: mpReq.ObjectToken.String(), UploadID: upload.UploadID}
+
+	switch req.Type {
+	case MultipartParts:
+		if len(mpReq.PartNumbers) == 0 {
+			msg := "part-numbers is required"
+			rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+			return
+		}
+		parts, err := rq.createPartLinks(upload, mpReq.PartNumbers)
+		if err != nil {
+			msg := fmt.Sprintf("Creating links of parts failed: %s", err.Error())
+			rq.logger.Debugf(msg)
+			rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create links of parts")
+			return
+		}
+		res.Parts = parts
+	case MultipartComplete:
+		if len(mpReq.Parts) == 0 {
+			msg := "At least one uploaded part is needed to complete the upload"
+			rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+			return
+		}
+		parts := make([]storage.CompletedPart, 0, len(mpReq.Parts))
+		for _, part := range mpReq.Parts {
+			parts = append(parts, storage.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+		}
+		if err := rq.storage.CompleteMultipartUpload(upload, parts); err != nil {
+			msg := fmt.Sprintf("Completing multipart upload failed: %s", err.Error())
+			rq.logger.Debugf(msg)
+			rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to complete multipart upload")
+			return
+		}
+		if !rq.checkCompletedSize(req, key, maxSize) {
+			return
+		}
+	case MultipartAbort:
+		if err := rq.storage.AbortMultipartUpload(upload); err != nil {
+			msg := fmt.Sprintf("Aborting multipart upload failed: %s", err.Error())
+			rq.logger.Debugf(msg)
+			rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to abort multipart upload")
+			return
+		}
+	}
+	res.Message = "OK"
+	res.StatusCode = http.StatusOK
+	rq.setResponse(req, res, http.StatusOK)
+}
+
+func (rq *simpleReqHandler) multipartCreateHandler(req *ReqDetails, mpReq *multipartReq) {
+	if mpReq.ObjectType == "" || mpReq.PartCount == 0 {
+		msg := "Both object-type and part-count are required"
+		rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+		return
+	}
+	maxSize, ok := rq.checkUploadType(req, mpReq.AuthToken, mpReq.ObjectType)
+	if !ok {
+		return
+	}
+	if maxSize > 0 && mpReq.Size > maxSize {
+		msg := fmt.Sprintf("File size %d bytes is larger than the allowed size %d bytes", mpReq.Size, maxSize)
+		rq.prepareErrResponse(req, http.StatusRequestEntityTooLarge, msg, msg)
+		return
+	}
+
+	// Checksum of the whole file isn't verified in multipart uploads
+	if rq.keyTemplate.NeedsChecksum() {
+		msg := "Multipart uploads can't be used, because names of the files are created from their checksum"
+		rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+		return
+	}
+	userID, ok := rq.uploaderID(req, mpReq.AuthToken)
+	if !ok {
+		return
+	}
+	uploadedAt := time.Now().UTC()
+	fileName, err := rq.keyTemplate.FileName(objectkey.KeyInfo{
+		UserID:     userID,
+		FileType:   mpReq.ObjectType,
+		UploadedAt: uploadedAt,
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Failed to create multipart upload: can't create file name: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create multipart upload")
+		return
+	}
+	objectToken, err := rq.tokenCodec.Encode(fmt.Sprintf("%s.%s", fileName, mpReq.ObjectType.String()))
+	if err != nil {
+		msg := fmt.Sprintf("Failed to create multipart upload: can't create object token: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create multipart upload")
+		return
+	}
+	md, err := prepareUploadMetadata(userID, mpReq.AuthToken, mpReq.ObjectType, mpReq.uploadFileReq)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid file info: %s", err.Error())
+		rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+		return
+	}
+	upload, err := rq.storage.CreateMultipartUpload(storage.UploadFileInfo{
+		FileName:      fileName,
+		UploadedBy:    mpReq.AuthToken,
+		UploadedAt:    uploadedAt,
+		FileExtension: mpReq.ObjectType,
+		Metadata:      md,
+		MaxSize:       maxSize,
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Creating multipart upload failed: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create multipart upload")
+		return
+	}
+	partNumbers := make([]int32, 0, mpReq.PartCount)
+	for i := int32(1); i <= mpReq.PartCount; i++ {
+		partNumbers = append(partNumbers, i)
+	}
+	parts, err := rq.createPartLinks(upload, partNumbers)
+	if err != nil {
+		msg := fmt.Sprintf("Creating links of parts failed: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create links of parts")
+		return
+	}
+	rq.setResponse(req, multipartResponse{
+		StatusCode:  http.StatusOK,
+		Message:     "OK",
+		ObjectToken: objectToken.String(),
+		UploadID:    upload.UploadID,
+		Parts:       parts,
+	}, http.StatusOK)
+}
+
+// Check whether the client created the multipart upload, so other clients couldn't
+// complete or abort it. If it didn't, the error response is sent to the client.
+func (rq *simpleReqHandler) checkMultipartUploader(req *ReqDetails, upload storage.MultipartUpload,
+	authToken token.Token) bool {
+	md, err := rq.storage.MultipartMetadata(upload)
+	if errors.Is(err, storage.ErrNotFound) {
+		msg := "Multipart upload not found"
+		rq.prepareErrResponse(req, http.StatusNotFound, msg, msg)
+		return false
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Getting details of the multipart upload failed: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to get details of the multipart upload")
+		return false
+	}
+	isUploader, ok := rq.isUploader(req, md, authToken)
+	if !ok {
+		return false
+	}
+	if !isUploader {
+		msg := "The multipart upload is not created by this client"
+		rq.prepareErrResponse(req, http.StatusForbidden, msg, msg)
+		return false
+	}
+	return true
+}
+
+// Parts are uploaded by links that don't limit their size, so the completed file is
+// deleted if it's larger than the allowed size. If it is, the error response is sent
+// to the client.
+func (rq *simpleReqHandler) checkCompletedSize(req *ReqDetails, key string, maxSize uint64) bool {
+	if maxSize == 0 {
+		return true
+	}
+	stat, err := rq.storage.StatFile(key)
+	if err != nil {
+		msg := fmt.Sprintf("Getting details of the completed file failed: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to get details of the completed file")
+		return false
+	}
+	if stat.Size <= maxSize {
+		return true
+	}
+	if err := rq.storage.DeleteFile(key); err != nil {
+		msg := fmt.Sprintf("Deleting the too large file failed: %s", err.Error())
+		rq.logger.Errorf(msg)
+		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to delete the too large file")
+		return false
+	}
+	msg := fmt.Sprintf("File size %d bytes is larger than the allowed size %d bytes, so it's deleted", stat.Size, maxSize)
+	rq.prepareErrResponse(req, http.StatusRequestEntityTooLarge, msg, msg)
+	return false
+}
+
+func (rq *simpleReqHandler) createPartLinks(upload storage.MultipartUpload, partNumbers []int32) ([]partLink, error) {
+	urls, err := rq.storage.UploadParts(upload, partNumbers, rq.uploadExpireTime)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]partLink, 0, len(urls))
+	for i, url := range urls {
+		parts = append(parts, partLink{PartNumber: partNumbers[i], URL: url.String()})
+	}
+	return parts, nil
+}
+
+// Check whether the client could upload the file type and return maximum size of it
+// in bytes. If it couldn't, the error response is sent to the client.
+func (rq *simpleReqHandler) checkUploadType(req *ReqDetails, authToken token.Token, fileType file.FileExtension) (uint64, bool) {
+	maxSize, isAllow, err := rq.allowedUploadSize(authToken, fileType)
+	if err != nil {
+		msg := fmt.Sprintf("Checking upload permission error: %s", err.Error())
+		rq.prepareAuthErrResponse(req, err, msg, "Failed to check upload permission")
+		return 0, false
+	}
+	if !isAllow {
+		msg := fmt.Sprintf("Uploading files with type %s is not allowed", fileType)
+		rq.prepareErrResponse(req, http.StatusForbidden, msg, msg)
+		return 0, false
+	}
+	return maxSize, true
+}
+
+// Return whether the client could upload the file type and maximum size of it in bytes.
+func (rq *simpleReqHandler) allowedUploadSize(authToken token.Token, fileType file.FileExtension) (uint64, bool, *e.Error) {
+	allowInfo, err := rq.auth.IsAllowedUpload(auth.UploadAccessReq{
+		AuthToken:   authToken,
+		ObjectTypes: map[file.FileExtension]uint{fileType: 1},
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	for _, upInfo := range allowInfo {
+		if upInfo.FileType == fileType && upInfo.IsAllow {
+			return upInfo.MaxSize * 1024, true, nil
+		}
+	}
+	return 0, false, nil
+}
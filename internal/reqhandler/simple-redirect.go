@@ -0,0 +1,60 @@
+package reqhandler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+)
+
+// Redirect the client to the download link of one file, so files could be linked
+// directly in the pages. (e.g. <img src="/download/<object token>">) The request doesn't
+// have any body, so the auth token must be in a header, a cookie or the query.
+func (rq *simpleReqHandler) downloadRedirectHandler(req *ReqDetails) {
+	objectToken := token.Token(req.PathValue(ObjectTokenWildcard))
+	if objectToken == "" {
+		msg := "object token is required"
+		rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+		return
+	}
+	disposition := req.URL.Query().Get("disposition")
+	if disposition != "" && disposition != "attachment" && disposition != "inline" {
+		msg := "disposition must be \"attachment\" or \"inline\""
+		rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+		return
+	}
+	key, ok := rq.objectKey(req, objectToken)
+	if !ok {
+		return
+	}
+	authToken := rq.authToken(req, "")
+	allowInfo, err := rq.auth.IsAllowedDownload(auth.DownloadAccessReq{
+		AuthToken:    authToken,
+		ObjectTokens: []token.Token{token.Token(key)},
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Checking download permission error: %s", err.Error())
+		rq.prepareAuthErrResponse(req, err, msg, "Failed to check download permission")
+		return
+	}
+	if !allowInfo[token.Token(key)] {
+		msg := "Downloading the file is not allowed"
+		rq.prepareErrResponse(req, http.StatusForbidden, msg, msg)
+		return
+	}
+
+	result := rq.createDownloadLink(token.Token(key), authToken, disposition == "inline")
+	switch result.Status {
+	case fileNotFound:
+		rq.prepareErrResponse(req, http.StatusNotFound, result.Error, result.Error)
+		return
+	case fileError:
+		rq.prepareErrResponse(req, http.StatusInternalServerError, result.Error, result.Error)
+		return
+	}
+	// Browsers could reuse the redirect while the link is valid, but the link depends on
+	// the auth token, so shared caches mustn't keep it.
+	req.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(rq.downloadExpireTime.Seconds()/2)))
+	http.Redirect(req.ResponseWriter, req.Request.Request, result.URL, http.StatusTemporaryRedirect)
+}
@@ -1,19 +1,26 @@
 package reqhandler
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/go-playground/validator/v10"
 	"github.com/q-sharafian/file-transfer/internal/auth"
 	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	"github.com/q-sharafian/file-transfer/internal/common/objectkey"
+	"github.com/q-sharafian/file-transfer/internal/common/objecttoken"
 	"github.com/q-sharafian/file-transfer/internal/common/token"
 	"github.com/q-sharafian/file-transfer/internal/storage"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
 	l "github.com/q-sharafian/file-transfer/pkg/logger"
 )
 
@@ -28,6 +35,22 @@ type simpleReqHandler struct {
 	// Storage service
 	storage  storage.Storage
 	isDevEnv bool
+	// If it's true, files are uploaded by POST forms that the storage rejects files
+	// larger than the allowed size. Otherwise, PUT links are created.
+	uploadByForm bool
+	// Names of the files in the storage are created by it
+	keyTemplate *objectkey.Template
+	// Clients refer to the files by the tokens it creates
+	tokenCodec objecttoken.Codec
+	// Places of the auth token in the requests, in order of their priority
+	tokenExtractors []tokenExtractor
+	// Validator of the JSON bodies of the requests
+	validate *validator.Validate
+	// Maximum size of the JSON body of requests in bytes
+	maxBodySize int64
+	// Maximum number of files in a request, and files of a type in an upload request
+	maxFiles        int
+	maxFilesPerType int
 }
 
 // Create a new instance of simpleReqHandler.
@@ -35,6 +58,48 @@ func NewSimpleReqHandler(auth auth.Auth, storage storage.Storage, logger l.Logge
 	uploadExpireTime, _ := strconv.Atoi(os.Getenv("UPLOAD_EXPIRE_TIME"))
 	downloadExpireTime, _ := strconv.Atoi(os.Getenv("DOWNLOAD_EXPIRE_TIME"))
 	isDevEnv := os.Getenv("APP_MODE") == "development"
+	uploadByForm := strings.ToUpper(os.Getenv("UPLOAD_METHOD")) == http.MethodPost
+	pattern := os.Getenv("OBJECT_KEY_TEMPLATE")
+	if pattern == "" {
+		pattern = objectkey.DefaultTemplate
+	}
+	keyTemplate, err := objectkey.NewTemplate(pattern, os.Getenv("OBJECT_KEY_TENANT"))
+	if err != nil {
+		logger.Panicf("Invalid OBJECT_KEY_TEMPLATE: %s", err.Error())
+	}
+	// Storages verify checksum of the files just for PUT links
+	if keyTemplate.NeedsChecksum() && uploadByForm {
+		logger.Panicf("OBJECT_KEY_TEMPLATE with {sha256} can't be used when UPLOAD_METHOD is POST")
+	}
+	tokenCodec := objecttoken.NewRawCodec()
+	if tokenKeys := os.Getenv("OBJECT_TOKEN_KEYS"); tokenKeys != "" {
+		keys, err := objecttoken.ParseKeys(tokenKeys)
+		if err == nil {
+			tokenCodec, err = objecttoken.NewSignedCodec(keys, os.Getenv("OBJECT_TOKEN_ENCRYPT") == "true")
+		}
+		if err != nil {
+			logger.Panicf("Invalid OBJECT_TOKEN_KEYS: %s", err.Error())
+		}
+	}
+	tokenSources := os.Getenv("AUTH_TOKEN_SOURCES")
+	if tokenSources == "" {
+		tokenSources = defaultTokenSources
+	}
+	cookieName := os.Getenv("AUTH_TOKEN_COOKIE")
+	if cookieName == "" {
+		cookieName = defaultTokenParam
+	}
+	queryParam := os.Getenv("AUTH_TOKEN_QUERY_PARAM")
+	if queryParam == "" {
+		queryParam = defaultTokenParam
+	}
+	tokenExtractors, err := newTokenExtractors(tokenSources, cookieName, queryParam)
+	if err != nil {
+		logger.Panicf("Invalid AUTH_TOKEN_SOURCES: %s", err.Error())
+	}
+	maxBodySize := positiveEnv("MAX_REQUEST_BODY_SIZE", defaultMaxBodySize, logger)
+	maxFiles := positiveEnv("MAX_FILES_PER_REQUEST", defaultMaxFiles, logger)
+	maxFilesPerType := positiveEnv("MAX_FILES_PER_TYPE", defaultMaxFilesPerType, logger)
 	return &simpleReqHandler{
 		time.Duration(uploadExpireTime) * time.Second,
 		time.Duration(downloadExpireTime) * time.Second,
@@ -42,19 +107,90 @@ func NewSimpleReqHandler(auth auth.Auth, storage storage.Storage, logger l.Logge
 		auth,
 		storage,
 		isDevEnv,
+		uploadByForm,
+		keyTemplate,
+		tokenCodec,
+		tokenExtractors,
+		newValidator(maxFiles, maxFilesPerType),
+		int64(maxBodySize),
+		maxFiles,
+		maxFilesPerType,
+	}
+}
+
+// Return the positive integer of the environment variable, or def if it isn't set
+func positiveEnv(name string, def int, logger l.Logger) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Panicf("Invalid %s: it must be a positive integer", name)
 	}
+	return n
 }
 
 // Process An IO (i.e. download/upload) request and response to client
 func (req *simpleReqHandler) HandleRequest(ioDetails *ReqDetails) {
-	if ioDetails.Type == Upload {
+	switch ioDetails.Type {
+	case Upload:
 		if ioDetails.Method != http.MethodPost {
 			msg := "HTTP method not allowed. (To uploading a file, use POST method)"
 			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
 			return
 		}
 		req.uploadHander(ioDetails)
-	} else {
+	case Finalize:
+		if ioDetails.Method != http.MethodPost {
+			msg := "HTTP method not allowed. (To finalizing an uploaded file, use POST method)"
+			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
+			return
+		}
+		req.finalizeHandler(ioDetails)
+	case MultipartCreate, MultipartParts, MultipartComplete, MultipartAbort:
+		if ioDetails.Method != http.MethodPost {
+			msg := "HTTP method not allowed. (To uploading a file in multiple parts, use POST method)"
+			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
+			return
+		}
+		req.multipartHandler(ioDetails)
+	case Metadata:
+		if ioDetails.Method != http.MethodGet {
+			msg := "HTTP method not allowed. (To reading metadata of files, use GET method)"
+			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
+			return
+		}
+		req.metadataHandler(ioDetails)
+	case Delete:
+		if ioDetails.Method != http.MethodDelete {
+			msg := "HTTP method not allowed. (To deleting files, use DELETE method)"
+			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
+			return
+		}
+		req.deleteHandler(ioDetails)
+	case Copy, Move:
+		if ioDetails.Method != http.MethodPost {
+			msg := "HTTP method not allowed. (To copying/moving files, use POST method)"
+			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
+			return
+		}
+		req.transferHandler(ioDetails)
+	case Files:
+		if ioDetails.Method != http.MethodGet {
+			msg := "HTTP method not allowed. (To listing files, use GET method)"
+			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
+			return
+		}
+		req.filesHandler(ioDetails)
+	case DownloadRedirect:
+		if ioDetails.Method != http.MethodGet {
+			msg := "HTTP method not allowed. (To downloading a file, use GET method)"
+			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
+			return
+		}
+		req.downloadRedirectHandler(ioDetails)
+	default:
 		if ioDetails.Method != http.MethodGet {
 			msg := "HTTP method not allowed. (To downloading a file, use GET method)"
 			req.prepareErrResponse(ioDetails, http.StatusMethodNotAllowed, msg, msg)
@@ -65,97 +201,141 @@ func (req *simpleReqHandler) HandleRequest(ioDetails *ReqDetails) {
 }
 
 func (rq *simpleReqHandler) downloadHander(req *ReqDetails) {
-	downloadReq, err := rq.extractDownloadInfo(req)
-	if err != nil {
-		msg := fmt.Sprintf("Extracting download info error: %s", err.Error())
-		rq.logger.Debugf(msg)
-		rq.prepareErrResponse(req, http.StatusBadRequest, msg, "Failed to extract download info")
+	downloadReq, inline, ok := rq.extractDownloadInfo(req)
+	if !ok {
 		return
 	}
+	// The auth server checks keys of the files
+	objectTokens := downloadReq.ObjectTokens
+	var keys []token.Token
+	keys, downloadReq.ObjectTokens = rq.objectKeys(objectTokens)
 	allowInfo, err2 := rq.auth.IsAllowedDownload(*downloadReq)
 	if err2 != nil {
 		msg := fmt.Sprintf("Checking download permission error: %s", err2.Error())
-		rq.logger.Debugf(msg)
-		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to check download permission")
+		rq.prepareAuthErrResponse(req, err2, msg, "Failed to check download permission")
 		return
 	}
 
 	// Prepare http response to client
 	var res downlaodResponse
 	res.Tokens2URLs = make(map[string]string)
-	for k, IsAllowDown := range allowInfo {
-		if !IsAllowDown {
-			res.Tokens2URLs[k.String()] = ""
-		}
-		downloadInfo := storage.DownloadFileInfo{
-			FileName:     k.String(),
-			DownloadedBy: downloadReq.AuthToken,
-			DownloadedAt: time.Now().UTC(),
+	res.Tokens2Results = make(map[string]fileResult)
+	for i, objectToken := range objectTokens {
+		var result fileResult
+		switch {
+		case keys[i] == "":
+			result = invalidTokenResult
+		case !allowInfo[keys[i]]:
+			result = fileResult{Status: fileForbidden, Error: "Downloading the file is not allowed"}
+		default:
+			result = rq.createDownloadLink(keys[i], downloadReq.AuthToken, inline)
 		}
-		url, err := rq.storage.DownloadFile(downloadInfo, rq.downloadExpireTime)
-		if err != nil {
-			msg := fmt.Sprintf("Creating download link failed: %s", err.Error())
-			rq.logger.Debugf(msg)
-			rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create download link")
-			return
-		}
-		res.Tokens2URLs[k.String()] = url.String()
+		res.Tokens2URLs[objectToken.String()] = result.URL
+		res.Tokens2Results[objectToken.String()] = result
 	}
 	res.Message = "OK"
 	res.StatusCode = http.StatusOK
 	rq.setResponse(req, res, http.StatusOK)
 }
 
+// Create a download link of the file that the client is allowed to download
+func (rq *simpleReqHandler) createDownloadLink(key, downloadBy token.Token, inline bool) fileResult {
+	link, err := rq.storage.DownloadFile(storage.DownloadFileInfo{
+		FileName:     key.String(),
+		DownloadedBy: downloadBy,
+		DownloadedAt: time.Now().UTC(),
+		Inline:       inline,
+	}, rq.downloadExpireTime)
+	if err == nil {
+		return fileResult{Status: fileOK, URL: link.String()}
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return fileResult{Status: fileNotFound, Error: "File not found"}
+	}
+	msg := fmt.Sprintf("Creating download link failed: %s", err.Error())
+	rq.logger.Debugf(msg)
+	if !rq.isDevEnv {
+		msg = "Failed to create download link"
+	}
+	return fileResult{Status: fileError, Error: msg}
+}
+
 func (rq *simpleReqHandler) uploadHander(req *ReqDetails) {
-	uploadReq, err := rq.extractUploadInfo(req)
-	if err != nil {
-		msg := fmt.Sprintf("Extracting upload info error: %s", err.Error())
-		rq.logger.Debugf(msg)
-		rq.prepareErrResponse(req, http.StatusBadRequest, msg, "Failed to extract upload info")
+	uploadReq, filesInfo, ok := rq.extractUploadInfo(req)
+	if !ok {
 		return
 	}
 	allowInfo, err2 := rq.auth.IsAllowedUpload(*uploadReq)
 	if err2 != nil {
 		msg := fmt.Sprintf("Checking upload permission error: %s", err2.Error())
-		rq.logger.Debugf(msg)
-		rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to check upload permission")
+		rq.prepareAuthErrResponse(req, err2, msg, "Failed to check upload permission")
+		return
+	}
+	userID, ok := rq.uploaderID(req, uploadReq.AuthToken)
+	if !ok {
 		return
 	}
 
 	// Prepare http response to client
 	var res uploadResponse
 	res.Tokens2URLs = make(map[string][]string)
+	res.Tokens2Links = make(map[string][]uploadLink)
 	for _, upInfo := range allowInfo {
 		if !upInfo.IsAllow {
 			continue
 		}
 		fileType := upInfo.FileType.String()
-		if res.Tokens2URLs[fileType] == nil {
+		if res.Tokens2URLs[fileType] == nil && !rq.uploadByForm {
 			res.Tokens2URLs[fileType] = make([]string, 0)
 		}
+		if res.Tokens2Links[fileType] == nil {
+			res.Tokens2Links[fileType] = make([]uploadLink, 0)
+		}
 		for i := uint(0); i < uploadReq.ObjectTypes[file.FileExtension(fileType)]; i++ {
-			id, err := uuid.NewRandom()
+			var fileInfo uploadFileReq
+			if i < uint(len(filesInfo[upInfo.FileType])) {
+				fileInfo = filesInfo[upInfo.FileType][i]
+			}
+			md, err := prepareUploadMetadata(userID, uploadReq.AuthToken, upInfo.FileType, fileInfo)
+			if err == nil {
+				err = rq.checkFileChecksum(fileInfo)
+			}
 			if err != nil {
-				msg := fmt.Sprintf("Failed to create upload link: can't create uuid: %s", err.Error())
-				rq.logger.Debugf(msg)
-				rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create upload link")
+				msg := fmt.Sprintf("Invalid file info: %s", err.Error())
+				rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
 				return
 			}
-
 			uploadInfo := storage.UploadFileInfo{
-				FileName:      id.String(),
-				UploadedBy:    uploadReq.AuthToken,
-				UploadedAt:    time.Now().UTC(),
-				FileExtension: upInfo.FileType,
+				UploadedBy:     uploadReq.AuthToken,
+				UploadedAt:     time.Now().UTC(),
+				FileExtension:  upInfo.FileType,
+				Metadata:       md,
+				MaxSize:        upInfo.MaxSize * 1024,
+				ChecksumSHA256: fileInfo.ChecksumSHA256,
+			}
+			uploadInfo.FileName, err = rq.keyTemplate.FileName(objectkey.KeyInfo{
+				UserID:         userID,
+				FileType:       upInfo.FileType,
+				ChecksumSHA256: fileInfo.ChecksumSHA256,
+				UploadedAt:     uploadInfo.UploadedAt,
+			})
+			if err != nil {
+				msg := fmt.Sprintf("Failed to create upload link: can't create file name: %s", err.Error())
+				rq.logger.Debugf(msg)
+				rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create upload link")
+				return
 			}
-			url, err := rq.storage.UploadFile(uploadInfo, rq.uploadExpireTime)
+			link, err := rq.createUploadLink(uploadInfo)
 			if err != nil {
 				msg := fmt.Sprintf("Creating upload link failed: %s", err.Error())
 				rq.logger.Debugf(msg)
 				rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create upload link")
 				return
 			}
-			res.Tokens2URLs[fileType] = append(res.Tokens2URLs[fileType], url.String())
+			if !rq.uploadByForm {
+				res.Tokens2URLs[fileType] = append(res.Tokens2URLs[fileType], link.URL)
+			}
+			res.Tokens2Links[fileType] = append(res.Tokens2Links[fileType], link)
 		}
 	}
 	res.Message = "OK"
@@ -163,6 +343,30 @@ func (rq *simpleReqHandler) uploadHander(req *ReqDetails) {
 	rq.setResponse(req, res, http.StatusOK)
 }
 
+// Create a PUT link or POST form to upload the file, based on the upload method
+func (rq *simpleReqHandler) createUploadLink(uploadInfo storage.UploadFileInfo) (uploadLink, error) {
+	objectToken, err := rq.tokenCodec.Encode(fmt.Sprintf("%s.%s", uploadInfo.FileName, uploadInfo.FileExtension.String()))
+	if err != nil {
+		return uploadLink{}, fmt.Errorf("creating object token error: %s", err.Error())
+	}
+	if rq.uploadByForm {
+		form, err := rq.storage.UploadFileForm(uploadInfo, rq.uploadExpireTime)
+		if err != nil {
+			return uploadLink{}, err
+		}
+		return uploadLink{
+			URL: form.URL.String(), Method: http.MethodPost, Fields: form.Fields, ObjectToken: objectToken.String(),
+		}, nil
+	}
+	link, err := rq.storage.UploadFile(uploadInfo, rq.uploadExpireTime)
+	if err != nil {
+		return uploadLink{}, err
+	}
+	return uploadLink{
+		URL: link.URL.String(), Method: http.MethodPut, Headers: link.Headers, ObjectToken: objectToken.String(),
+	}, nil
+}
+
 // Send response to the client
 func (io *simpleReqHandler) setResponse(req *ReqDetails, response any, statusCode int) {
 	req.Header().Set("Content-Type", "application/json")
@@ -175,49 +379,182 @@ func (io *simpleReqHandler) setResponse(req *ReqDetails, response any, statusCod
 	}
 }
 
-// Extract needded info from http request and return
-func (ioh *simpleReqHandler) extractDownloadInfo(ioDetails *ReqDetails) (*auth.DownloadAccessReq, error) {
-	body, err := io.ReadAll(ioDetails.Body)
-	if err != nil {
-		return nil, fmt.Errorf("getting http body error: %s", err.Error())
-	}
-	defer ioDetails.Body.Close()
-
+// Extract needded info from http request and return. It's returned too whether browsers
+// must display the files instead of saving them. If the request doesn't have any body,
+// the files are taken from "object-token" query parameters. (e.g. ?object-token=a&object-token=b)
+// If the request is invalid, the error response is sent to the client.
+func (ioh *simpleReqHandler) extractDownloadInfo(ioDetails *ReqDetails) (*auth.DownloadAccessReq, bool, bool) {
 	var authData struct {
 		AuthToken    token.Token   `json:"auth-token" validate:"required"`
-		ObjectTokens []token.Token `json:"object-tokens" validate:"required"`
+		ObjectTokens []token.Token `json:"object-tokens" validate:"required,min=1,maxfiles,dive,required"`
+		// It could be "attachment" (default) or "inline"
+		Disposition string `json:"disposition" validate:"omitempty,oneof=attachment inline"`
 	}
-	err = json.Unmarshal(body, &authData)
-	if err != nil {
-		return nil, fmt.Errorf("unmarshaling http body error: %s", err.Error())
+	if !ioh.decodeBody(ioDetails, &authData) {
+		return nil, false, false
+	}
+	if authData.ObjectTokens == nil {
+		query := ioDetails.URL.Query()
+		for _, objectToken := range query["object-token"] {
+			authData.ObjectTokens = append(authData.ObjectTokens, token.Token(objectToken))
+		}
+		if authData.Disposition == "" {
+			authData.Disposition = query.Get("disposition")
+		}
+	}
+	authData.AuthToken = ioh.authToken(ioDetails, authData.AuthToken)
+	if problems := ioh.requestProblems(&authData); len(problems) > 0 {
+		ioh.prepareInvalidResponse(ioDetails, problems)
+		return nil, false, false
 	}
 	return &auth.DownloadAccessReq{
 		AuthToken:    authData.AuthToken,
 		ObjectTokens: authData.ObjectTokens,
-	}, nil
+	}, authData.Disposition == "inline", true
 }
 
-// Extract needded info from http request and return
-func (ioh *simpleReqHandler) extractUploadInfo(ioDetails *ReqDetails) (*auth.UploadAccessReq, error) {
-	body, err := io.ReadAll(ioDetails.Request.Body)
-	if err != nil {
-		return nil, fmt.Errorf("getting http body error: %s", err.Error())
-	}
-	defer ioDetails.Request.Body.Close()
-
+// Extract needded info from http request and return. Details of the files of each type
+// are returned too. If the request is invalid, the error response is sent to the client.
+func (ioh *simpleReqHandler) extractUploadInfo(ioDetails *ReqDetails) (*auth.UploadAccessReq,
+	map[file.FileExtension][]uploadFileReq, bool) {
 	var authData struct {
-		AuthToken   token.Token                 `json:"auth-token" validate:"required"`
-		ObjectTypes map[file.FileExtension]uint `json:"object-types" validate:"required"`
+		AuthToken token.Token `json:"auth-token" validate:"required"`
+		// Number of the files of each type
+		ObjectTypes map[file.FileExtension]uint `json:"object-types" validate:"required,min=1,dive,keys,fileext,endkeys,min=1,maxpertype"`
+		// Details of the files of each type in the same order as their upload links.
+		Files map[file.FileExtension][]uploadFileReq `json:"files" validate:"omitempty,dive,keys,fileext,endkeys"`
 	}
-	err = json.Unmarshal(body, &authData)
-	if err != nil {
-		return nil, fmt.Errorf("unmarshaling http body error: %s", err.Error())
+	if !ioh.decodeBody(ioDetails, &authData) {
+		return nil, nil, false
+	}
+	authData.AuthToken = ioh.authToken(ioDetails, authData.AuthToken)
+	problems := ioh.requestProblems(&authData)
+	var count uint
+	for _, n := range authData.ObjectTypes {
+		count += n
+	}
+	if count > uint(ioh.maxFiles) {
+		problems = append(problems, fmt.Sprintf("object-types: must have at most %d files", ioh.maxFiles))
+	}
+	for fileType, files := range authData.Files {
+		if uint(len(files)) > authData.ObjectTypes[fileType] {
+			problems = append(problems, fmt.Sprintf("files[%s]: there are more files than their number in object-types", fileType))
+		}
+	}
+	if len(problems) > 0 {
+		ioh.prepareInvalidResponse(ioDetails, problems)
+		return nil, nil, false
 	}
 	ioh.logger.Debugf("Extracted upload info: %+v", authData)
 	return &auth.UploadAccessReq{
 		AuthToken:   authData.AuthToken,
 		ObjectTypes: authData.ObjectTypes,
-	}, nil
+	}, authData.Files, true
+}
+
+// Return ID of the user that owns the auth token. If it couldn't, the error response
+// is sent to the client.
+func (rq *simpleReqHandler) identify(req *ReqDetails, authToken token.Token) (string, bool) {
+	userID, err := rq.auth.Identify(authToken)
+	if err != nil {
+		msg := fmt.Sprintf("Identifying the user error: %s", err.Error())
+		rq.prepareAuthErrResponse(req, err, msg, "Failed to identify the user")
+		return "", false
+	}
+	return userID, true
+}
+
+// Return ID of the user that uploads files by the auth token. If the auth service doesn't
+// support identifying users, it's empty, unless names of the files need it. If it
+// couldn't, the error response is sent to the client.
+func (rq *simpleReqHandler) uploaderID(req *ReqDetails, authToken token.Token) (string, bool) {
+	userID, err := rq.auth.Identify(authToken)
+	if err != nil && e.CodeOf(err) == auth.ErrNotImplemented && !rq.keyTemplate.NeedsUser() {
+		rq.logger.WithFields(e.LogFields(err)).Debugf("Uploader isn't identified: %s", err.Error())
+		return "", true
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Identifying the user error: %s", err.Error())
+		rq.prepareAuthErrResponse(req, err, msg, "Failed to identify the user")
+		return "", false
+	}
+	return userID, true
+}
+
+// Return whether the client with the auth token uploaded the file with the metadata.
+// The user is compared if the uploader is known, so new tokens of the same user are
+// accepted. If it couldn't, the error response is sent to the client.
+func (rq *simpleReqHandler) isUploader(req *ReqDetails, md metadata.Metadata, authToken token.Token) (bool, bool) {
+	var userID string
+	if md.UploadedBy() != "" {
+		var ok bool
+		if userID, ok = rq.identify(req, authToken); !ok {
+			return false, false
+		}
+	}
+	return md.IsUploadedBy(userID, authToken), true
+}
+
+// Result of the files that their object tokens aren't valid. (e.g. they're forged)
+var invalidTokenResult = fileResult{Status: fileNotFound, Error: "Invalid object token"}
+
+// Return keys of the files that the object tokens refer to, in the same order. Key of
+// an invalid token is empty. Valid keys are returned separately to check them by the
+// auth server.
+func (rq *simpleReqHandler) objectKeys(objectTokens []token.Token) (keys, validKeys []token.Token) {
+	keys = make([]token.Token, 0, len(objectTokens))
+	validKeys = make([]token.Token, 0, len(objectTokens))
+	for _, objectToken := range objectTokens {
+		key, err := rq.tokenCodec.Decode(objectToken)
+		if err != nil {
+			rq.logger.Debugf("Decoding object token %s error: %s", objectToken, err.Error())
+			key = ""
+		}
+		keys = append(keys, token.Token(key))
+		if key != "" {
+			validKeys = append(validKeys, token.Token(key))
+		}
+	}
+	return keys, validKeys
+}
+
+// Return key of the file that the object token refers to. If it couldn't, the error
+// response is sent to the client.
+func (rq *simpleReqHandler) objectKey(req *ReqDetails, objectToken token.Token) (string, bool) {
+	key, err := rq.tokenCodec.Decode(objectToken)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid object-token: %s", err.Error())
+		rq.prepareErrResponse(req, http.StatusBadRequest, msg, "Invalid object-token")
+		return "", false
+	}
+	return key, true
+}
+
+// Check the checksum of the file that is needed to name it by its content
+func (rq *simpleReqHandler) checkFileChecksum(fileInfo uploadFileReq) error {
+	if fileInfo.ChecksumSHA256 == "" {
+		if rq.keyTemplate.NeedsChecksum() {
+			return fmt.Errorf("checksum-sha256 of each file is required")
+		}
+		return nil
+	}
+	checksum, err := base64.StdEncoding.DecodeString(fileInfo.ChecksumSHA256)
+	if err != nil || len(checksum) != sha256.Size {
+		return fmt.Errorf("checksum-sha256 must be a SHA-256 checksum in base64")
+	}
+	return nil
+}
+
+// Create metadata of a file that the user with userID is going to upload. userID is
+// empty if the user isn't known.
+func prepareUploadMetadata(userID string, uploadBy token.Token, fileType file.FileExtension,
+	fileInfo uploadFileReq) (metadata.Metadata, error) {
+	var md metadata.Metadata
+	md.PrepareUploadMetadata(userID, uploadBy, strings.TrimSuffix(fileInfo.Name, "."+fileType.String()))
+	if err := md.AddLabels(fileInfo.Labels); err != nil {
+		return nil, err
+	}
+	return md, nil
 }
 
 func (rq *simpleReqHandler) prepareErrResponse(req *ReqDetails, statusCode int, devMsg, prodMsg string) {
@@ -230,3 +567,21 @@ func (rq *simpleReqHandler) prepareErrResponse(req *ReqDetails, statusCode int,
 		Message:    msg,
 	}, statusCode)
 }
+
+// Send the error response of a failed auth query. Its status shows whether the auth
+// token is invalid (401), the user is forbidden (403) or the auth service failed (5xx),
+// so clients could tell expired sessions apart from outages. The error is logged with
+// its fields.
+func (rq *simpleReqHandler) prepareAuthErrResponse(req *ReqDetails, err *e.Error, devMsg, prodMsg string) {
+	rq.logger.WithFields(e.LogFields(err)).Debugf(devMsg)
+	code := e.CodeOf(err)
+	switch code {
+	case e.Unauthorized:
+		prodMsg = "Invalid auth token"
+	case e.Forbidden:
+		prodMsg = "Access is forbidden"
+	case e.Unavailable:
+		prodMsg = "Auth service is unavailable"
+	}
+	rq.prepareErrResponse(req, code.HTTPStatus(), devMsg, prodMsg)
+}
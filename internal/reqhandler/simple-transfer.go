@@ -0,0 +1,170 @@
+package reqhandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/file"
+	"github.com/q-sharafian/file-transfer/internal/common/objectkey"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+)
+
+type transferReq struct {
+	AuthToken token.Token `json:"auth-token" validate:"required"`
+	Objects   []struct {
+		Source token.Token `json:"source" validate:"required"`
+		// If it's empty, a new name is created for the file like uploaded files.
+		Destination token.Token `json:"destination"`
+	} `json:"objects" validate:"required,min=1,maxfiles,dive"`
+}
+
+// Copy or move files to other tokens in the storage, based on the request type. Files
+// aren't downloaded and uploaded again.
+func (rq *simpleReqHandler) transferHandler(req *ReqDetails) {
+	var transReq transferReq
+	if !rq.readRequest(req, &transReq, &transReq.AuthToken) {
+		return
+	}
+	accessReq, destinations, ok := rq.prepareTransfers(req, &transReq)
+	if !ok {
+		return
+	}
+
+	var allowInfo []bool
+	var err2 *e.Error
+	if req.Type == Move {
+		allowInfo, err2 = rq.auth.IsAllowedMove(*accessReq)
+	} else {
+		allowInfo, err2 = rq.auth.IsAllowedCopy(*accessReq)
+	}
+	if err2 != nil {
+		msg := fmt.Sprintf("Checking transfer permission error: %s", err2.Error())
+		rq.prepareAuthErrResponse(req, err2, msg, "Failed to check transfer permission")
+		return
+	}
+
+	res := transferResponse{Results: make([]transferResult, 0, len(accessReq.Objects))}
+	for i, object := range accessReq.Objects {
+		result := fileResult{Status: fileForbidden, Error: "Transferring the file is not allowed"}
+		if allowInfo[i] {
+			result = rq.transferFile(object, req.Type == Move)
+		}
+		res.Results = append(res.Results, transferResult{
+			Source:      transReq.Objects[i].Source.String(),
+			Destination: destinations[i].String(),
+			fileResult:  result,
+		})
+	}
+	res.Message = "OK"
+	res.StatusCode = http.StatusOK
+	rq.setResponse(req, res, http.StatusOK)
+}
+
+// Check the requested transfers and convert them to an auth request that contains keys
+// of the files. Object tokens of the destinations are returned too. If it couldn't,
+// the error response is sent to the client.
+func (rq *simpleReqHandler) prepareTransfers(req *ReqDetails, transReq *transferReq) (*auth.TransferAccessReq,
+	[]token.Token, bool) {
+	accessReq := &auth.TransferAccessReq{AuthToken: transReq.AuthToken}
+	destinations := make([]token.Token, 0, len(transReq.Objects))
+	destKeys := make(map[string]bool)
+	var userID string
+	identified := false
+	for _, object := range transReq.Objects {
+		srcKey, err := rq.tokenCodec.Decode(object.Source)
+		if err != nil {
+			msg := fmt.Sprintf("Invalid transfer info: source %s is invalid", object.Source)
+			rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+			return nil, nil, false
+		}
+		fileType := file.ExtensionOf(srcKey)
+		destination := object.Destination
+		var dstKey string
+		if destination == "" {
+			if fileType == "" || rq.keyTemplate.NeedsChecksum() {
+				msg := fmt.Sprintf("Invalid transfer info: destination of %s is required", object.Source)
+				rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+				return nil, nil, false
+			}
+			if rq.keyTemplate.NeedsUser() && !identified {
+				if userID, identified = rq.identify(req, transReq.AuthToken); !identified {
+					return nil, nil, false
+				}
+			}
+			fileName, err := rq.keyTemplate.FileName(objectkey.KeyInfo{
+				UserID:     userID,
+				FileType:   fileType,
+				UploadedAt: time.Now().UTC(),
+			})
+			if err == nil {
+				dstKey = fmt.Sprintf("%s.%s", fileName, fileType.String())
+				destination, err = rq.tokenCodec.Encode(dstKey)
+			}
+			if err != nil {
+				msg := fmt.Sprintf("Creating destination of %s failed: %s", object.Source, err.Error())
+				rq.logger.Debugf(msg)
+				rq.prepareErrResponse(req, http.StatusInternalServerError, msg, "Failed to create destination of the file")
+				return nil, nil, false
+			}
+		} else if dstKey, err = rq.tokenCodec.Decode(destination); err != nil {
+			msg := fmt.Sprintf("Invalid transfer info: destination %s is invalid", destination)
+			rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+			return nil, nil, false
+		}
+
+		var problem string
+		switch {
+		case srcKey == dstKey:
+			problem = fmt.Sprintf("source and destination of %s are the same", object.Source)
+		// Otherwise, files could bypass the allowed upload types
+		case fileType != file.ExtensionOf(dstKey):
+			problem = fmt.Sprintf("extension of destination %s differs from its source", destination)
+		case destKeys[dstKey]:
+			problem = fmt.Sprintf("destination %s is repeated", destination)
+		}
+		if problem != "" {
+			msg := fmt.Sprintf("Invalid transfer info: %s", problem)
+			rq.prepareErrResponse(req, http.StatusBadRequest, msg, msg)
+			return nil, nil, false
+		}
+		destKeys[dstKey] = true
+		destinations = append(destinations, destination)
+		accessReq.Objects = append(accessReq.Objects, auth.ObjectTransfer{
+			Source: token.Token(srcKey), Destination: token.Token(dstKey),
+		})
+	}
+	return accessReq, destinations, true
+}
+
+// Copy/move the file that the client is allowed to transfer. Existing files aren't
+// overwritten.
+func (rq *simpleReqHandler) transferFile(object auth.ObjectTransfer, move bool) fileResult {
+	_, err := rq.storage.GetMetadata(object.Destination.String())
+	if err == nil {
+		return fileResult{Status: fileExists, Error: "Destination already exists"}
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		if move {
+			err = rq.storage.MoveFile(object.Source.String(), object.Destination.String())
+		} else {
+			err = rq.storage.CopyFile(object.Source.String(), object.Destination.String())
+		}
+		if err == nil {
+			return fileResult{Status: fileOK}
+		}
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return fileResult{Status: fileNotFound, Error: "File not found"}
+	}
+	msg := fmt.Sprintf("Transferring the file failed: %s", err.Error())
+	rq.logger.Debugf(msg)
+	if !rq.isDevEnv {
+		msg = "Failed to transfer the file"
+	}
+	return fileResult{Status: fileError, Error: msg}
+}
@@ -0,0 +1,71 @@
+package reqhandler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestDeletePerTokenResults(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	for _, key := range []string{"alice/a.pdf", "bob/a.pdf"} {
+		memory.PutObject(key, []byte(key), nil)
+	}
+	a := auth.NewMemoryAuth().AllowDelete("alice-token", "alice/a.pdf")
+	h := newTestHandler(t, a, memory)
+
+	allowed, forbidden := objectToken(t, "alice/a.pdf"), objectToken(t, "bob/a.pdf")
+	var res deleteResponse
+	code := serve(t, h, Delete, http.MethodDelete, jsonBody(t, map[string]any{
+		"auth-token": "alice-token", "object-tokens": []token.Token{allowed, forbidden, "forged"},
+	}), &res)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", code, http.StatusOK, res.Message)
+	}
+	want := map[token.Token]fileStatus{allowed: fileOK, forbidden: fileForbidden, "forged": fileNotFound}
+	for objectToken, status := range want {
+		if got := res.Tokens2Results[objectToken.String()].Status; got != status {
+			t.Errorf("status of %s = %q, want %q", objectToken, got, status)
+		}
+	}
+	if _, ok := memory.Object("alice/a.pdf"); ok {
+		t.Error("the allowed file isn't deleted")
+	}
+	if _, ok := memory.Object("bob/a.pdf"); !ok {
+		t.Error("the forbidden file is deleted")
+	}
+}
+
+func TestDeleteInvalidRequests(t *testing.T) {
+	allowed := objectToken(t, "alice/a.pdf").String()
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"without object tokens", http.MethodDelete, `{"auth-token": "alice", "object-tokens": []}`, http.StatusBadRequest},
+		{"invalid JSON", http.MethodDelete, `{"auth-token": `, http.StatusBadRequest},
+		{"unknown auth token", http.MethodDelete, `{"auth-token": "unknown", "object-tokens": ["` + allowed + `"]}`,
+			http.StatusUnauthorized},
+		{"POST method", http.MethodPost, `{"auth-token": "alice", "object-tokens": ["` + allowed + `"]}`,
+			http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := storage.NewMemoryStorage()
+			memory.PutObject("alice/a.pdf", []byte("content"), nil)
+			h := newTestHandler(t, auth.NewMemoryAuth().AllowDelete("alice", "alice/a.pdf"), memory)
+			var res deleteResponse
+			if code := serve(t, h, Delete, tt.method, tt.body, &res); code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", code, tt.wantStatus, res.Message)
+			}
+			if _, ok := memory.Object("alice/a.pdf"); !ok {
+				t.Error("file is deleted by an invalid request")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package reqhandler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestFilesPagination(t *testing.T) {
+	// Files are listed just if they're stored under the user IDs
+	t.Setenv("OBJECT_KEY_TEMPLATE", "{user}/{uuid}.{ext}")
+	memory := storage.NewMemoryStorage()
+	var md metadata.Metadata
+	md.PrepareUploadMetadata("alice", "alice", "report")
+	for _, key := range []string{"user-1/a.pdf", "user-1/b.pdf", "user-1/c.pdf", "user-10/d.pdf", "user-2/e.pdf"} {
+		memory.PutObject(key, []byte(key), md)
+	}
+	h := newTestHandler(t, auth.NewMemoryAuth().SetUserID("alice", "user-1"), memory)
+
+	var keys []string
+	cursor := ""
+	for page := 0; ; page++ {
+		if page == 3 {
+			t.Fatalf("listing doesn't end after %d pages", page)
+		}
+		var res filesResponse
+		req := map[string]any{"auth-token": "alice", "cursor": cursor, "limit": 2}
+		if code := serve(t, h, Files, http.MethodGet, jsonBody(t, req), &res); code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", code, http.StatusOK, res.Message)
+		}
+		if len(res.Files) > 2 {
+			t.Fatalf("got %d files, want at most the limit", len(res.Files))
+		}
+		for _, f := range res.Files {
+			key := objectKey(t, f.ObjectToken)
+			keys = append(keys, key)
+			if f.Size != uint64(len(key)) || f.RealName != "report.pdf" || f.UploadedBy != "alice" {
+				t.Errorf("listed file = %+v, want its size and metadata", f)
+			}
+		}
+		if res.NextCursor == "" {
+			break
+		}
+		cursor = res.NextCursor
+	}
+	// Files of other users aren't listed, even if their IDs begin with the user ID
+	want := []string{"user-1/a.pdf", "user-1/b.pdf", "user-1/c.pdf"}
+	if len(keys) != len(want) {
+		t.Fatalf("listed files = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("listed files = %v, want %v", keys, want)
+			break
+		}
+	}
+}
+
+func TestFilesInvalidRequests(t *testing.T) {
+	t.Setenv("OBJECT_KEY_TEMPLATE", "{user}/{uuid}.{ext}")
+	tests := []struct {
+		name       string
+		method     string
+		req        map[string]any
+		wantStatus int
+	}{
+		{"default limit", http.MethodGet, map[string]any{"auth-token": "alice"}, http.StatusOK},
+		{"maximum limit", http.MethodGet, map[string]any{"auth-token": "alice", "limit": 1000}, http.StatusOK},
+		{"negative limit", http.MethodGet, map[string]any{"auth-token": "alice", "limit": -1}, http.StatusBadRequest},
+		{"too large limit", http.MethodGet, map[string]any{"auth-token": "alice", "limit": 1001}, http.StatusBadRequest},
+		{"unknown auth token", http.MethodGet, map[string]any{"auth-token": "unknown"}, http.StatusUnauthorized},
+		{"POST method", http.MethodPost, map[string]any{"auth-token": "alice"}, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := storage.NewMemoryStorage()
+			memory.PutObject("alice/a.pdf", []byte("content"), nil)
+			h := newTestHandler(t, auth.NewMemoryAuth().SetUserID("alice", "alice"), memory)
+			var res filesResponse
+			if code := serve(t, h, Files, tt.method, jsonBody(t, tt.req), &res); code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", code, tt.wantStatus, res.Message)
+			}
+			if tt.wantStatus == http.StatusOK && (len(res.Files) != 1 || objectKey(t, res.Files[0].ObjectToken) != "alice/a.pdf") {
+				t.Errorf("files = %+v, want the file of the user", res.Files)
+			}
+		})
+	}
+}
+
+func TestFilesWithoutUserDirectory(t *testing.T) {
+	tests := []struct {
+		pattern string
+	}{
+		{"{uuid}.{ext}"},
+		// Dates before the user change, so files of the user don't have a fixed prefix
+		{"{yyyy}/{user}/{uuid}.{ext}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			t.Setenv("OBJECT_KEY_TEMPLATE", tt.pattern)
+			memory := storage.NewMemoryStorage()
+			memory.PutObject("a.pdf", []byte("content"), nil)
+			h := newTestHandler(t, auth.NewMemoryAuth().SetUserID("alice", "alice"), memory)
+			var res filesResponse
+			req := map[string]any{"auth-token": "alice"}
+			if code := serve(t, h, Files, http.MethodGet, jsonBody(t, req), &res); code != http.StatusNotImplemented {
+				t.Errorf("status = %d, want %d: %s", code, http.StatusNotImplemented, res.Message)
+			}
+			if len(res.Files) != 0 {
+				t.Errorf("files = %+v, want none", res.Files)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package reqhandler
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestFinalize(t *testing.T) {
+	content := []byte("content")
+	md5Hash := md5.Sum(content)
+	sha256Hash := sha256.Sum256(content)
+	checksumMD5 := hex.EncodeToString(md5Hash[:])
+	checksumSHA256 := base64.StdEncoding.EncodeToString(sha256Hash[:])
+	otherMD5 := md5.Sum([]byte("other"))
+	otherSHA256 := sha256.Sum256([]byte("other"))
+	wrongMD5 := hex.EncodeToString(otherMD5[:])
+	wrongSHA256 := base64.StdEncoding.EncodeToString(otherSHA256[:])
+
+	tests := []struct {
+		name string
+		// Key and content of the stored file. The file isn't stored if the key is empty.
+		key        string
+		data       []byte
+		req        map[string]any
+		wantStatus int
+		// Whether the file should be stored after the request
+		wantKept bool
+	}{
+		{"valid file", "a.pdf", content,
+			map[string]any{"size": 7, "checksum-md5": strings.ToUpper(checksumMD5), "checksum-sha256": checksumSHA256},
+			http.StatusOK, true},
+		{"without details", "a.pdf", content, map[string]any{}, http.StatusOK, true},
+		{"wrong size", "a.pdf", content, map[string]any{"size": 8}, http.StatusUnprocessableEntity, false},
+		{"wrong MD5 hash", "a.pdf", content, map[string]any{"checksum-md5": wrongMD5},
+			http.StatusUnprocessableEntity, false},
+		{"wrong SHA-256 checksum", "a.pdf", content, map[string]any{"checksum-sha256": wrongSHA256},
+			http.StatusUnprocessableEntity, false},
+		{"larger than maximum size", "a.pdf", make([]byte, 1025), map[string]any{},
+			http.StatusUnprocessableEntity, false},
+		{"type isn't allowed", "a.png", content, map[string]any{}, http.StatusUnprocessableEntity, false},
+		{"not uploaded", "", nil, map[string]any{}, http.StatusNotFound, false},
+		{"without object token", "a.pdf", content, map[string]any{"object-token": ""}, http.StatusBadRequest, true},
+		{"invalid object token", "a.pdf", content, map[string]any{"object-token": "a.pdf"}, http.StatusBadRequest, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := storage.NewMemoryStorage()
+			h := newTestHandler(t, auth.NewMemoryAuth().AllowUpload("alice", "pdf", 1), memory)
+			key := tt.key
+			if key != "" {
+				var md metadata.Metadata
+				md.PrepareUploadMetadata("alice", "alice", "")
+				memory.PutObject(key, tt.data, md)
+			} else {
+				key = "a.pdf"
+			}
+			tt.req["auth-token"] = "alice"
+			if _, ok := tt.req["object-token"]; !ok {
+				tt.req["object-token"] = objectToken(t, key)
+			}
+
+			var res finalizeResponse
+			code := serve(t, h, Finalize, http.MethodPost, jsonBody(t, tt.req), &res)
+			if code != tt.wantStatus || res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%d), want %d: %s", code, res.StatusCode, tt.wantStatus, res.Message)
+			}
+			if tt.wantStatus == http.StatusUnprocessableEntity && len(res.Problems) == 0 {
+				t.Errorf("rejected file has no problems")
+			}
+			object, ok := memory.Object(key)
+			if ok != tt.wantKept {
+				t.Errorf("file is stored = %v, want %v", ok, tt.wantKept)
+			}
+			if ok && object.Finalized != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("file is finalized = %v, status %d", object.Finalized, code)
+			}
+		})
+	}
+}
+
+func TestFinalizeByAnotherClient(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	a := auth.NewMemoryAuth().AllowUpload("alice", "pdf", 0).AllowUpload("bob", "pdf", 0).
+		AllowUpload("alice-2", "pdf", 0).SetUserID("alice-2", "alice")
+	h := newTestHandler(t, a, memory)
+	var md metadata.Metadata
+	md.PrepareUploadMetadata("alice", "alice", "")
+	memory.PutObject("a.pdf", []byte("content"), md)
+
+	var res finalizeResponse
+	req := map[string]any{"auth-token": "bob", "object-token": objectToken(t, "a.pdf"), "size": 1}
+	if code := serve(t, h, Finalize, http.MethodPost, jsonBody(t, req), &res); code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d: %s", code, http.StatusForbidden, res.Message)
+	}
+	if object, ok := memory.Object("a.pdf"); !ok || object.Finalized {
+		t.Errorf("file of another client is deleted or finalized")
+	}
+
+	// Another token of the uploader could finalize the file. Finalizing a finalized file
+	// again isn't an error.
+	req["size"] = 7
+	for _, authToken := range []string{"alice-2", "alice"} {
+		req["auth-token"] = authToken
+		if code := serve(t, h, Finalize, http.MethodPost, jsonBody(t, req), &res); code != http.StatusOK {
+			t.Errorf("status = %d, want %d: %s", code, http.StatusOK, res.Message)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package reqhandler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestMetadata(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	md, err := prepareUploadMetadata("alice", "alice", "pdf", uploadFileReq{
+		Name: "report.pdf", Labels: map[string]string{"Project": "x"},
+	})
+	if err != nil {
+		t.Fatalf("prepareUploadMetadata() error = %v", err)
+	}
+	md["Internal"] = "secret"
+	memory.PutObject("alice/a.pdf", []byte("content"), md)
+	memory.PutObject("alice/b.pdf", []byte("content"), metadata.Metadata{})
+	memory.PutObject("alice/c.pdf", []byte("content"), metadata.Metadata{})
+	a := auth.NewMemoryAuth().AllowDownload("alice", "alice/a.pdf", "alice/b.pdf", "alice/missing.pdf").
+		AllowDownload("bob", "alice/a.pdf")
+	h := newTestHandler(t, a, memory)
+
+	tests := []struct {
+		name      string
+		authToken string
+		// Keys of the requested files
+		objects []string
+		// Metadata of each key. nil means the response must contain null.
+		want map[string]*fileMetadata
+	}{
+		{"uploader", "alice", []string{"alice/a.pdf"}, map[string]*fileMetadata{
+			"alice/a.pdf": {RealName: "report.pdf", UploadedBy: "alice", Labels: map[string]string{"project": "x"}},
+		}},
+		{"another client", "bob", []string{"alice/a.pdf"}, map[string]*fileMetadata{
+			"alice/a.pdf": {RealName: "report.pdf", UploadedBy: "alice", Labels: map[string]string{"project": "x"}},
+		}},
+		{"without metadata", "alice", []string{"alice/b.pdf"}, map[string]*fileMetadata{
+			"alice/b.pdf": {Labels: map[string]string{}},
+		}},
+		{"not allowed and missing files", "alice", []string{"alice/c.pdf", "alice/missing.pdf"}, map[string]*fileMetadata{
+			"alice/c.pdf": nil, "alice/missing.pdf": nil,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res metadataResponse
+			objectTokens := make([]token.Token, 0, len(tt.objects))
+			for _, key := range tt.objects {
+				objectTokens = append(objectTokens, objectToken(t, key))
+			}
+			req := map[string]any{"auth-token": tt.authToken, "object-tokens": objectTokens}
+			if code := serve(t, h, Metadata, http.MethodGet, jsonBody(t, req), &res); code != http.StatusOK {
+				t.Fatalf("status = %d: %s", code, res.Message)
+			}
+			if len(res.Tokens2Metadata) != len(tt.want) {
+				t.Fatalf("tokens2metadata = %v, want %d tokens", res.Tokens2Metadata, len(tt.want))
+			}
+			for key, want := range tt.want {
+				got, ok := res.Tokens2Metadata[objectToken(t, key).String()]
+				if !ok || (got == nil) != (want == nil) {
+					t.Fatalf("metadata of %s = %+v, want %+v", key, got, want)
+				}
+				if got == nil {
+					continue
+				}
+				if got.RealName != want.RealName || got.UploadedBy != want.UploadedBy || len(got.Labels) != len(want.Labels) ||
+					got.Labels["project"] != want.Labels["project"] {
+					t.Errorf("metadata of %s = %+v, want %+v", key, got, want)
+				}
+				if want.RealName != "" && time.Since(time.Unix(got.UploadedAt, 0)) > time.Minute {
+					t.Errorf("uploaded-at = %d, want the upload time", got.UploadedAt)
+				}
+			}
+		})
+	}
+
+	var res metadataResponse
+	req := map[string]any{"auth-token": "alice", "object-tokens": []token.Token{objectToken(t, "alice/a.pdf")}}
+	if code := serve(t, h, Metadata, http.MethodPost, jsonBody(t, req), &res); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+}
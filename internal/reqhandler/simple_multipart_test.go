@@ -0,0 +1,213 @@
+package reqhandler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestMultipartCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        map[string]any
+		wantStatus int
+	}{
+		{"allowed", map[string]any{"object-type": "pdf", "size": 2048, "part-count": 2}, http.StatusOK},
+		{"without size", map[string]any{"object-type": "pdf", "part-count": 1}, http.StatusOK},
+		{"larger than maximum size", map[string]any{"object-type": "pdf", "size": 2049, "part-count": 2},
+			http.StatusRequestEntityTooLarge},
+		{"type isn't allowed", map[string]any{"object-type": "png", "size": 10, "part-count": 1}, http.StatusForbidden},
+		{"without type", map[string]any{"size": 10, "part-count": 1}, http.StatusBadRequest},
+		{"without parts", map[string]any{"object-type": "pdf", "size": 10, "part-count": 0}, http.StatusBadRequest},
+		{"too many parts", map[string]any{"object-type": "pdf", "size": 10, "part-count": 10001}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := storage.NewMemoryStorage()
+			h := newTestHandler(t, auth.NewMemoryAuth().AllowUpload("alice", "pdf", 2), memory)
+			tt.req["auth-token"] = "alice"
+			var res multipartResponse
+			code := serve(t, h, MultipartCreate, http.MethodPost, jsonBody(t, tt.req), &res)
+			if code != tt.wantStatus || res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%d), want %d: %s", code, res.StatusCode, tt.wantStatus, res.Message)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if res.UploadID != "" || len(memory.Links()) != 0 {
+					t.Errorf("upload is created for a rejected request")
+				}
+				return
+			}
+			if !strings.HasSuffix(objectKey(t, res.ObjectToken), ".pdf") || res.UploadID == "" {
+				t.Errorf("object token = %q, upload id = %q", res.ObjectToken, res.UploadID)
+			}
+			if len(res.Parts) != tt.req["part-count"] {
+				t.Fatalf("got %d parts, want %v", len(res.Parts), tt.req["part-count"])
+			}
+			for i, part := range res.Parts {
+				if part.PartNumber != int32(i+1) || part.URL == "" {
+					t.Errorf("part %d = %+v, want a link of part %d", i, part, i+1)
+				}
+			}
+		})
+	}
+}
+
+// Upload the data with the link of the part and return its ETag
+func uploadPart(t *testing.T, memory *storage.MemoryStorage, part partLink, data string) string {
+	t.Helper()
+	link, err := url.Parse(part.URL)
+	if err != nil {
+		t.Fatalf("invalid link of part %d: %v", part.PartNumber, err)
+	}
+	etag, err := memory.Upload(*link, []byte(data))
+	if err != nil {
+		t.Fatalf("uploading part %d error = %v", part.PartNumber, err)
+	}
+	return etag
+}
+
+func TestMultipartUpload(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	h := newTestHandler(t, auth.NewMemoryAuth().AllowUpload("alice", "pdf", 0).AllowUpload("bob", "pdf", 0), memory)
+	var created multipartResponse
+	serve(t, h, MultipartCreate, http.MethodPost, jsonBody(t, map[string]any{
+		"auth-token": "alice", "object-type": "pdf", "part-count": 2,
+	}), &created)
+	firstETag := uploadPart(t, memory, created.Parts[0], "first-")
+
+	step := func(typ ioType, req map[string]any) (int, multipartResponse) {
+		t.Helper()
+		if _, ok := req["auth-token"]; !ok {
+			req["auth-token"] = "alice"
+		}
+		if _, ok := req["object-token"]; !ok {
+			req["object-token"] = created.ObjectToken
+		}
+		if _, ok := req["upload-id"]; !ok {
+			req["upload-id"] = created.UploadID
+		}
+		var res multipartResponse
+		code := serve(t, h, typ, http.MethodPost, jsonBody(t, req), &res)
+		return code, res
+	}
+
+	// Links of parts could be created again to resume the upload.
+	code, res := step(MultipartParts, map[string]any{"part-numbers": []int32{2}})
+	if code != http.StatusOK || len(res.Parts) != 1 || res.Parts[0].PartNumber != 2 {
+		t.Fatalf("creating links of parts = %d, %+v", code, res)
+	}
+	secondETag := uploadPart(t, memory, res.Parts[0], "second")
+
+	tests := []struct {
+		name       string
+		typ        ioType
+		req        map[string]any
+		wantStatus int
+	}{
+		{"links of invalid parts", MultipartParts, map[string]any{"part-numbers": []int32{0}}, http.StatusBadRequest},
+		{"links of no parts", MultipartParts, map[string]any{"part-numbers": []int32{}}, http.StatusBadRequest},
+		{"without upload id", MultipartParts, map[string]any{"upload-id": "", "part-numbers": []int32{1}},
+			http.StatusBadRequest},
+		{"type isn't allowed", MultipartParts, map[string]any{
+			"object-token": objectToken(t, "alice/a.png"), "part-numbers": []int32{1},
+		}, http.StatusForbidden},
+		{"invalid object token", MultipartParts, map[string]any{
+			"object-token": objectKey(t, created.ObjectToken), "part-numbers": []int32{1},
+		}, http.StatusBadRequest},
+		{"unknown upload", MultipartParts, map[string]any{"upload-id": "unknown", "part-numbers": []int32{1}},
+			http.StatusNotFound},
+		// Just the client that created the upload could use it
+		{"links by another client", MultipartParts, map[string]any{"auth-token": "bob", "part-numbers": []int32{1}},
+			http.StatusForbidden},
+		{"complete by another client", MultipartComplete, map[string]any{"auth-token": "bob", "parts": []map[string]any{
+			{"part-number": 1, "etag": firstETag}, {"part-number": 2, "etag": secondETag},
+		}}, http.StatusForbidden},
+		{"abort by another client", MultipartAbort, map[string]any{"auth-token": "bob"}, http.StatusForbidden},
+		{"complete without parts", MultipartComplete, map[string]any{}, http.StatusBadRequest},
+		{"complete with wrong ETag", MultipartComplete, map[string]any{"parts": []map[string]any{
+			{"part-number": 1, "etag": firstETag}, {"part-number": 2, "etag": firstETag},
+		}}, http.StatusInternalServerError},
+		{"complete", MultipartComplete, map[string]any{"parts": []map[string]any{
+			{"part-number": 1, "etag": firstETag}, {"part-number": 2, "etag": secondETag},
+		}}, http.StatusOK},
+		{"complete again", MultipartComplete, map[string]any{"parts": []map[string]any{
+			{"part-number": 1, "etag": firstETag}, {"part-number": 2, "etag": secondETag},
+		}}, http.StatusNotFound},
+		{"abort completed upload", MultipartAbort, map[string]any{}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, res := step(tt.typ, tt.req)
+			if code != tt.wantStatus || res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d (%d), want %d: %s", code, res.StatusCode, tt.wantStatus, res.Message)
+			}
+		})
+	}
+
+	object, ok := memory.Object(objectKey(t, created.ObjectToken))
+	if !ok || string(object.Data) != "first-second" {
+		t.Errorf("completed file = %q, want the parts in order", object.Data)
+	}
+}
+
+func TestMultipartAbort(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	h := newTestHandler(t, auth.NewMemoryAuth().AllowUpload("alice", "pdf", 0), memory)
+	var created multipartResponse
+	serve(t, h, MultipartCreate, http.MethodPost, jsonBody(t, map[string]any{
+		"auth-token": "alice", "object-type": "pdf", "part-count": 1,
+	}), &created)
+	etag := uploadPart(t, memory, created.Parts[0], "content")
+
+	upload := map[string]any{"auth-token": "alice", "object-token": created.ObjectToken, "upload-id": created.UploadID}
+	var res multipartResponse
+	if code := serve(t, h, MultipartAbort, http.MethodPost, jsonBody(t, upload), &res); code != http.StatusOK {
+		t.Fatalf("aborting status = %d: %s", code, res.Message)
+	}
+	upload["parts"] = []map[string]any{{"part-number": 1, "etag": etag}}
+	if code := serve(t, h, MultipartComplete, http.MethodPost, jsonBody(t, upload), &res); code == http.StatusOK {
+		t.Errorf("completing an aborted upload status = %d", code)
+	}
+	if _, ok := memory.Object(objectKey(t, created.ObjectToken)); ok {
+		t.Error("aborted upload is stored")
+	}
+}
+
+func TestMultipartCompleteTooLarge(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantStatus int
+	}{
+		{"allowed size", strings.Repeat("x", 1024), http.StatusOK},
+		// Links of the parts don't limit their size
+		{"larger than maximum size", strings.Repeat("x", 1025), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := storage.NewMemoryStorage()
+			h := newTestHandler(t, auth.NewMemoryAuth().AllowUpload("alice", "pdf", 1), memory)
+			var created multipartResponse
+			serve(t, h, MultipartCreate, http.MethodPost, jsonBody(t, map[string]any{
+				"auth-token": "alice", "object-type": "pdf", "part-count": 1,
+			}), &created)
+			etag := uploadPart(t, memory, created.Parts[0], tt.data)
+
+			var res multipartResponse
+			code := serve(t, h, MultipartComplete, http.MethodPost, jsonBody(t, map[string]any{
+				"auth-token": "alice", "object-token": created.ObjectToken, "upload-id": created.UploadID,
+				"parts": []map[string]any{{"part-number": 1, "etag": etag}},
+			}), &res)
+			if code != tt.wantStatus || res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%d), want %d: %s", code, res.StatusCode, tt.wantStatus, res.Message)
+			}
+			if _, ok := memory.Object(objectKey(t, created.ObjectToken)); ok != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("completed file is stored = %v, want %v", ok, tt.wantStatus == http.StatusOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package reqhandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/server"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestDownloadRedirect(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	memory.PutObject("alice/a.pdf", []byte("content"), nil)
+	memory.PutObject("bob/a.pdf", []byte("content"), nil)
+	h := newTestHandler(t, auth.NewMemoryAuth().AllowDownload("alice", "alice/a.pdf", "alice/gone.pdf"), memory)
+
+	tests := []struct {
+		name        string
+		method      string
+		objectToken token.Token
+		query       url.Values
+		wantStatus  int
+		// Content disposition of the download link. It's checked just if the client is redirected.
+		wantDisposition string
+	}{
+		{"attachment", http.MethodGet, objectToken(t, "alice/a.pdf"), url.Values{},
+			http.StatusTemporaryRedirect, "attachment; filename=a.pdf"},
+		{"inline", http.MethodGet, objectToken(t, "alice/a.pdf"), url.Values{"disposition": {"inline"}},
+			http.StatusTemporaryRedirect, "inline; filename=a.pdf"},
+		{"invalid disposition", http.MethodGet, objectToken(t, "alice/a.pdf"), url.Values{"disposition": {"download"}},
+			http.StatusBadRequest, ""},
+		{"without object token", http.MethodGet, "", url.Values{}, http.StatusBadRequest, ""},
+		{"invalid object token", http.MethodGet, "alice/a.pdf", url.Values{}, http.StatusBadRequest, ""},
+		{"forbidden", http.MethodGet, objectToken(t, "bob/a.pdf"), url.Values{}, http.StatusForbidden, ""},
+		{"allowed but not stored", http.MethodGet, objectToken(t, "alice/gone.pdf"), url.Values{}, http.StatusNotFound, ""},
+		{"POST method", http.MethodPost, objectToken(t, "alice/a.pdf"), url.Values{}, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/download/x?"+tt.query.Encode(), nil)
+			r.SetPathValue(ObjectTokenWildcard, tt.objectToken.String())
+			r.Header.Set("Authorization", "Bearer alice")
+			w := httptest.NewRecorder()
+			linkCount := len(memory.Links())
+			h.HandleRequest(&ReqDetails{Type: DownloadRedirect, ResponseWriter: w, Request: &server.Request{Request: r}})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusTemporaryRedirect {
+				if location := w.Header().Get("Location"); location != "" {
+					t.Errorf("location = %q, want no redirect", location)
+				}
+				return
+			}
+
+			links := memory.Links()
+			if len(links) != linkCount+1 {
+				t.Fatalf("got %d new links, want 1", len(links)-linkCount)
+			}
+			link := links[len(links)-1]
+			if location := w.Header().Get("Location"); location != link.URL.String() {
+				t.Errorf("location = %q, want the download link %q", location, link.URL.String())
+			}
+			if link.Key != "alice/a.pdf" || link.ContentDisposition != tt.wantDisposition {
+				t.Errorf("link of %s with disposition %q, want alice/a.pdf with %q",
+					link.Key, link.ContentDisposition, tt.wantDisposition)
+			}
+			// The link depends on the auth token, so shared caches mustn't keep it
+			if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "private, max-age=30" {
+				t.Errorf("cache-control = %q, want %q", cacheControl, "private, max-age=30")
+			}
+		})
+	}
+}
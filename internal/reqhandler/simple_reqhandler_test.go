@@ -0,0 +1,236 @@
+package reqhandler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/objecttoken"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/server"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+	l "github.com/q-sharafian/file-transfer/pkg/logger"
+)
+
+var testTokenSecret = strings.Repeat("s", 32)
+
+// Create the request handler with the test settings. Object tokens are signed, so
+// invalid tokens could be tested.
+func newTestHandler(t *testing.T, a auth.Auth, s storage.Storage) ReqHandler {
+	t.Helper()
+	t.Setenv("APP_MODE", "development")
+	t.Setenv("UPLOAD_EXPIRE_TIME", "60")
+	t.Setenv("DOWNLOAD_EXPIRE_TIME", "60")
+	t.Setenv("OBJECT_TOKEN_KEYS", "k1:"+base64.StdEncoding.EncodeToString([]byte(testTokenSecret)))
+	return NewSimpleReqHandler(a, s, l.NewSLogger(l.Error, nil, os.Stderr))
+}
+
+// Return the codec that the test handler uses for object tokens
+func testTokenCodec(t *testing.T) objecttoken.Codec {
+	t.Helper()
+	codec, err := objecttoken.NewSignedCodec([]objecttoken.Key{{ID: "k1", Secret: []byte(testTokenSecret)}}, false)
+	if err != nil {
+		t.Fatalf("NewSignedCodec() error = %v", err)
+	}
+	return codec
+}
+
+// Return the object token of the key that the test handler issues
+func objectToken(t *testing.T, key string) token.Token {
+	t.Helper()
+	objectToken, err := testTokenCodec(t).Encode(key)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	return objectToken
+}
+
+// Return the key that the object token issued by the test handler refers to
+func objectKey(t *testing.T, objectToken string) string {
+	t.Helper()
+	key, err := testTokenCodec(t).Decode(token.Token(objectToken))
+	if err != nil {
+		t.Fatalf("Decode(%q) error = %v", objectToken, err)
+	}
+	return key
+}
+
+// Send the request with the JSON body to the handler and decode its response into v
+func serve(t *testing.T, h ReqHandler, typ ioType, method, body string, v any) int {
+	t.Helper()
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.HandleRequest(&ReqDetails{Type: typ, ResponseWriter: w, Request: &server.Request{Request: r}})
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode the response %q: %v", w.Body.String(), err)
+	}
+	return w.Code
+}
+
+func jsonBody(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal the request: %v", err)
+	}
+	return string(data)
+}
+
+func TestDownloadDisposition(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	md, _ := prepareUploadMetadata("alice", "alice", "pdf", uploadFileReq{Name: "report.pdf"})
+	memory.PutObject("alice/a.pdf", []byte("content"), md)
+	h := newTestHandler(t, auth.NewMemoryAuth().AllowDownload("bob", "alice/a.pdf"), memory)
+	objectToken := objectToken(t, "alice/a.pdf")
+
+	tests := []struct {
+		name            string
+		disposition     string
+		wantStatus      int
+		wantDisposition string
+	}{
+		{"default", "", http.StatusOK, "attachment; filename=report.pdf"},
+		{"attachment", "attachment", http.StatusOK, "attachment; filename=report.pdf"},
+		{"inline", "inline", http.StatusOK, "inline; filename=report.pdf"},
+		{"invalid", "download", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := map[string]any{"auth-token": "bob", "object-tokens": []token.Token{objectToken}, "disposition": tt.disposition}
+			var res downlaodResponse
+			if code := serve(t, h, Download, http.MethodGet, jsonBody(t, req), &res); code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", code, tt.wantStatus, res.Message)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			links := memory.Links()
+			link := links[len(links)-1]
+			if res.Tokens2URLs[objectToken.String()] != link.URL.String() {
+				t.Errorf("tokens2urls = %v, want the download link", res.Tokens2URLs)
+			}
+			if link.ContentDisposition != tt.wantDisposition || link.ContentType != "application/pdf" {
+				t.Errorf("link headers = %q, %q, want %q, application/pdf",
+					link.ContentDisposition, link.ContentType, tt.wantDisposition)
+			}
+		})
+	}
+}
+
+// A storage that fails to create download links of a file
+type failingStorage struct {
+	*storage.MemoryStorage
+	failedKey string
+}
+
+func (s failingStorage) DownloadFile(fileInfo storage.DownloadFileInfo, expireTime time.Duration) (url.URL, error) {
+	if fileInfo.FileName == s.failedKey {
+		return url.URL{}, errors.New("storage is unavailable")
+	}
+	return s.MemoryStorage.DownloadFile(fileInfo, expireTime)
+}
+
+func TestDownloadPerTokenResults(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	for _, key := range []string{"alice/a.pdf", "alice/b.pdf", "alice/broken.pdf", "bob/a.pdf"} {
+		memory.PutObject(key, []byte(key), nil)
+	}
+	a := auth.NewMemoryAuth().AllowDownload("alice-token", "alice/a.pdf", "alice/b.pdf", "alice/gone.pdf", "alice/broken.pdf")
+	h := newTestHandler(t, a, failingStorage{memory, "alice/broken.pdf"})
+
+	tests := []struct {
+		name        string
+		objectToken token.Token
+		wantStatus  fileStatus
+		wantError   string
+	}{
+		{"allowed", objectToken(t, "alice/a.pdf"), fileOK, ""},
+		{"another allowed file", objectToken(t, "alice/b.pdf"), fileOK, ""},
+		{"forbidden", objectToken(t, "bob/a.pdf"), fileForbidden, "Downloading the file is not allowed"},
+		{"allowed but not stored", objectToken(t, "alice/gone.pdf"), fileNotFound, "File not found"},
+		{"storage failed", objectToken(t, "alice/broken.pdf"), fileError, "storage is unavailable"},
+		{"forged token", "k1.forged.token", fileNotFound, "Invalid object token"},
+		{"raw key", "alice/a.pdf", fileNotFound, "Invalid object token"},
+	}
+	objectTokens := make([]token.Token, 0, len(tests))
+	for _, tt := range tests {
+		objectTokens = append(objectTokens, tt.objectToken)
+	}
+	var res downlaodResponse
+	code := serve(t, h, Download, http.MethodGet,
+		jsonBody(t, map[string]any{"auth-token": "alice-token", "object-tokens": objectTokens}), &res)
+	// One bad file doesn't fail the others.
+	if code != http.StatusOK || res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d (%d), want %d: %s", code, res.StatusCode, http.StatusOK, res.Message)
+	}
+	if len(res.Tokens2Results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(res.Tokens2Results), len(tests))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := res.Tokens2Results[tt.objectToken.String()]
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", result.Status, tt.wantStatus)
+			}
+			if !strings.Contains(result.Error, tt.wantError) {
+				t.Errorf("error = %q, want it to contain %q", result.Error, tt.wantError)
+			}
+			if (result.URL != "") != (tt.wantStatus == fileOK) {
+				t.Errorf("url = %q, want it just for ok files", result.URL)
+			}
+			if url := res.Tokens2URLs[tt.objectToken.String()]; url != result.URL {
+				t.Errorf("tokens2urls = %q, want the url of the result %q", url, result.URL)
+			}
+		})
+	}
+
+	// The link downloads the file.
+	link, err := url.Parse(res.Tokens2Results[objectToken(t, "alice/a.pdf").String()].URL)
+	if err != nil {
+		t.Fatalf("invalid download link: %v", err)
+	}
+	if data, err := memory.Download(*link); err != nil || string(data) != "alice/a.pdf" {
+		t.Errorf("Download() = %q, %v", data, err)
+	}
+}
+
+func TestAuthErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name       string
+		authToken  token.Token
+		err        *e.Error
+		wantStatus int
+	}{
+		{"unknown token", "unknown-token", nil, http.StatusUnauthorized},
+		{"expired token", "expired-token", e.NewErrorP("expired", auth.ErrUnauthorized), http.StatusUnauthorized},
+		{"disabled user", "disabled-token", e.NewErrorP("disabled", auth.ErrForbidden), http.StatusForbidden},
+		{"auth service is down", "down-token", e.NewErrorP("down", auth.ErrUnavailable), http.StatusServiceUnavailable},
+		{"auth service failed", "failed-token", e.NewErrorP("failed", auth.ErrInternal), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := auth.NewMemoryAuth()
+			if tt.err != nil {
+				a.SetError(tt.authToken, tt.err)
+			}
+			h := newTestHandler(t, a, storage.NewMemoryStorage())
+			var res downlaodResponse
+			code := serve(t, h, Download, http.MethodGet, jsonBody(t, map[string]any{
+				"auth-token": tt.authToken, "object-tokens": []token.Token{objectToken(t, "alice/a.pdf")},
+			}), &res)
+			if code != tt.wantStatus || res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d (%d), want %d", code, res.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
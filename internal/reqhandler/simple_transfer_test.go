@@ -0,0 +1,130 @@
+package reqhandler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestTransfer(t *testing.T) {
+	t.Setenv("OBJECT_KEY_TEMPLATE", "{user}/{uuid}.{ext}")
+	tests := []struct {
+		name string
+		typ  ioType
+		// Keys of the files. A new key is created if the destination is empty.
+		source      string
+		destination string
+		wantStatus  fileStatus
+		// Whether the source and the destination are stored after the request
+		wantSource, wantDestination bool
+	}{
+		{"copy", Copy, "a.pdf", "new.pdf", fileOK, true, true},
+		{"move", Move, "a.pdf", "new.pdf", fileOK, false, true},
+		{"copy to a new key", Copy, "a.pdf", "", fileOK, true, true},
+		{"move to a new key", Move, "a.pdf", "", fileOK, false, true},
+		{"copy to an existing file", Copy, "a.pdf", "b.pdf", fileExists, true, true},
+		{"move to an existing file", Move, "a.pdf", "b.pdf", fileExists, true, true},
+		{"copy a missing file", Copy, "gone.pdf", "new.pdf", fileNotFound, false, false},
+		{"copy a forbidden file", Copy, "b.pdf", "new.pdf", fileForbidden, true, false},
+		// Permission of copying a file doesn't allow moving it
+		{"move a file that could be copied", Move, "copy-only.pdf", "new.pdf", fileForbidden, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := storage.NewMemoryStorage()
+			for _, key := range []string{"a.pdf", "b.pdf", "copy-only.pdf"} {
+				memory.PutObject(key, []byte(key), metadata.Metadata{"RealName": key})
+			}
+			a := auth.NewMemoryAuth().AllowCopy("alice", "a.pdf", "gone.pdf", "copy-only.pdf").
+				AllowMove("alice", "a.pdf", "gone.pdf").SetUserID("alice", "alice")
+			h := newTestHandler(t, a, memory)
+
+			var res transferResponse
+			object := map[string]token.Token{"source": objectToken(t, tt.source)}
+			if tt.destination != "" {
+				object["destination"] = objectToken(t, tt.destination)
+			}
+			req := map[string]any{"auth-token": "alice", "objects": []map[string]token.Token{object}}
+			if code := serve(t, h, tt.typ, http.MethodPost, jsonBody(t, req), &res); code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", code, http.StatusOK, res.Message)
+			}
+			if len(res.Results) != 1 {
+				t.Fatalf("got %d results, want 1", len(res.Results))
+			}
+			result := res.Results[0]
+			if result.Source != object["source"].String() || result.Status != tt.wantStatus {
+				t.Errorf("result = %+v, want status %q", result, tt.wantStatus)
+			}
+			destinationKey := objectKey(t, result.Destination)
+			if tt.destination == "" && (!strings.HasPrefix(destinationKey, "alice/") || !strings.HasSuffix(destinationKey, ".pdf")) {
+				t.Errorf("new destination = %q, want a pdf file of the user", destinationKey)
+			} else if tt.destination != "" && destinationKey != tt.destination {
+				t.Errorf("destination = %q, want %q", destinationKey, tt.destination)
+			}
+			if _, ok := memory.Object(tt.source); ok != tt.wantSource {
+				t.Errorf("source is stored = %v, want %v", ok, tt.wantSource)
+			}
+			destination, ok := memory.Object(destinationKey)
+			if ok != tt.wantDestination {
+				t.Fatalf("destination is stored = %v, want %v", ok, tt.wantDestination)
+			}
+			// Existing files aren't overwritten
+			if ok && string(destination.Data) != tt.source && tt.wantStatus == fileOK {
+				t.Errorf("destination = %q, want content of the source", destination.Data)
+			}
+			if ok && tt.wantStatus == fileExists && string(destination.Data) != destinationKey {
+				t.Errorf("existing destination is overwritten with %q", destination.Data)
+			}
+			if ok && destination.Metadata.Get("RealName") == "" {
+				t.Error("metadata of the source isn't copied")
+			}
+		})
+	}
+}
+
+func TestTransferInvalidRequests(t *testing.T) {
+	a, b, noExtension := objectToken(t, "a.pdf"), objectToken(t, "b.pdf"), objectToken(t, "a")
+	tests := []struct {
+		name    string
+		objects []map[string]token.Token
+	}{
+		{"without objects", []map[string]token.Token{}},
+		{"without source", []map[string]token.Token{{"destination": objectToken(t, "new.pdf")}}},
+		{"invalid source", []map[string]token.Token{{"source": "a.pdf", "destination": objectToken(t, "new.pdf")}}},
+		{"invalid destination", []map[string]token.Token{{"source": a, "destination": "new.pdf"}}},
+		{"new key without extension", []map[string]token.Token{{"source": noExtension}}},
+		{"same source and destination", []map[string]token.Token{{"source": a, "destination": a}}},
+		{"extension change", []map[string]token.Token{{"source": a, "destination": objectToken(t, "a.html")}}},
+		{"extension removal", []map[string]token.Token{{"source": a, "destination": noExtension}}},
+		{"repeated destination", []map[string]token.Token{
+			{"source": a, "destination": objectToken(t, "new.pdf")}, {"source": b, "destination": objectToken(t, "new.pdf")},
+		}},
+	}
+	for _, tt := range tests {
+		for _, typ := range []ioType{Copy, Move} {
+			t.Run(tt.name, func(t *testing.T) {
+				memory := storage.NewMemoryStorage()
+				memory.PutObject("a.pdf", []byte("content"), nil)
+				memory.PutObject("b.pdf", []byte("content"), nil)
+				memory.PutObject("a", []byte("content"), nil)
+				h := newTestHandler(t, auth.NewMemoryAuth().AllowCopy("alice", "a.pdf", "b.pdf", "a").
+					AllowMove("alice", "a.pdf", "b.pdf", "a").SetUserID("alice", "alice"), memory)
+				var res transferResponse
+				req := map[string]any{"auth-token": "alice", "objects": tt.objects}
+				if code := serve(t, h, typ, http.MethodPost, jsonBody(t, req), &res); code != http.StatusBadRequest {
+					t.Errorf("status = %d, want %d: %s", code, http.StatusBadRequest, res.Message)
+				}
+				_, sourceOK := memory.Object("a.pdf")
+				_, destinationOK := memory.Object("new.pdf")
+				if len(res.Results) != 0 || !sourceOK || destinationOK {
+					t.Error("files are transferred by an invalid request")
+				}
+			})
+		}
+	}
+}
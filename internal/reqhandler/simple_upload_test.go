@@ -0,0 +1,130 @@
+package reqhandler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestUploadFileDetails(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]any
+		wantStatus int
+	}{
+		{"name and labels", map[string]any{"pdf": []map[string]any{
+			{"name": "report.pdf", "labels": map[string]string{"Project": "x"}},
+		}}, http.StatusOK},
+		{"without details", nil, http.StatusOK},
+		{"invalid label key", map[string]any{"pdf": []map[string]any{{"labels": map[string]string{"a b": "x"}}}},
+			http.StatusBadRequest},
+		{"more files than object types", map[string]any{"pdf": []map[string]any{{"name": "a"}, {"name": "b"}, {"name": "c"}}},
+			http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := storage.NewMemoryStorage()
+			h := newTestHandler(t, auth.NewMemoryAuth().AllowUpload("alice", "pdf", 0), memory)
+			req := map[string]any{"auth-token": "alice", "object-types": map[string]int{"pdf": 2}}
+			if tt.files != nil {
+				req["files"] = tt.files
+			}
+			var res uploadResponse
+			code := serve(t, h, Upload, http.MethodPost, jsonBody(t, req), &res)
+			if code != tt.wantStatus || res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%d), want %d: %s", code, res.StatusCode, tt.wantStatus, res.Message)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			links := memory.Links()
+			if len(links) != 2 || len(res.Tokens2Links["pdf"]) != 2 {
+				t.Fatalf("got %d links, want 2", len(links))
+			}
+			if links[0].Metadata.UploadedBy() != "alice" {
+				t.Errorf("uploader of the file = %q, want alice", links[0].Metadata.UploadedBy())
+			}
+			wantName, wantLabel := "", ""
+			if tt.files != nil {
+				wantName, wantLabel = "report", "x"
+			}
+			if got := links[0].Metadata.RealName(); got != wantName {
+				t.Errorf("real name of the file = %q, want %q", got, wantName)
+			}
+			if got := links[0].Metadata.Labels()["project"]; got != wantLabel {
+				t.Errorf("label of the file = %q, want %q", got, wantLabel)
+			}
+			// Details are just for the first file
+			if name := links[1].Metadata.RealName(); name != "" {
+				t.Errorf("real name of the second file = %q, want empty", name)
+			}
+		})
+	}
+}
+
+func TestUploadChecksumKeyTemplate(t *testing.T) {
+	sum := sha256.Sum256([]byte("content"))
+	checksum := base64.StdEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name       string
+		file       map[string]any
+		wantStatus int
+	}{
+		{"with checksum", map[string]any{"checksum-sha256": checksum}, http.StatusOK},
+		{"without checksum", map[string]any{}, http.StatusBadRequest},
+		{"checksum isn't base64", map[string]any{"checksum-sha256": "not base64!"}, http.StatusBadRequest},
+		{"checksum isn't SHA-256", map[string]any{"checksum-sha256": base64.StdEncoding.EncodeToString(sum[:16])},
+			http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OBJECT_KEY_TEMPLATE", "{tenant}/{user}/{sha256}.{ext}")
+			t.Setenv("OBJECT_KEY_TENANT", "acme")
+			memory := storage.NewMemoryStorage()
+			h := newTestHandler(t, auth.NewMemoryAuth().AllowUpload("alice", "pdf", 0), memory)
+			req := map[string]any{"auth-token": "alice", "object-types": map[string]int{"pdf": 1},
+				"files": map[string]any{"pdf": []map[string]any{tt.file}}}
+			var res uploadResponse
+			code := serve(t, h, Upload, http.MethodPost, jsonBody(t, req), &res)
+			if code != tt.wantStatus || res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%d), want %d: %s", code, res.StatusCode, tt.wantStatus, res.Message)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(memory.Links()) != 0 {
+					t.Error("upload link is created for a rejected request")
+				}
+				return
+			}
+			wantKey := "acme/alice/" + hex.EncodeToString(sum[:]) + ".pdf"
+			links := memory.Links()
+			if len(links) != 1 || links[0].Key != wantKey || links[0].ChecksumSHA256 != checksum {
+				t.Fatalf("links = %+v, want a link to %s with the checksum", links, wantKey)
+			}
+			if got := objectKey(t, res.Tokens2Links["pdf"][0].ObjectToken); got != wantKey {
+				t.Errorf("key of the object token = %q, want %q", got, wantKey)
+			}
+			// The storage rejects another content
+			if _, err := memory.Upload(links[0].URL, []byte("another content")); err == nil {
+				t.Error("file with another checksum is uploaded")
+			}
+			if _, err := memory.Upload(links[0].URL, []byte("content")); err != nil {
+				t.Errorf("Upload() error = %v", err)
+			}
+		})
+	}
+
+	// Checksum of the whole file isn't verified in multipart uploads
+	t.Setenv("OBJECT_KEY_TEMPLATE", "{sha256}.{ext}")
+	h := newTestHandler(t, auth.NewMemoryAuth().AllowUpload("alice", "pdf", 0), storage.NewMemoryStorage())
+	var res multipartResponse
+	req := map[string]any{"auth-token": "alice", "object-type": "pdf", "part-count": 1}
+	if code := serve(t, h, MultipartCreate, http.MethodPost, jsonBody(t, req), &res); code != http.StatusBadRequest {
+		t.Errorf("creating multipart upload status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
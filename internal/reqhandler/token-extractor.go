@@ -0,0 +1,104 @@
+package reqhandler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+)
+
+// Sources of the auth token if AUTH_TOKEN_SOURCES isn't set
+const defaultTokenSources = "header,body"
+
+// Default name of the cookie and the query parameter that contain the auth token
+const defaultTokenParam = "auth-token"
+
+// It finds the auth token of requests in a place. (e.g. a header)
+type tokenExtractor interface {
+	// Return the auth token of the request. bodyToken is "auth-token" field of the JSON
+	// body of the request, if it has any. If the token isn't in this place, it's empty.
+	extract(req *http.Request, bodyToken token.Token) token.Token
+}
+
+// The token is in "Authorization: Bearer <token>" header
+type bearerExtractor struct{}
+
+func (bearerExtractor) extract(req *http.Request, _ token.Token) token.Token {
+	scheme, credentials, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return token.Token(strings.TrimSpace(credentials))
+}
+
+// The token is in a cookie. Cookies are used just for GET and HEAD requests, because
+// browsers send them in requests that other sites make too. (CSRF)
+type cookieExtractor struct {
+	name string
+}
+
+func (c cookieExtractor) extract(req *http.Request, _ token.Token) token.Token {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return ""
+	}
+	cookie, err := req.Cookie(c.name)
+	if err != nil {
+		return ""
+	}
+	return token.Token(cookie.Value)
+}
+
+// The token is in a query parameter of the URL. (e.g. links that are redirected to this app)
+type queryExtractor struct {
+	name string
+}
+
+func (q queryExtractor) extract(req *http.Request, _ token.Token) token.Token {
+	return token.Token(req.URL.Query().Get(q.name))
+}
+
+// The token is "auth-token" field of the JSON body
+type bodyExtractor struct{}
+
+func (bodyExtractor) extract(_ *http.Request, bodyToken token.Token) token.Token {
+	return bodyToken
+}
+
+// Create extractors of the comma separated sources in the same order. Sources could be
+// "header", "cookie", "query" and "body".
+func newTokenExtractors(sources, cookieName, queryParam string) ([]tokenExtractor, error) {
+	var extractors []tokenExtractor
+	seen := make(map[string]bool)
+	for _, source := range strings.Split(sources, ",") {
+		source = strings.TrimSpace(source)
+		if seen[source] {
+			return nil, fmt.Errorf("source %s is repeated", source)
+		}
+		seen[source] = true
+		switch source {
+		case "header":
+			extractors = append(extractors, bearerExtractor{})
+		case "cookie":
+			extractors = append(extractors, cookieExtractor{cookieName})
+		case "query":
+			extractors = append(extractors, queryExtractor{queryParam})
+		case "body":
+			extractors = append(extractors, bodyExtractor{})
+		default:
+			return nil, fmt.Errorf("unknown source %q", source)
+		}
+	}
+	return extractors, nil
+}
+
+// Return the auth token of the request that the first extractor finds it. bodyToken is
+// "auth-token" field of the JSON body of the request, if it has any.
+func (rq *simpleReqHandler) authToken(req *ReqDetails, bodyToken token.Token) token.Token {
+	for _, extractor := range rq.tokenExtractors {
+		if t := extractor.extract(req.Request.Request, bodyToken); t != "" {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package reqhandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+	"github.com/q-sharafian/file-transfer/internal/server"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestNewTokenExtractors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources string
+		wantLen int
+		wantErr bool
+	}{
+		{"default", defaultTokenSources, 2, false},
+		{"all sources", "header, cookie, query, body", 4, false},
+		{"unknown source", "header,form", 0, true},
+		{"repeated source", "header,body,header", 0, true},
+		{"empty source", "header,", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractors, err := newTokenExtractors(tt.sources, defaultTokenParam, defaultTokenParam)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newTokenExtractors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(extractors) != tt.wantLen {
+				t.Errorf("got %d extractors, want %d", len(extractors), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestAuthTokenSources(t *testing.T) {
+	tests := []struct {
+		name      string
+		sources   string
+		method    string
+		header    string
+		cookie    string
+		query     string
+		bodyToken token.Token
+		want      token.Token
+	}{
+		{"bearer header", "header,body", http.MethodGet, "Bearer alice", "", "", "bob", "alice"},
+		{"case of the scheme", "header", http.MethodGet, "bearer alice", "", "", "", "alice"},
+		{"another scheme", "header,body", http.MethodGet, "Basic alice", "", "", "bob", "bob"},
+		{"body after header", "header,body", http.MethodPost, "", "", "", "bob", "bob"},
+		{"body before header", "body,header", http.MethodGet, "Bearer alice", "", "", "bob", "bob"},
+		{"cookie of GET", "cookie", http.MethodGet, "", "alice", "", "", "alice"},
+		{"cookie of POST", "cookie,body", http.MethodPost, "", "alice", "", "bob", "bob"},
+		{"query", "query", http.MethodPost, "", "", "alice", "", "alice"},
+		{"source isn't enabled", "header", http.MethodGet, "", "alice", "alice", "alice", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractors, err := newTokenExtractors(tt.sources, "session", "token")
+			if err != nil {
+				t.Fatalf("newTokenExtractors() error = %v", err)
+			}
+			r := httptest.NewRequest(tt.method, "/?"+url.Values{"token": {tt.query}}.Encode(), nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "session", Value: tt.cookie})
+			}
+			rq := &simpleReqHandler{tokenExtractors: extractors}
+			got := rq.authToken(&ReqDetails{Request: &server.Request{Request: r}}, tt.bodyToken)
+			if got != tt.want {
+				t.Errorf("authToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadWithoutBody(t *testing.T) {
+	memory := storage.NewMemoryStorage()
+	memory.PutObject("alice/a.pdf", []byte("content"), nil)
+	memory.PutObject("alice/b.pdf", []byte("content"), nil)
+	h := newTestHandler(t, auth.NewMemoryAuth().AllowDownload("alice", "alice/a.pdf", "alice/b.pdf"), memory)
+
+	a, b := objectToken(t, "alice/a.pdf"), objectToken(t, "alice/b.pdf")
+	query := url.Values{"object-token": {a.String(), b.String()}, "disposition": {"inline"}}
+	r := httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil)
+	r.Header.Set("Authorization", "Bearer alice")
+	w := httptest.NewRecorder()
+	h.HandleRequest(&ReqDetails{Type: Download, ResponseWriter: w, Request: &server.Request{Request: r}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	links := memory.Links()
+	if len(links) != 2 || links[0].Key != "alice/a.pdf" || links[1].Key != "alice/b.pdf" {
+		t.Fatalf("links = %+v, want links of the files in the query", links)
+	}
+	if want := "inline; filename=a.pdf"; links[0].ContentDisposition != want {
+		t.Errorf("content disposition = %q, want %q", links[0].ContentDisposition, want)
+	}
+}
@@ -0,0 +1,220 @@
+package reqhandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/q-sharafian/file-transfer/internal/common/token"
+)
+
+const (
+	// Maximum size of the JSON body of requests in bytes if MAX_REQUEST_BODY_SIZE isn't set
+	defaultMaxBodySize = 1 << 20
+	// Maximum number of files in a request if MAX_FILES_PER_REQUEST isn't set
+	defaultMaxFiles = 100
+	// Maximum number of files of a type in an upload request if MAX_FILES_PER_TYPE isn't set
+	defaultMaxFilesPerType = 50
+)
+
+// Extensions of the files. (e.g. "pdf" or "tar.gz")
+var fileExtPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,16}(\.[A-Za-z0-9]{1,16}){0,3}$`)
+
+// Create the validator of the requests. Besides the validator tags, fields could have
+// these tags:
+//   - fileext: The string is a file extension.
+//   - maxfiles: The list has at most maxFiles items.
+//   - maxpertype: The number is at most maxFilesPerType.
+//
+// Fields are named by their JSON names in the errors.
+func newValidator(maxFiles, maxFilesPerType int) *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	validate.RegisterValidation("fileext", func(fl validator.FieldLevel) bool {
+		return fileExtPattern.MatchString(fl.Field().String())
+	})
+	validate.RegisterValidation("maxfiles", func(fl validator.FieldLevel) bool {
+		return fl.Field().Len() <= maxFiles
+	})
+	validate.RegisterValidation("maxpertype", func(fl validator.FieldLevel) bool {
+		return fl.Field().Uint() <= uint64(maxFilesPerType)
+	})
+	return validate
+}
+
+// Read the JSON body of the http request into v. An empty body is the same as an empty
+// object, so GET requests don't need any body when the auth token is in a header.
+// Unknown fields and bodies larger than the maximum size are rejected. If it couldn't,
+// the error response is sent to the client.
+func (rq *simpleReqHandler) decodeBody(req *ReqDetails, v any) bool {
+	defer req.Body.Close()
+	body, err := io.ReadAll(http.MaxBytesReader(req.ResponseWriter, req.Body, rq.maxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		msg := fmt.Sprintf("Request body is larger than %d bytes", rq.maxBodySize)
+		rq.prepareErrResponse(req, http.StatusRequestEntityTooLarge, msg, msg)
+		return false
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Getting http body error: %s", err.Error())
+		rq.logger.Debugf(msg)
+		rq.prepareErrResponse(req, http.StatusBadRequest, msg, "Failed to read the request body")
+		return false
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return true
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+	if err == nil && decoder.Decode(&json.RawMessage{}) != io.EOF {
+		err = errors.New("must have just one JSON object")
+	}
+	if err != nil {
+		rq.prepareInvalidResponse(req, []string{decodeProblem(err)})
+		return false
+	}
+	return true
+}
+
+// Describe the error of decoding a JSON body like the problems of the fields
+func decodeProblem(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return fmt.Sprintf("%s: must be %s, not %s", field, jsonKind(typeErr.Type), typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("body: invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr.Error())
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "body: JSON is incomplete"
+	}
+	// Errors of unknown fields aren't typed. (e.g. `json: unknown field "x"`)
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return fmt.Sprintf("%s: unknown field", strings.Trim(field, `"`))
+	}
+	return fmt.Sprintf("body: %s", strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// Return the JSON type of the values that are decoded into t
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return t.String()
+}
+
+// Check the request by its validator tags and return the problem of each invalid field.
+// (e.g. "object-tokens: is required")
+func (rq *simpleReqHandler) requestProblems(v any) []string {
+	err := rq.validate.Struct(v)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		if err != nil {
+			return []string{err.Error()}
+		}
+		return nil
+	}
+	// Namespaces of the fields start with name of the struct, if it isn't anonymous.
+	structName := reflect.Indirect(reflect.ValueOf(v)).Type().Name()
+	problems := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		path := fieldErr.Namespace()
+		if structName != "" {
+			path = strings.TrimPrefix(path, structName+".")
+		}
+		problems = append(problems, rq.fieldProblem(path, fieldErr))
+	}
+	return problems
+}
+
+// Describe the problem of an invalid field with its JSON path
+func (rq *simpleReqHandler) fieldProblem(path string, fieldErr validator.FieldError) string {
+	param := fieldErr.Param()
+	var problem string
+	switch fieldErr.Tag() {
+	case "required":
+		problem = "is required"
+	case "min", "max", "len":
+		bound := map[string]string{"min": "at least ", "max": "at most ", "len": ""}[fieldErr.Tag()]
+		switch fieldErr.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			problem = fmt.Sprintf("must have %s%s items", bound, param)
+		case reflect.String:
+			problem = fmt.Sprintf("must have %s%s characters", bound, param)
+		default:
+			problem = fmt.Sprintf("must be %s%s", bound, param)
+		}
+	case "oneof":
+		problem = fmt.Sprintf("must be one of %s", strings.ReplaceAll(param, " ", ", "))
+	case "hexadecimal":
+		problem = "must be in hex"
+	case "base64":
+		problem = "must be in base64"
+	case "fileext":
+		problem = fmt.Sprintf("%q must be a file extension like \"pdf\" or \"tar.gz\"", fieldErr.Value())
+	case "maxfiles":
+		problem = fmt.Sprintf("must have at most %d files", rq.maxFiles)
+	case "maxpertype":
+		problem = fmt.Sprintf("must be at most %d files", rq.maxFilesPerType)
+	default:
+		problem = fmt.Sprintf("doesn't satisfy %s", fieldErr.Tag())
+	}
+	return fmt.Sprintf("%s: %s", path, problem)
+}
+
+// Read the JSON body of the request into v, replace authToken by the token of the request
+// and validate v. authToken must point to "auth-token" field of v. If the request is
+// invalid, the error response is sent to the client.
+func (rq *simpleReqHandler) readRequest(req *ReqDetails, v any, authToken *token.Token) bool {
+	if !rq.decodeBody(req, v) {
+		return false
+	}
+	*authToken = rq.authToken(req, *authToken)
+	if problems := rq.requestProblems(v); len(problems) > 0 {
+		rq.prepareInvalidResponse(req, problems)
+		return false
+	}
+	return true
+}
+
+// Send the error response of an invalid request that lists problems of its fields
+func (rq *simpleReqHandler) prepareInvalidResponse(req *ReqDetails, problems []string) {
+	rq.logger.Debugf("Invalid request: %s", strings.Join(problems, "; "))
+	rq.setResponse(req, downlaodResponse{
+		StatusCode: http.StatusBadRequest,
+		Message:    "Invalid request",
+		Problems:   problems,
+	}, http.StatusBadRequest)
+}
@@ -0,0 +1,155 @@
+package reqhandler
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/auth"
+	"github.com/q-sharafian/file-transfer/internal/storage"
+)
+
+func TestInvalidRequestProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    ioType
+		method string
+		body   string
+		want   []string
+	}{
+		{
+			name: "empty download", typ: Download, method: http.MethodGet, body: `{}`,
+			want: []string{"auth-token: is required", "object-tokens: is required"},
+		},
+		{
+			name: "no object tokens", typ: Download, method: http.MethodGet,
+			body: `{"auth-token": "t", "object-tokens": []}`,
+			want: []string{"object-tokens: must have at least 1 items"},
+		},
+		{
+			name: "too many object tokens", typ: Download, method: http.MethodGet,
+			body: `{"auth-token": "t", "object-tokens": ["a", "b", "c", "d"]}`,
+			want: []string{"object-tokens: must have at most 3 files"},
+		},
+		{
+			name: "empty object token", typ: Download, method: http.MethodGet,
+			body: `{"auth-token": "t", "object-tokens": ["a", ""]}`,
+			want: []string{"object-tokens[1]: is required"},
+		},
+		{
+			name: "unknown disposition", typ: Download, method: http.MethodGet,
+			body: `{"auth-token": "t", "object-tokens": ["a"], "disposition": "download"}`,
+			want: []string{"disposition: must be one of attachment, inline"},
+		},
+		{
+			name: "unknown field", typ: Download, method: http.MethodGet,
+			body: `{"auth-token": "t", "object-token": ["a"]}`,
+			want: []string{"object-token: unknown field"},
+		},
+		{
+			name: "field with wrong type", typ: Download, method: http.MethodGet,
+			body: `{"auth-token": "t", "object-tokens": "a"}`,
+			want: []string{"object-tokens: must be an array, not string"},
+		},
+		{
+			name: "body isn't an object", typ: Download, method: http.MethodGet, body: `["a"]`,
+			want: []string{"body: must be an object, not array"},
+		},
+		{
+			name: "incomplete JSON", typ: Download, method: http.MethodGet, body: `{"auth-token": "t"`,
+			want: []string{"body: JSON is incomplete"},
+		},
+		{
+			name: "malformed JSON", typ: Download, method: http.MethodGet, body: `{"auth-token": x}`,
+			want: []string{"body: invalid JSON at offset 16: invalid character 'x' looking for beginning of value"},
+		},
+		{
+			name: "more than one object", typ: Download, method: http.MethodGet, body: `{} {}`,
+			want: []string{"body: must have just one JSON object"},
+		},
+		{
+			name: "empty upload", typ: Upload, method: http.MethodPost, body: `{"auth-token": "t"}`,
+			want: []string{"object-types: is required"},
+		},
+		{
+			name: "no object types", typ: Upload, method: http.MethodPost,
+			body: `{"auth-token": "t", "object-types": {}}`,
+			want: []string{"object-types: must have at least 1 items"},
+		},
+		{
+			name: "invalid file extension", typ: Upload, method: http.MethodPost,
+			body: `{"auth-token": "t", "object-types": {"../pdf": 1}}`,
+			want: []string{`object-types[../pdf]: "../pdf" must be a file extension like "pdf" or "tar.gz"`},
+		},
+		{
+			name: "no files of a type", typ: Upload, method: http.MethodPost,
+			body: `{"auth-token": "t", "object-types": {"pdf": 0}}`,
+			want: []string{"object-types[pdf]: must be at least 1"},
+		},
+		{
+			name: "too many files of a type", typ: Upload, method: http.MethodPost,
+			body: `{"auth-token": "t", "object-types": {"pdf": 3}}`,
+			want: []string{"object-types[pdf]: must be at most 2 files"},
+		},
+		{
+			name: "negative number of files", typ: Upload, method: http.MethodPost,
+			body: `{"auth-token": "t", "object-types": {"pdf": -1}}`,
+			want: []string{"object-types.pdf: must be a non-negative integer, not number -1"},
+		},
+		{
+			name: "empty delete", typ: Delete, method: http.MethodDelete, body: `{"object-tokens": [""]}`,
+			want: []string{"auth-token: is required", "object-tokens[0]: is required"},
+		},
+		{
+			name: "transfer without source", typ: Copy, method: http.MethodPost,
+			body: `{"auth-token": "t", "objects": [{"source": "a"}, {"destination": "b"}]}`,
+			want: []string{"objects[1].source: is required"},
+		},
+		{
+			name: "empty transfer", typ: Move, method: http.MethodPost, body: `{"auth-token": "t"}`,
+			want: []string{"objects: is required"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_FILES_PER_REQUEST", "3")
+			t.Setenv("MAX_FILES_PER_TYPE", "2")
+			h := newTestHandler(t, auth.NewMemoryAuth(), storage.NewMemoryStorage())
+			var res downlaodResponse
+			code := serve(t, h, tt.typ, tt.method, tt.body, &res)
+			if code != http.StatusBadRequest || res.StatusCode != http.StatusBadRequest {
+				t.Errorf("status = %d (%d), want %d", code, res.StatusCode, http.StatusBadRequest)
+			}
+			if res.Message != "Invalid request" {
+				t.Errorf("message = %q, want %q", res.Message, "Invalid request")
+			}
+			if !reflect.DeepEqual(res.Problems, tt.want) {
+				t.Errorf("problems = %q, want %q", res.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestBodySize(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		wantStatus int
+	}{
+		{"at the limit", 100, http.StatusUnauthorized},
+		{"larger than the limit", 101, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_REQUEST_BODY_SIZE", "100")
+			h := newTestHandler(t, auth.NewMemoryAuth(), storage.NewMemoryStorage())
+			prefix, suffix := `{"auth-token": "t", "object-tokens": ["`, `"]}`
+			body := prefix + strings.Repeat("a", tt.size-len(prefix)-len(suffix)) + suffix
+			var res downlaodResponse
+			if code := serve(t, h, Download, http.MethodGet, body, &res); code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", code, tt.wantStatus, res.Message)
+			}
+		})
+	}
+}
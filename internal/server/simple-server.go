@@ -6,6 +6,7 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"sync"
 
@@ -51,10 +52,20 @@ type simpleServer struct {
 	oneTimeActiveRoutes sync.Map
 	serverAddr          string
 	logger              l.Logger
+	// Query parameters that their values aren't logged. (e.g. auth tokens)
+	secretParams []string
 }
 
+// Query parameter of the auth token if AUTH_TOKEN_QUERY_PARAM isn't set. It's the same
+// as the default of the request handler.
+const defaultTokenParam = "auth-token"
+
 func NewSimpleServer(logger l.Logger) Server {
 	logger.Infof("Initializing simple server on port %s", os.Getenv("SERVER_PORT"))
+	tokenParam := os.Getenv("AUTH_TOKEN_QUERY_PARAM")
+	if tokenParam == "" {
+		tokenParam = defaultTokenParam
+	}
 	server := simpleServer{
 		mux:                 http.NewServeMux(),
 		mu:                  sync.RWMutex{},
@@ -62,6 +73,7 @@ func NewSimpleServer(logger l.Logger) Server {
 		oneTimeActiveRoutes: sync.Map{},
 		serverAddr:          fmt.Sprintf(":%s", os.Getenv("SERVER_PORT")),
 		logger:              logger,
+		secretParams:        []string{tokenParam},
 	}
 	// Start the server in a separate goroutine
 	go func() {
@@ -87,21 +99,40 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.handler(w, &Request{r})
 }
 
-func httpLogger(logger l.Logger, next *handler) http.HandlerFunc {
+func httpLogger(logger l.Logger, secretParams []string, next *handler) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Debugf(`-----------------------------------------------------------
-Handling http request with %s method to url %s`, r.Method, r.URL.String())
+Handling http request with %s method to url %s`, r.Method, redactURL(r.URL, secretParams))
 		next.ServeHTTP(w, r)
 		// Write codes run after running the handler
 	})
 }
 
+// Return the URL with values of the secret query parameters replaced, so they aren't
+// written in the logs.
+func redactURL(u *url.URL, secretParams []string) string {
+	query := u.Query()
+	redacted := false
+	for _, param := range secretParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+	copied := *u
+	copied.RawQuery = query.Encode()
+	return copied.String()
+}
+
 func (s *simpleServer) AddHandler(path string, handler func(w ResponseWriter, r *Request)) {
 	s.logger.Debugf("Adding HTTP path handler for \"%s\"", path)
 	stdHandler := newHandler(handler, &s.mu)
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.mux.Handle(path, httpLogger(s.logger, stdHandler))
+	s.mux.Handle(path, httpLogger(s.logger, s.secretParams, stdHandler))
 	// http.ListenAndServe(s.serverAddr, s.mux)
 }
 
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"without query", "/download/a", "/download/a"},
+		{"without secrets", "/download?object-token=a&object-token=b", "/download?object-token=a&object-token=b"},
+		{"auth token", "/download/a?auth-token=s3cret", "/download/a?auth-token=REDACTED"},
+		{"repeated auth token", "/download/a?auth-token=s3cret&auth-token=other&disposition=inline",
+			"/download/a?auth-token=REDACTED&disposition=inline"},
+		{"empty auth token", "/download/a?auth-token=", "/download/a?auth-token=REDACTED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("url.Parse() error = %v", err)
+			}
+			if got := redactURL(u, []string{defaultTokenParam}); got != tt.want {
+				t.Errorf("redactURL() = %q, want %q", got, tt.want)
+			}
+			if u.String() != tt.url {
+				t.Errorf("redactURL() changed the URL to %q", u.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,823 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	"github.com/q-sharafian/file-transfer/internal/server"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+	l "github.com/q-sharafian/file-transfer/pkg/logger"
+)
+
+const (
+	// Query parameters of the links created by LocalStorage
+	localExpiresParam   = "expires"
+	localSignatureParam = "signature"
+	localMaxSizeParam   = "max-size"
+	localChecksumParam  = "checksum-sha256"
+	// Query parameters of the download links that override the response headers
+	localDispositionParam = "response-content-disposition"
+	localContentTypeParam = "response-content-type"
+	// Query parameters of the links to upload parts of a multipart upload
+	localUploadIDParam   = "upload-id"
+	localPartNumberParam = "part-number"
+	// Prefix of the query parameters that carry metadata of the uploading file
+	localMetaParamPrefix = "meta-"
+	// Name of subdirectories of the storage root directory
+	localObjectsDir  = "objects"
+	localMetadataDir = "metadata"
+	// Each multipart upload has a directory in it that its parts are stored there
+	localMultipartDir = "multipart"
+)
+
+// Keeps the files on the local disk and serves them through the server of this app.
+// Links are signed by HMAC and expire in the same way as S3 presigned URLs.
+// It's useful for development and deployments that don't have any S3 storage.
+type LocalStorage struct {
+	// Directory the files and their metadata are stored in
+	rootDir string
+	// Public address of this app that clients could reach the server through it. (e.g. http://localhost:8081)
+	baseURL *url.URL
+	// HTTP path that files are served under it. It always ends with "/"
+	routePath string
+	// Secret key that links are signed with it
+	secret []byte
+	logger l.Logger
+}
+
+// Create a local storage and add its upload/download routes to the server.
+func NewLocalStorage(srv server.Server, logger l.Logger) Storage {
+	rootDir := os.Getenv("LOCAL_STORAGE_DIR")
+	if rootDir == "" {
+		rootDir = "data"
+	}
+	baseURL, err := url.Parse(os.Getenv("LOCAL_STORAGE_BASE_URL"))
+	if err != nil {
+		logger.Panicf("Failed to parse LOCAL_STORAGE_BASE_URL: %s", err.Error())
+	}
+	routePath := os.Getenv("LOCAL_STORAGE_PATH")
+	if routePath == "" {
+		routePath = "/objects/"
+	}
+	if !strings.HasSuffix(routePath, "/") {
+		routePath += "/"
+	}
+	secret := []byte(os.Getenv("LOCAL_STORAGE_SECRET"))
+	if len(secret) == 0 {
+		logger.Warn("LOCAL_STORAGE_SECRET is empty. A random secret is used and links will be invalid after restarting the app")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.Panicf("Failed to create random secret for local storage: %s", err.Error())
+		}
+	}
+	for _, dir := range []string{localObjectsDir, localMetadataDir, localMultipartDir} {
+		if err := os.MkdirAll(filepath.Join(rootDir, dir), 0o750); err != nil {
+			logger.Panicf("Failed to create local storage directory: %s", err.Error())
+		}
+	}
+
+	logger.Infof("Initializing local storage in directory %s", rootDir)
+	storage := &LocalStorage{
+		rootDir:   rootDir,
+		baseURL:   baseURL,
+		routePath: routePath,
+		secret:    secret,
+		logger:    logger,
+	}
+	srv.AddHandler(routePath, storage.serveObject)
+	return storage
+}
+
+// Metadata of the file are signed in the query of the link, so no header is needed.
+func (s *LocalStorage) UploadFile(fileInfo UploadFileInfo, expireTime time.Duration) (UploadLink, error) {
+	key := fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String())
+	if _, err := s.objectPath(key); err != nil {
+		return UploadLink{}, fmt.Errorf("failed to create uploading link with key name %s: %s", key, err.Error())
+	}
+	query := url.Values{}
+	for k, v := range fileInfo.Metadata {
+		query.Set(localMetaParamPrefix+k, v)
+	}
+	if fileInfo.MaxSize > 0 {
+		query.Set(localMaxSizeParam, strconv.FormatUint(fileInfo.MaxSize, 10))
+	}
+	if fileInfo.ChecksumSHA256 != "" {
+		query.Set(localChecksumParam, fileInfo.ChecksumSHA256)
+	}
+	return UploadLink{URL: s.signedURL(http.MethodPut, key, query, expireTime)}, nil
+}
+
+func (s *LocalStorage) UploadFileForm(fileInfo UploadFileInfo, expireTime time.Duration) (UploadForm, error) {
+	key := fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String())
+	if _, err := s.objectPath(key); err != nil {
+		return UploadForm{}, fmt.Errorf("failed to create uploading form with key name %s: %s", key, err.Error())
+	}
+	values := url.Values{}
+	for k, v := range fileInfo.Metadata {
+		values.Set(localMetaParamPrefix+k, v)
+	}
+	if fileInfo.MaxSize > 0 {
+		values.Set(localMaxSizeParam, strconv.FormatUint(fileInfo.MaxSize, 10))
+	}
+	link := s.signedURL(http.MethodPost, key, values, expireTime)
+	fields := make(map[string]string, len(values))
+	for k := range link.Query() {
+		fields[k] = link.Query().Get(k)
+	}
+	link.RawQuery = ""
+	return UploadForm{link, fields}, nil
+}
+
+func (s *LocalStorage) DownloadFile(fileInfo DownloadFileInfo, expireTime time.Duration) (url.URL, error) {
+	md, err := s.GetMetadata(fileInfo.FileName)
+	if err != nil {
+		return url.URL{}, fmt.Errorf("failed to create downloading link: %w", err)
+	}
+	query := url.Values{}
+	query.Set(localDispositionParam, contentDisposition(fileInfo, md))
+	query.Set(localContentTypeParam, contentType(fileInfo.FileName))
+	return s.signedURL(http.MethodGet, fileInfo.FileName, query, expireTime), nil
+}
+
+// Create a link to the key that is valid just for the method until the expiration time
+func (s *LocalStorage) signedURL(method, key string, query url.Values, expireTime time.Duration) url.URL {
+	query.Set(localExpiresParam, strconv.FormatInt(time.Now().Add(expireTime).Unix(), 10))
+	query.Set(localSignatureParam, s.sign(method, key, query))
+	link := *s.baseURL
+	link.Path = path.Join(link.Path, s.routePath, key)
+	link.RawQuery = query.Encode()
+	return link
+}
+
+// Sign the method, key and all query parameters except the signature itself
+func (s *LocalStorage) sign(method, key string, query url.Values) string {
+	signed := url.Values{}
+	for k, v := range query {
+		if k != localSignatureParam {
+			signed[k] = v
+		}
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s", method, key, signed.Encode())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Check the signature and expiration time of the link
+func (s *LocalStorage) verify(method, key string, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get(localExpiresParam), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid expiration time")
+	}
+	expected := s.sign(method, key, query)
+	if !hmac.Equal([]byte(expected), []byte(query.Get(localSignatureParam))) {
+		return fmt.Errorf("invalid signature")
+	}
+	if time.Now().Unix() > expires {
+		return fmt.Errorf("link has expired")
+	}
+	return nil
+}
+
+// Handle the requests of the links created by the local storage
+func (s *LocalStorage) serveObject(w server.ResponseWriter, r *server.Request) {
+	key := strings.TrimPrefix(r.URL.Path, s.routePath)
+	switch r.Method {
+	case http.MethodPut:
+		if !s.verifyRequest(w, r.Method, key, r.URL.Query()) {
+			return
+		}
+		if r.URL.Query().Has(localUploadIDParam) {
+			s.storePart(w, key, r.Body, r.URL.Query())
+			return
+		}
+		s.storeObject(w, key, r.Body, r.URL.Query())
+	case http.MethodPost:
+		s.postObject(w, r, key)
+	case http.MethodGet:
+		if !s.verifyRequest(w, r.Method, key, r.URL.Query()) {
+			return
+		}
+		s.readObject(w, r, key)
+	default:
+		http.Error(w, "HTTP method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Verify the link and response to the client if it's not valid
+func (s *LocalStorage) verifyRequest(w server.ResponseWriter, method, key string, values url.Values) bool {
+	if err := s.verify(method, key, values); err != nil {
+		s.logger.Debugf("Rejecting local storage request with key %s: %s", key, err.Error())
+		http.Error(w, "Link is invalid or has expired", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// Handle uploading a file by a form created by UploadFileForm.
+func (s *LocalStorage) postObject(w server.ResponseWriter, r *server.Request, key string) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Request must be multipart/form-data", http.StatusBadRequest)
+		return
+	}
+	values := url.Values{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			http.Error(w, "Field \"file\" is missing", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Malformed multipart body", http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, 64<<10))
+			if err != nil {
+				http.Error(w, "Malformed multipart body", http.StatusBadRequest)
+				return
+			}
+			values.Set(part.FormName(), string(value))
+			continue
+		}
+		if !s.verifyRequest(w, http.MethodPost, key, values) {
+			return
+		}
+		s.storeObject(w, key, part, values)
+		return
+	}
+}
+
+// Store the body as the file with the key. Metadata and size limit of the file are
+// taken from the signed values of the link/form.
+func (s *LocalStorage) storeObject(w server.ResponseWriter, key string, body io.Reader, values url.Values) {
+	metadata := metadata.Metadata{}
+	for k, v := range values {
+		if strings.HasPrefix(k, localMetaParamPrefix) {
+			metadata[strings.TrimPrefix(k, localMetaParamPrefix)] = v[0]
+		}
+	}
+	etag, err := s.writeObject(key, body, metadata, signedMaxSize(values), values.Get(localChecksumParam))
+	// Errors of the file itself are reported by their codes
+	if errors.Is(err, errLocalTooLarge) || errors.Is(err, errLocalChecksum) {
+		http.Error(w, err.Error(), e.CodeOf(err).HTTPStatus())
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to store file with key %s: %s", key, err.Error())
+		http.Error(w, "Failed to store the file", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+	w.WriteHeader(http.StatusOK)
+}
+
+// Return the size limit that is signed in the values of the link/form. Zero means there's no limit.
+func signedMaxSize(values url.Values) uint64 {
+	maxSize, _ := strconv.ParseUint(values.Get(localMaxSizeParam), 10, 64)
+	return maxSize
+}
+
+// Store the body as a part of a multipart upload. A part can't be larger than the
+// whole file, so the size limit of the file is applied to each part.
+func (s *LocalStorage) storePart(w server.ResponseWriter, key string, body io.Reader, values url.Values) {
+	upload := MultipartUpload{Key: key, UploadID: values.Get(localUploadIDParam)}
+	if _, err := s.readMultipartUpload(upload); err != nil {
+		s.logger.Debugf("Rejecting part of multipart upload: %s", err.Error())
+		http.Error(w, "Multipart upload not found", http.StatusNotFound)
+		return
+	}
+	partNumber, err := strconv.ParseInt(values.Get(localPartNumberParam), 10, 32)
+	if err != nil || partNumber < 1 {
+		http.Error(w, "Invalid part number", http.StatusBadRequest)
+		return
+	}
+	partPath := filepath.Join(s.rootDir, localMultipartDir, upload.UploadID, fmt.Sprintf("%d.part", partNumber))
+	etag, _, err := writeFile(partPath, body, signedMaxSize(values), "")
+	if errors.Is(err, errLocalTooLarge) {
+		http.Error(w, err.Error(), e.CodeOf(err).HTTPStatus())
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to store part %d of file with key %s: %s", partNumber, key, err.Error())
+		http.Error(w, "Failed to store the part", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+	w.WriteHeader(http.StatusOK)
+}
+
+var (
+	errLocalTooLarge error = e.NewErrorP("File is larger than the allowed size", e.TooLarge)
+	errLocalChecksum error = e.NewErrorP("Checksum of the file doesn't match", e.InvalidArgument)
+)
+
+// Details of a file that are stored in the metadata directory
+type localObjectInfo struct {
+	Metadata metadata.Metadata `json:"metadata"`
+	// MD5 hash of the file in hex
+	ETag string `json:"etag"`
+	// SHA-256 checksum of the file in base64
+	ChecksumSHA256 string `json:"checksum-sha256"`
+	Finalized      bool   `json:"finalized"`
+}
+
+// Store content of the reader as the file with the key and return its MD5 hash.
+func (s *LocalStorage) writeObject(key string, body io.Reader, metadata metadata.Metadata, maxSize uint64,
+	checksum string) (string, error) {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return "", err
+	}
+	etag, checksum, err := writeFile(objectPath, body, maxSize, checksum)
+	if err != nil {
+		return "", err
+	}
+	info := localObjectInfo{Metadata: metadata, ETag: etag, ChecksumSHA256: checksum}
+	if err := s.writeObjectInfo(key, info); err != nil {
+		return "", err
+	}
+	return etag, nil
+}
+
+// Write content of the reader to the file and return its MD5 hash in hex and its SHA-256
+// checksum in base64. If maxSize is not zero and the content is larger than it,
+// errLocalTooLarge is returned. If wantChecksum is not empty and the content has another
+// checksum, errLocalChecksum is returned.
+func writeFile(filePath string, body io.Reader, maxSize uint64, wantChecksum string) (string, string, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o750); err != nil {
+		return "", "", fmt.Errorf("creating directory error: %s", err.Error())
+	}
+	// Write to a temporary file first so a half written file is never served
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return "", "", fmt.Errorf("creating file error: %s", err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	if maxSize > 0 {
+		body = io.LimitReader(body, int64(maxSize)+1)
+	}
+	written, err := io.Copy(io.MultiWriter(tmp, md5Hash, sha256Hash), body)
+	if err != nil {
+		tmp.Close()
+		return "", "", fmt.Errorf("writing file error: %s", err.Error())
+	}
+	if maxSize > 0 && uint64(written) > maxSize {
+		tmp.Close()
+		return "", "", errLocalTooLarge
+	}
+	checksum := base64.StdEncoding.EncodeToString(sha256Hash.Sum(nil))
+	if wantChecksum != "" && checksum != wantChecksum {
+		tmp.Close()
+		return "", "", errLocalChecksum
+	}
+	if err := tmp.Close(); err != nil {
+		return "", "", fmt.Errorf("writing file error: %s", err.Error())
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return "", "", fmt.Errorf("moving file error: %s", err.Error())
+	}
+	return hex.EncodeToString(md5Hash.Sum(nil)), checksum, nil
+}
+
+func (s *LocalStorage) readObject(w server.ResponseWriter, r *server.Request, key string) {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(objectPath)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	// Parameters are signed, so they are set by the storage itself
+	if disposition := r.URL.Query().Get(localDispositionParam); disposition != "" {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+	// Files are served on the origin of the app, so browsers mustn't guess their type
+	// or run any script in them. (XSS)
+	contentType := r.URL.Query().Get(localContentTypeParam)
+	if contentType == "" || isActiveContent(contentType) {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox; default-src 'none'")
+	http.ServeContent(w, r.Request, path.Base(key), stat.ModTime(), f)
+}
+
+func (s *LocalStorage) writeObjectInfo(key string, info localObjectInfo) error {
+	metadataPath, err := s.metadataPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(metadataPath), 0o750); err != nil {
+		return fmt.Errorf("creating metadata directory error: %s", err.Error())
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("marshaling metadata error: %s", err.Error())
+	}
+	if err := os.WriteFile(metadataPath, data, 0o640); err != nil {
+		return fmt.Errorf("writing metadata error: %s", err.Error())
+	}
+	return nil
+}
+
+func (s *LocalStorage) readObjectInfo(key string) (localObjectInfo, error) {
+	metadataPath, err := s.metadataPath(key)
+	if err != nil {
+		return localObjectInfo{}, err
+	}
+	data, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return localObjectInfo{}, fmt.Errorf("reading metadata error: %s", err.Error())
+	}
+	var info localObjectInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return localObjectInfo{}, fmt.Errorf("unmarshaling metadata error: %s", err.Error())
+	}
+	return info, nil
+}
+
+// Return path of the file with the key on the disk. Keys that could escape from
+// the storage directory are rejected.
+func (s *LocalStorage) objectPath(key string) (string, error) {
+	if err := checkLocalKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.rootDir, localObjectsDir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) metadataPath(key string) (string, error) {
+	if err := checkLocalKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.rootDir, localMetadataDir, filepath.FromSlash(key)+".json"), nil
+}
+
+func checkLocalKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid key name %q", key)
+	}
+	return nil
+}
+
+// Details of a multipart upload that are stored in its directory
+type localMultipartUpload struct {
+	Key      string            `json:"key"`
+	Metadata metadata.Metadata `json:"metadata"`
+	// Maximum size of the file in bytes. Zero means there's no limit.
+	MaxSize uint64 `json:"max-size,omitempty"`
+}
+
+func (s *LocalStorage) CreateMultipartUpload(fileInfo UploadFileInfo) (MultipartUpload, error) {
+	key := fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String())
+	if _, err := s.objectPath(key); err != nil {
+		return MultipartUpload{}, fmt.Errorf("failed to create multipart upload with key name %s: %s", key, err.Error())
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return MultipartUpload{}, fmt.Errorf("failed to create multipart upload with key name %s: %s", key, err.Error())
+	}
+	upload := MultipartUpload{Key: key, UploadID: hex.EncodeToString(id)}
+	data, err := json.Marshal(localMultipartUpload{Key: key, Metadata: fileInfo.Metadata, MaxSize: fileInfo.MaxSize})
+	if err != nil {
+		return MultipartUpload{}, fmt.Errorf("failed to create multipart upload with key name %s: %s", key, err.Error())
+	}
+	uploadDir := filepath.Join(s.rootDir, localMultipartDir, upload.UploadID)
+	if err := os.MkdirAll(uploadDir, 0o750); err != nil {
+		return MultipartUpload{}, fmt.Errorf("failed to create multipart upload with key name %s: %s", key, err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(uploadDir, "upload.json"), data, 0o640); err != nil {
+		return MultipartUpload{}, fmt.Errorf("failed to create multipart upload with key name %s: %s", key, err.Error())
+	}
+	return upload, nil
+}
+
+func (s *LocalStorage) UploadParts(upload MultipartUpload, partNumbers []int32, expireTime time.Duration) ([]url.URL, error) {
+	details, err := s.readMultipartUpload(upload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create links of parts with key name %s: %s", upload.Key, err.Error())
+	}
+	urls := make([]url.URL, 0, len(partNumbers))
+	for _, partNumber := range partNumbers {
+		query := url.Values{}
+		query.Set(localUploadIDParam, upload.UploadID)
+		query.Set(localPartNumberParam, strconv.Itoa(int(partNumber)))
+		if details.MaxSize > 0 {
+			query.Set(localMaxSizeParam, strconv.FormatUint(details.MaxSize, 10))
+		}
+		urls = append(urls, s.signedURL(http.MethodPut, upload.Key, query, expireTime))
+	}
+	return urls, nil
+}
+
+func (s *LocalStorage) CompleteMultipartUpload(upload MultipartUpload, parts []CompletedPart) error {
+	details, err := s.readMultipartUpload(upload)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload with key name %s: %s", upload.Key, err.Error())
+	}
+	uploadDir := filepath.Join(s.rootDir, localMultipartDir, upload.UploadID)
+	parts = slices.Clone(parts)
+	slices.SortFunc(parts, func(a, b CompletedPart) int {
+		return int(a.PartNumber - b.PartNumber)
+	})
+	partPaths := make([]string, 0, len(parts))
+	for _, part := range parts {
+		partPath := filepath.Join(uploadDir, fmt.Sprintf("%d.part", part.PartNumber))
+		etag, err := fileMD5(partPath)
+		if err != nil {
+			return fmt.Errorf("failed to complete multipart upload with key name %s: part %d not found",
+				upload.Key, part.PartNumber)
+		}
+		if etag != strings.Trim(part.ETag, "\"") {
+			return fmt.Errorf("failed to complete multipart upload with key name %s: ETag of part %d doesn't match",
+				upload.Key, part.PartNumber)
+		}
+		partPaths = append(partPaths, partPath)
+	}
+
+	// Parts are opened one by one to not exceed the limit of open files
+	reader, writer := io.Pipe()
+	go func() {
+		for _, partPath := range partPaths {
+			f, err := os.Open(partPath)
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			_, err = io.Copy(writer, f)
+			f.Close()
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+		writer.Close()
+	}()
+	_, err = s.writeObject(upload.Key, reader, details.Metadata, 0, "")
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload with key name %s: %s", upload.Key, err.Error())
+	}
+	if err := os.RemoveAll(uploadDir); err != nil {
+		s.logger.Warnf("Failed to remove parts of multipart upload %s: %s", upload.UploadID, err.Error())
+	}
+	return nil
+}
+
+func (s *LocalStorage) AbortMultipartUpload(upload MultipartUpload) error {
+	if _, err := s.readMultipartUpload(upload); err != nil {
+		return fmt.Errorf("failed to abort multipart upload with key name %s: %s", upload.Key, err.Error())
+	}
+	if err := os.RemoveAll(filepath.Join(s.rootDir, localMultipartDir, upload.UploadID)); err != nil {
+		return fmt.Errorf("failed to abort multipart upload with key name %s: %s", upload.Key, err.Error())
+	}
+	return nil
+}
+
+func (s *LocalStorage) MultipartMetadata(upload MultipartUpload) (metadata.Metadata, error) {
+	details, err := s.readMultipartUpload(upload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata of multipart upload %s: %w", upload.UploadID, err)
+	}
+	return details.Metadata, nil
+}
+
+func fileMD5(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Read details of the multipart upload and check it belongs to the key. If the upload
+// doesn't exist, the returned error wraps ErrNotFound.
+func (s *LocalStorage) readMultipartUpload(upload MultipartUpload) (*localMultipartUpload, error) {
+	if _, err := hex.DecodeString(upload.UploadID); err != nil || upload.UploadID == "" {
+		return nil, fmt.Errorf("invalid upload id %q: %w", upload.UploadID, ErrNotFound)
+	}
+	data, err := os.ReadFile(filepath.Join(s.rootDir, localMultipartDir, upload.UploadID, "upload.json"))
+	if err != nil {
+		return nil, fmt.Errorf("multipart upload %s: %w", upload.UploadID, ErrNotFound)
+	}
+	var details localMultipartUpload
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, fmt.Errorf("reading multipart upload %s error: %s", upload.UploadID, err.Error())
+	}
+	if details.Key != upload.Key {
+		return nil, fmt.Errorf("multipart upload %s doesn't belong to key %s: %w", upload.UploadID, upload.Key, ErrNotFound)
+	}
+	return &details, nil
+}
+
+func (s *LocalStorage) StatFile(key string) (FileStat, error) {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return FileStat{}, fmt.Errorf("failed to get details of file with key name %s: %w", key, ErrNotFound)
+	}
+	stat, err := os.Stat(objectPath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && stat.IsDir()) {
+		return FileStat{}, fmt.Errorf("failed to get details of file with key name %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return FileStat{}, fmt.Errorf("failed to get details of file with key name %s: %s", key, err.Error())
+	}
+	info, err := s.readObjectInfo(key)
+	if err != nil {
+		return FileStat{}, fmt.Errorf("failed to get details of file with key name %s: %s", key, err.Error())
+	}
+	return FileStat{
+		Key:            key,
+		Size:           uint64(stat.Size()),
+		ETag:           info.ETag,
+		ChecksumSHA256: info.ChecksumSHA256,
+		Metadata:       info.Metadata,
+		LastModified:   stat.ModTime(),
+		Finalized:      info.Finalized,
+	}, nil
+}
+
+func (s *LocalStorage) GetMetadata(key string) (metadata.Metadata, error) {
+	stat, err := s.StatFile(key)
+	if err != nil {
+		return nil, err
+	}
+	return stat.Metadata, nil
+}
+
+func (s *LocalStorage) FinalizeFile(key string) error {
+	info, err := s.readObjectInfo(key)
+	if err != nil {
+		return fmt.Errorf("failed to finalize file with key name %s: %s", key, err.Error())
+	}
+	info.Finalized = true
+	if err := s.writeObjectInfo(key, info); err != nil {
+		return fmt.Errorf("failed to finalize file with key name %s: %s", key, err.Error())
+	}
+	return nil
+}
+
+func (s *LocalStorage) DeleteFile(key string) error {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete file with key name %s: %s", key, err.Error())
+	}
+	metadataPath, _ := s.metadataPath(key)
+	for _, p := range []string{objectPath, metadataPath} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete file with key name %s: %s", key, err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *LocalStorage) CopyFile(srcKey, dstKey string) error {
+	info, err := s.readExistingObject(srcKey)
+	if err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %w", srcKey, err)
+	}
+	srcPath, _ := s.objectPath(srcKey)
+	dstPath, err := s.objectPath(dstKey)
+	if err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %s", srcKey, err.Error())
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %s", srcKey, err.Error())
+	}
+	defer src.Close()
+	if _, _, err := writeFile(dstPath, src, 0, ""); err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %s", srcKey, err.Error())
+	}
+	if err := s.writeObjectInfo(dstKey, info); err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %s", srcKey, err.Error())
+	}
+	return nil
+}
+
+func (s *LocalStorage) MoveFile(srcKey, dstKey string) error {
+	if _, err := s.readExistingObject(srcKey); err != nil {
+		return fmt.Errorf("failed to move file with key name %s: %w", srcKey, err)
+	}
+	srcPath, _ := s.objectPath(srcKey)
+	srcMetadataPath, _ := s.metadataPath(srcKey)
+	dstPath, err := s.objectPath(dstKey)
+	if err != nil {
+		return fmt.Errorf("failed to move file with key name %s: %s", srcKey, err.Error())
+	}
+	dstMetadataPath, _ := s.metadataPath(dstKey)
+	// The file itself is moved last, because the destination exists just after that
+	for _, paths := range [][2]string{{srcMetadataPath, dstMetadataPath}, {srcPath, dstPath}} {
+		if err := os.MkdirAll(filepath.Dir(paths[1]), 0o750); err != nil {
+			return fmt.Errorf("failed to move file with key name %s: %s", srcKey, err.Error())
+		}
+		if err := os.Rename(paths[0], paths[1]); err != nil {
+			return fmt.Errorf("failed to move file with key name %s: %s", srcKey, err.Error())
+		}
+	}
+	return nil
+}
+
+// Return details of the file if it exists. Otherwise, the returned error wraps ErrNotFound.
+func (s *LocalStorage) readExistingObject(key string) (localObjectInfo, error) {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return localObjectInfo{}, ErrNotFound
+	}
+	if stat, err := os.Stat(objectPath); errors.Is(err, os.ErrNotExist) || (err == nil && stat.IsDir()) {
+		return localObjectInfo{}, ErrNotFound
+	} else if err != nil {
+		return localObjectInfo{}, err
+	}
+	return s.readObjectInfo(key)
+}
+
+func (s *LocalStorage) ListFiles(prefix, cursor string, limit int32) (FileList, error) {
+	objectsDir := filepath.Join(s.rootDir, localObjectsDir)
+	// Just the directory that contains the prefix is walked
+	walkDir := objectsDir
+	if dir := path.Dir(prefix); strings.Contains(prefix, "/") && checkLocalKey(dir) == nil {
+		walkDir = filepath.Join(objectsDir, filepath.FromSlash(dir))
+	}
+	var keys []string
+	err := filepath.WalkDir(walkDir, func(filePath string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Temporary files of the uploads that are in progress are skipped
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(objectsDir, filePath)
+		if err != nil {
+			return err
+		}
+		// The cursor is the last key of the previous page
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) && key > cursor {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return FileList{}, fmt.Errorf("failed to list files with prefix %s: %s", prefix, err.Error())
+	}
+	slices.Sort(keys)
+
+	var list FileList
+	if len(keys) > int(limit) {
+		keys = keys[:limit]
+		list.NextCursor = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		stat, err := s.StatFile(key)
+		// The file is deleted after listing it
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return FileList{}, fmt.Errorf("failed to list files with prefix %s: %s", prefix, err.Error())
+		}
+		list.Files = append(list.Files, stat)
+	}
+	return list, nil
+}
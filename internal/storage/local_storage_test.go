@@ -0,0 +1,728 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	"github.com/q-sharafian/file-transfer/internal/server"
+	l "github.com/q-sharafian/file-transfer/pkg/logger"
+)
+
+// Keeps the handlers that are added to the server, so requests could be sent to them
+type handlerServer map[string]func(w server.ResponseWriter, r *server.Request)
+
+func (s handlerServer) AddHandler(path string, handler func(w server.ResponseWriter, r *server.Request)) {
+	s[path] = handler
+}
+
+func (s handlerServer) HandleOneTime(path string, handler func(w server.ResponseWriter, r *server.Request)) {
+	s[path] = handler
+}
+
+// Create a local storage in a temporary directory. The returned function sends the
+// request of a link to the storage.
+func newTestLocalStorage(t *testing.T) (*LocalStorage, func(method string, link url.URL, body string) *httptest.ResponseRecorder) {
+	t.Helper()
+	t.Setenv("LOCAL_STORAGE_DIR", t.TempDir())
+	t.Setenv("LOCAL_STORAGE_BASE_URL", "http://files.test")
+	t.Setenv("LOCAL_STORAGE_PATH", "/objects")
+	t.Setenv("LOCAL_STORAGE_SECRET", "local-storage-test-secret")
+	srv := handlerServer{}
+	s := NewLocalStorage(srv, l.NewSLogger(l.Error, nil, os.Stderr)).(*LocalStorage)
+	handler, ok := srv["/objects/"]
+	if !ok {
+		t.Fatalf("local storage didn't add its handler under /objects/")
+	}
+	return s, func(method string, link url.URL, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, link.String(), strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler(w, &server.Request{Request: r})
+		return w
+	}
+}
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	s, do := newTestLocalStorage(t)
+	upload, err := s.UploadFile(UploadFileInfo{FileName: "dir/a", FileExtension: "pdf",
+		Metadata: metadata.Metadata{"RealName": "report"}}, time.Minute)
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	link := upload.URL
+	if link.Host != "files.test" || link.Path != "/objects/dir/a.pdf" || len(upload.Headers) != 0 {
+		t.Errorf("UploadFile() = %+v, want a link to /objects/dir/a.pdf on the base URL", upload)
+	}
+	w := do(http.MethodPut, link, "content")
+	if w.Code != http.StatusOK {
+		t.Fatalf("uploading status = %d: %s", w.Code, w.Body.String())
+	}
+	sum := md5.Sum([]byte("content"))
+	if etag := w.Header().Get("ETag"); etag != fmt.Sprintf("%q", hex.EncodeToString(sum[:])) {
+		t.Errorf("ETag = %s, want MD5 hash of the file", etag)
+	}
+
+	stat, err := s.StatFile("dir/a.pdf")
+	if err != nil {
+		t.Fatalf("StatFile() error = %v", err)
+	}
+	checksum := sha256.Sum256([]byte("content"))
+	if stat.Size != 7 || stat.ETag != hex.EncodeToString(sum[:]) || stat.Finalized ||
+		stat.ChecksumSHA256 != base64.StdEncoding.EncodeToString(checksum[:]) {
+		t.Errorf("StatFile() = %+v, want details of the uploaded file", stat)
+	}
+	if stat.Metadata["RealName"] != "report" {
+		t.Errorf("stored metadata = %v, want the metadata of the link", stat.Metadata)
+	}
+
+	link, err = s.DownloadFile(DownloadFileInfo{FileName: "dir/a.pdf"}, time.Minute)
+	if err != nil {
+		t.Fatalf("DownloadFile() error = %v", err)
+	}
+	w = do(http.MethodGet, link, "")
+	if body, _ := io.ReadAll(w.Body); w.Code != http.StatusOK || string(body) != "content" {
+		t.Errorf("downloading = %d, %q, want the uploaded file", w.Code, body)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=report.pdf" {
+		t.Errorf("Content-Disposition = %q, want the real name of the file", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("Content-Type = %q, want application/pdf", got)
+	}
+}
+
+func TestLocalStorageDownloadHeaders(t *testing.T) {
+	s, do := newTestLocalStorage(t)
+	for _, key := range []string{"a.png", "a.svg"} {
+		if _, err := s.writeObject(key, strings.NewReader("content"), metadata.Metadata{}, 0, ""); err != nil {
+			t.Fatalf("writeObject() error = %v", err)
+		}
+	}
+	tests := []struct {
+		name            string
+		fileInfo        DownloadFileInfo
+		wantDisposition string
+		wantType        string
+	}{
+		{"attachment", DownloadFileInfo{FileName: "a.png"}, "attachment; filename=a.png", "image/png"},
+		{"real name", DownloadFileInfo{FileName: "a.png", RealName: "photo 1.png"}, `attachment; filename="photo 1.png"`,
+			"image/png"},
+		{"inline", DownloadFileInfo{FileName: "a.png", Inline: true}, "inline; filename=a.png", "image/png"},
+		// Scripts of the file could run on the origin of the app
+		{"inline SVG", DownloadFileInfo{FileName: "a.svg", Inline: true}, "attachment; filename=a.svg",
+			"application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := s.DownloadFile(tt.fileInfo, time.Minute)
+			if err != nil {
+				t.Fatalf("DownloadFile() error = %v", err)
+			}
+			w := do(http.MethodGet, link, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("downloading status = %d: %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Disposition"); got != tt.wantDisposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.wantDisposition)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if w.Header().Get("X-Content-Type-Options") != "nosniff" ||
+				!strings.HasPrefix(w.Header().Get("Content-Security-Policy"), "sandbox") {
+				t.Errorf("headers = %v, want nosniff and a sandbox policy", w.Header())
+			}
+
+			// Headers are signed, so clients can't change them
+			query := link.Query()
+			query.Set("response-content-type", "text/html")
+			link.RawQuery = query.Encode()
+			if w := do(http.MethodGet, link, ""); w.Code != http.StatusForbidden {
+				t.Errorf("downloading with another content type status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+	if _, err := s.DownloadFile(DownloadFileInfo{FileName: "missing.png"}, time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DownloadFile() of a missing file error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalStorageRejectsInvalidLinks(t *testing.T) {
+	s, do := newTestLocalStorage(t)
+	if _, err := s.writeObject("a.pdf", strings.NewReader("content"), metadata.Metadata{}, 0, ""); err != nil {
+		t.Fatalf("writeObject() error = %v", err)
+	}
+	other := &LocalStorage{baseURL: s.baseURL, routePath: s.routePath, secret: []byte("another-secret")}
+
+	tests := []struct {
+		name       string
+		method     string
+		link       func() url.URL
+		wantStatus int
+	}{
+		{
+			name: "valid", method: http.MethodGet, wantStatus: http.StatusOK,
+			link: func() url.URL { return s.signedURL(http.MethodGet, "a.pdf", url.Values{}, time.Minute) },
+		},
+		{
+			name: "expired", method: http.MethodGet, wantStatus: http.StatusForbidden,
+			link: func() url.URL { return s.signedURL(http.MethodGet, "a.pdf", url.Values{}, -time.Minute) },
+		},
+		{
+			name: "signed by another secret", method: http.MethodGet, wantStatus: http.StatusForbidden,
+			link: func() url.URL { return other.signedURL(http.MethodGet, "a.pdf", url.Values{}, time.Minute) },
+		},
+		{
+			name: "download link used to upload", method: http.MethodPut, wantStatus: http.StatusForbidden,
+			link: func() url.URL { return s.signedURL(http.MethodGet, "a.pdf", url.Values{}, time.Minute) },
+		},
+		{
+			name: "link of another key", method: http.MethodGet, wantStatus: http.StatusForbidden,
+			link: func() url.URL {
+				link := s.signedURL(http.MethodGet, "b.pdf", url.Values{}, time.Minute)
+				link.Path = "/objects/a.pdf"
+				return link
+			},
+		},
+		{
+			name: "extended expiration time", method: http.MethodGet, wantStatus: http.StatusForbidden,
+			link: func() url.URL {
+				link := s.signedURL(http.MethodGet, "a.pdf", url.Values{}, -time.Minute)
+				query := link.Query()
+				query.Set(localExpiresParam, fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+				link.RawQuery = query.Encode()
+				return link
+			},
+		},
+		{
+			name: "added metadata", method: http.MethodPut, wantStatus: http.StatusForbidden,
+			link: func() url.URL {
+				link := s.signedURL(http.MethodPut, "a.pdf", url.Values{}, time.Minute)
+				query := link.Query()
+				query.Set(localMetaParamPrefix+"RealName", "forged")
+				link.RawQuery = query.Encode()
+				return link
+			},
+		},
+		{
+			name: "changed signature", method: http.MethodGet, wantStatus: http.StatusForbidden,
+			link: func() url.URL {
+				link := s.signedURL(http.MethodGet, "a.pdf", url.Values{}, time.Minute)
+				query := link.Query()
+				signature := []byte(query.Get(localSignatureParam))
+				signature[0] ^= 1
+				query.Set(localSignatureParam, string(signature))
+				link.RawQuery = query.Encode()
+				return link
+			},
+		},
+		{
+			name: "without signature", method: http.MethodGet, wantStatus: http.StatusForbidden,
+			link: func() url.URL {
+				link := s.signedURL(http.MethodGet, "a.pdf", url.Values{}, time.Minute)
+				query := link.Query()
+				query.Del(localSignatureParam)
+				link.RawQuery = query.Encode()
+				return link
+			},
+		},
+		{
+			name: "without expiration time", method: http.MethodGet, wantStatus: http.StatusForbidden,
+			link: func() url.URL {
+				link := s.signedURL(http.MethodGet, "a.pdf", url.Values{}, time.Minute)
+				query := link.Query()
+				query.Del(localExpiresParam)
+				link.RawQuery = query.Encode()
+				return link
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.link(), "forged")
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.rootDir, localObjectsDir, "a.pdf"))
+	if err != nil || string(data) != "content" {
+		t.Errorf("stored file = %q, %v, want it unchanged", data, err)
+	}
+}
+
+// Send the form with the file to the storage like browsers
+func postForm(t *testing.T, s *LocalStorage, form UploadForm, file string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for k, v := range form.Fields {
+		if err := writer.WriteField(k, v); err != nil {
+			t.Fatalf("failed to write the form: %v", err)
+		}
+	}
+	if file != "" {
+		part, err := writer.CreateFormFile("file", "a.pdf")
+		if err != nil {
+			t.Fatalf("failed to write the form: %v", err)
+		}
+		io.WriteString(part, file)
+	}
+	writer.Close()
+	r := httptest.NewRequest(http.MethodPost, form.URL.String(), &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.serveObject(w, &server.Request{Request: r})
+	return w
+}
+
+func TestLocalStorageUploadForm(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSize    uint64
+		fields     map[string]string
+		file       string
+		wantStatus int
+	}{
+		{name: "smaller than maximum size", maxSize: 8, file: "content", wantStatus: http.StatusOK},
+		{name: "same as maximum size", maxSize: 7, file: "content", wantStatus: http.StatusOK},
+		{name: "larger than maximum size", maxSize: 6, file: "content", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "without maximum size", file: "content", wantStatus: http.StatusOK},
+		{name: "increased maximum size", maxSize: 6, fields: map[string]string{localMaxSizeParam: "100"},
+			file: "content", wantStatus: http.StatusForbidden},
+		{name: "removed maximum size", maxSize: 6, fields: map[string]string{localMaxSizeParam: ""},
+			file: "content", wantStatus: http.StatusForbidden},
+		{name: "without file", maxSize: 8, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestLocalStorage(t)
+			form, err := s.UploadFileForm(UploadFileInfo{FileName: "a", FileExtension: "pdf", MaxSize: tt.maxSize}, time.Minute)
+			if err != nil {
+				t.Fatalf("UploadFileForm() error = %v", err)
+			}
+			if form.URL.RawQuery != "" {
+				t.Errorf("URL of the form = %s, want the signed values just in the fields", form.URL.String())
+			}
+			for k, v := range tt.fields {
+				if v == "" {
+					delete(form.Fields, k)
+				} else {
+					form.Fields[k] = v
+				}
+			}
+			w := postForm(t, s, form, tt.file)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			_, err = os.Stat(filepath.Join(s.rootDir, localObjectsDir, "a.pdf"))
+			if stored := err == nil; stored != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("file is stored = %v, want %v", stored, !stored)
+			}
+		})
+	}
+}
+
+func TestLocalStorageUploadMaxSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize uint64
+		// Value of the max size in the query of the link. It's removed if it's "-".
+		query      string
+		file       string
+		wantStatus int
+	}{
+		{name: "smaller than maximum size", maxSize: 8, file: "content", wantStatus: http.StatusOK},
+		{name: "same as maximum size", maxSize: 7, file: "content", wantStatus: http.StatusOK},
+		{name: "larger than maximum size", maxSize: 6, file: "content", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "without maximum size", file: "content", wantStatus: http.StatusOK},
+		{name: "increased maximum size", maxSize: 6, query: "100", file: "content", wantStatus: http.StatusForbidden},
+		{name: "removed maximum size", maxSize: 6, query: "-", file: "content", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, do := newTestLocalStorage(t)
+			upload, err := s.UploadFile(UploadFileInfo{FileName: "a", FileExtension: "pdf", MaxSize: tt.maxSize}, time.Minute)
+			if err != nil {
+				t.Fatalf("UploadFile() error = %v", err)
+			}
+			mpUpload, err := s.CreateMultipartUpload(UploadFileInfo{FileName: "b", FileExtension: "pdf", MaxSize: tt.maxSize})
+			if err != nil {
+				t.Fatalf("CreateMultipartUpload() error = %v", err)
+			}
+			parts, err := s.UploadParts(mpUpload, []int32{1}, time.Minute)
+			if err != nil {
+				t.Fatalf("UploadParts() error = %v", err)
+			}
+			partPath := filepath.Join(s.rootDir, localMultipartDir, mpUpload.UploadID, "1.part")
+
+			// A part can't be larger than the whole file either.
+			for _, link := range []struct {
+				url  url.URL
+				path string
+			}{
+				{upload.URL, filepath.Join(s.rootDir, localObjectsDir, "a.pdf")},
+				{parts[0], partPath},
+			} {
+				query := link.url.Query()
+				switch tt.query {
+				case "":
+				case "-":
+					query.Del(localMaxSizeParam)
+				default:
+					query.Set(localMaxSizeParam, tt.query)
+				}
+				link.url.RawQuery = query.Encode()
+				w := do(http.MethodPut, link.url, tt.file)
+				if w.Code != tt.wantStatus {
+					t.Fatalf("uploading to %s status = %d, want %d: %s",
+						link.url.Path, w.Code, tt.wantStatus, w.Body.String())
+				}
+				_, err = os.Stat(link.path)
+				if stored := err == nil; stored != (tt.wantStatus == http.StatusOK) {
+					t.Errorf("%s is stored = %v, want %v", filepath.Base(link.path), stored, !stored)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalStorageRejectsEscapingKeys(t *testing.T) {
+	s, do := newTestLocalStorage(t)
+	// A file out of the storage directory
+	secretPath := filepath.Join(filepath.Dir(s.rootDir), "secret.pdf")
+	if err := os.WriteFile(secretPath, []byte("secret"), 0o600); err != nil {
+		t.Fatalf("failed to write the file: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(secretPath) })
+
+	for _, key := range []string{"../secret", "dir/../../secret", "/secret"} {
+		if _, err := s.UploadFile(UploadFileInfo{FileName: key, FileExtension: "pdf"}, time.Minute); err == nil {
+			t.Errorf("UploadFile() with file name %q error = nil", key)
+		}
+		if _, err := s.DownloadFile(DownloadFileInfo{FileName: key + ".pdf"}, time.Minute); err == nil {
+			t.Errorf("DownloadFile() with file name %q error = nil", key+".pdf")
+		}
+	}
+
+	// Even with a valid signature, the files out of the directory aren't served.
+	for _, key := range []string{"../../secret.pdf", "dir/../../../secret.pdf"} {
+		link := s.signedURL(http.MethodGet, key, url.Values{}, time.Minute)
+		link.Path = "/objects/" + key
+		if w := do(http.MethodGet, link, ""); w.Code == http.StatusOK {
+			t.Errorf("downloading %q = %d: %s", key, w.Code, w.Body.String())
+		}
+		link = s.signedURL(http.MethodPut, key, url.Values{}, time.Minute)
+		link.Path = "/objects/" + key
+		if w := do(http.MethodPut, link, "forged"); w.Code == http.StatusOK {
+			t.Errorf("uploading %q = %d", key, w.Code)
+		}
+	}
+	if data, _ := os.ReadFile(secretPath); string(data) != "secret" {
+		t.Errorf("file out of the storage directory is changed to %q", data)
+	}
+}
+
+func TestLocalStorageMultipart(t *testing.T) {
+	s, do := newTestLocalStorage(t)
+	upload, err := s.CreateMultipartUpload(UploadFileInfo{FileName: "a", FileExtension: "pdf",
+		Metadata: metadata.Metadata{"RealName": "report"}})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+	other, err := s.CreateMultipartUpload(UploadFileInfo{FileName: "b", FileExtension: "pdf"})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+	links, err := s.UploadParts(upload, []int32{1, 2}, time.Minute)
+	if err != nil {
+		t.Fatalf("UploadParts() error = %v", err)
+	}
+	var parts []CompletedPart
+	for i, data := range []string{"first-", "second"} {
+		w := do(http.MethodPut, links[i], data)
+		if w.Code != http.StatusOK {
+			t.Fatalf("uploading part %d status = %d: %s", i+1, w.Code, w.Body.String())
+		}
+		parts = append(parts, CompletedPart{PartNumber: int32(i + 1), ETag: w.Header().Get("ETag")})
+	}
+
+	// Links of the parts are signed with their upload.
+	link := links[0]
+	query := link.Query()
+	query.Set(localUploadIDParam, other.UploadID)
+	link.RawQuery = query.Encode()
+	if w := do(http.MethodPut, link, "forged"); w.Code != http.StatusForbidden {
+		t.Errorf("uploading part of another upload status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	for _, invalid := range []MultipartUpload{
+		{Key: "b.pdf", UploadID: upload.UploadID},
+		{Key: "a.pdf", UploadID: "../" + upload.UploadID},
+		{Key: "a.pdf", UploadID: ""},
+	} {
+		if _, err := s.UploadParts(invalid, []int32{1}, time.Minute); err == nil {
+			t.Errorf("UploadParts(%+v) error = nil", invalid)
+		}
+		if _, err := s.MultipartMetadata(invalid); !errors.Is(err, ErrNotFound) {
+			t.Errorf("MultipartMetadata(%+v) error = %v, want ErrNotFound", invalid, err)
+		}
+	}
+	if md, err := s.MultipartMetadata(upload); err != nil || md["RealName"] != "report" {
+		t.Errorf("MultipartMetadata() = %v, %v, want metadata of the upload", md, err)
+	}
+
+	wrongETag := []CompletedPart{parts[0], {PartNumber: 2, ETag: `"wrong"`}}
+	if err := s.CompleteMultipartUpload(upload, wrongETag); err == nil {
+		t.Error("CompleteMultipartUpload() with a wrong ETag error = nil")
+	}
+	// Parts are joined in order of their numbers.
+	if err := s.CompleteMultipartUpload(upload, []CompletedPart{parts[1], parts[0]}); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(s.rootDir, localObjectsDir, "a.pdf"))
+	if err != nil || string(data) != "first-second" {
+		t.Errorf("completed file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(s.rootDir, localMultipartDir, upload.UploadID)); !os.IsNotExist(err) {
+		t.Errorf("parts aren't removed after completing the upload: %v", err)
+	}
+	if _, err := s.MultipartMetadata(upload); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MultipartMetadata() of a completed upload error = %v, want ErrNotFound", err)
+	}
+
+	otherLinks, err := s.UploadParts(other, []int32{1}, time.Minute)
+	if err != nil {
+		t.Fatalf("UploadParts() error = %v", err)
+	}
+	if err := s.AbortMultipartUpload(other); err != nil {
+		t.Fatalf("AbortMultipartUpload() error = %v", err)
+	}
+	if w := do(http.MethodPut, otherLinks[0], "late"); w.Code != http.StatusNotFound {
+		t.Errorf("uploading part of an aborted upload status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	links, _ = s.UploadParts(upload, []int32{1}, time.Minute)
+	if len(links) != 0 {
+		t.Error("UploadParts() of a completed upload returned links")
+	}
+	if err := s.AbortMultipartUpload(other); err == nil {
+		t.Error("AbortMultipartUpload() of an aborted upload error = nil")
+	}
+}
+
+func TestCheckLocalKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{"a.pdf", false},
+		{"alice/2024/a.pdf", false},
+		{"", true},
+		{"/etc/passwd", true},
+		{"../a.pdf", true},
+		{"..", true},
+		{"alice/../../a.pdf", true},
+		{"alice//a.pdf", true},
+		{"alice\\a.pdf", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := checkLocalKey(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("checkLocalKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalStorageFinalizeAndDelete(t *testing.T) {
+	s, _ := newTestLocalStorage(t)
+	if _, err := s.StatFile("a.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("StatFile() of a missing file error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.StatFile("../a.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("StatFile() of an invalid key error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.writeObject("a.pdf", strings.NewReader("content"), metadata.Metadata{"RealName": "report"}, 0, ""); err != nil {
+		t.Fatalf("writeObject() error = %v", err)
+	}
+
+	if err := s.FinalizeFile("a.pdf"); err != nil {
+		t.Fatalf("FinalizeFile() error = %v", err)
+	}
+	stat, err := s.StatFile("a.pdf")
+	if err != nil || !stat.Finalized || stat.Metadata["RealName"] != "report" {
+		t.Errorf("StatFile() = %+v, %v, want a finalized file with its metadata", stat, err)
+	}
+
+	if err := s.DeleteFile("a.pdf"); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if _, err := s.StatFile("a.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("StatFile() of a deleted file error = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.rootDir, localMetadataDir, "a.pdf.json")); !os.IsNotExist(err) {
+		t.Errorf("metadata of the deleted file isn't deleted: %v", err)
+	}
+	if err := s.DeleteFile("a.pdf"); err != nil {
+		t.Errorf("DeleteFile() of a missing file error = %v", err)
+	}
+	if err := s.FinalizeFile("a.pdf"); err == nil {
+		t.Error("FinalizeFile() of a missing file succeeded")
+	}
+}
+
+func TestLocalStorageTransfer(t *testing.T) {
+	s, _ := newTestLocalStorage(t)
+	if _, err := s.writeObject("a.pdf", strings.NewReader("content"), metadata.Metadata{"RealName": "report"}, 0, ""); err != nil {
+		t.Fatalf("writeObject() error = %v", err)
+	}
+	if err := s.FinalizeFile("a.pdf"); err != nil {
+		t.Fatalf("FinalizeFile() error = %v", err)
+	}
+	if err := s.CopyFile("a.pdf", "dir/b.pdf"); err != nil {
+		t.Fatalf("CopyFile() error = %v", err)
+	}
+	if err := s.MoveFile("a.pdf", "other/c.pdf"); err != nil {
+		t.Fatalf("MoveFile() error = %v", err)
+	}
+	if _, err := s.StatFile("a.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("StatFile() of the moved file error = %v, want ErrNotFound", err)
+	}
+	for _, key := range []string{"dir/b.pdf", "other/c.pdf"} {
+		stat, err := s.StatFile(key)
+		if err != nil || stat.Size != 7 || !stat.Finalized || stat.Metadata["RealName"] != "report" {
+			t.Errorf("StatFile(%q) = %+v, %v, want the source with its metadata", key, stat, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		src, dst string
+	}{
+		{"missing source", "a.pdf", "d.pdf"},
+		{"escaping source", "../a.pdf", "d.pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.CopyFile(tt.src, tt.dst); !errors.Is(err, ErrNotFound) {
+				t.Errorf("CopyFile() error = %v, want ErrNotFound", err)
+			}
+			if err := s.MoveFile(tt.src, tt.dst); !errors.Is(err, ErrNotFound) {
+				t.Errorf("MoveFile() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+	for _, dst := range []string{"../d.pdf", "dir/../../d.pdf"} {
+		if err := s.CopyFile("dir/b.pdf", dst); err == nil {
+			t.Errorf("CopyFile() to %q error = nil", dst)
+		}
+		if err := s.MoveFile("dir/b.pdf", dst); err == nil {
+			t.Errorf("MoveFile() to %q error = nil", dst)
+		}
+	}
+}
+
+func TestLocalStorageListFiles(t *testing.T) {
+	s, _ := newTestLocalStorage(t)
+	for _, key := range []string{"u1/c.pdf", "u1/a.pdf", "u1/dir/b.pdf", "u10/a.pdf", "u2/a.pdf"} {
+		if _, err := s.writeObject(key, strings.NewReader(key), metadata.Metadata{"RealName": "report"}, 0, ""); err != nil {
+			t.Fatalf("writeObject() error = %v", err)
+		}
+	}
+	// Temporary files of an upload in progress
+	if err := os.WriteFile(filepath.Join(s.rootDir, localObjectsDir, "u1", ".upload-1"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		prefix     string
+		cursor     string
+		limit      int32
+		wantKeys   []string
+		wantCursor string
+	}{
+		{"first page", "u1/", "", 2, []string{"u1/a.pdf", "u1/c.pdf"}, "u1/c.pdf"},
+		{"last page", "u1/", "u1/c.pdf", 2, []string{"u1/dir/b.pdf"}, ""},
+		{"exact page", "u1/", "", 3, []string{"u1/a.pdf", "u1/c.pdf", "u1/dir/b.pdf"}, ""},
+		{"prefix of a name", "u1", "", 10, []string{"u1/a.pdf", "u1/c.pdf", "u1/dir/b.pdf", "u10/a.pdf"}, ""},
+		{"missing directory", "u3/", "", 10, nil, ""},
+		{"escaping prefix", "../", "", 10, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := s.ListFiles(tt.prefix, tt.cursor, tt.limit)
+			if err != nil {
+				t.Fatalf("ListFiles() error = %v", err)
+			}
+			var keys []string
+			for _, f := range list.Files {
+				keys = append(keys, f.Key)
+				if f.Size != uint64(len(f.Key)) || f.Metadata["RealName"] != "report" {
+					t.Errorf("listed file = %+v, want its size and metadata", f)
+				}
+			}
+			if !slices.Equal(keys, tt.wantKeys) || list.NextCursor != tt.wantCursor {
+				t.Errorf("ListFiles() = %v, %q, want %v, %q", keys, list.NextCursor, tt.wantKeys, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestLocalStorageChecksum(t *testing.T) {
+	s, do := newTestLocalStorage(t)
+	sum := sha256.Sum256([]byte("content"))
+	checksum := base64.StdEncoding.EncodeToString(sum[:])
+	upload, err := s.UploadFile(UploadFileInfo{FileName: "a", FileExtension: "pdf", ChecksumSHA256: checksum}, time.Minute)
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	tampered := upload.URL
+	query := tampered.Query()
+	query.Del("checksum-sha256")
+	tampered.RawQuery = query.Encode()
+
+	tests := []struct {
+		name       string
+		link       url.URL
+		body       string
+		wantStatus int
+	}{
+		{"another content", upload.URL, "another content", http.StatusBadRequest},
+		{"checksum is removed", tampered, "another content", http.StatusForbidden},
+		{"same content", upload.URL, "content", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(http.MethodPut, tt.link, tt.body); w.Code != tt.wantStatus {
+				t.Errorf("uploading status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			stat, err := s.StatFile("a.pdf")
+			if tt.wantStatus != http.StatusOK {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("rejected file is stored: %+v, %v", stat, err)
+				}
+				return
+			}
+			if err != nil || stat.ChecksumSHA256 != checksum {
+				t.Errorf("StatFile() = %+v, %v, want the checksum of the link", stat, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+)
+
+// Its purpose is just for testing. It keeps all files, metadata and created links in
+// memory and is safe for concurrent use.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]MemoryObject
+	// All links created by the storage. The link id is the index of the slice
+	links []MemoryLink
+	// Multipart uploads that are not completed or aborted yet. The key is the upload id
+	multiparts map[string]*memoryMultipart
+	// Number of created multipart uploads that is used to create upload ids
+	multipartCount int
+}
+
+type memoryMultipart struct {
+	key string
+	metadata.Metadata
+	parts map[int32][]byte
+}
+
+type MemoryObject struct {
+	Data []byte
+	metadata.Metadata
+	LastModified time.Time
+	Finalized    bool
+}
+
+// A link created to upload/download a file
+type MemoryLink struct {
+	Method string
+	Key    string
+	// Metadata the file would be stored with it. (Just for upload links)
+	metadata.Metadata
+	// Maximum size of the file in bytes. (Just for upload forms)
+	MaxSize uint64
+	// SHA-256 checksum of the file in base64. (Just for upload links)
+	ChecksumSHA256 string
+	// Upload id and part number of the part. (Just for links of multipart uploads)
+	UploadID   string
+	PartNumber int32
+	// Headers the file is downloaded with them. (Just for download links)
+	ContentDisposition string
+	ContentType        string
+	ExpiresAt          time.Time
+	URL                url.URL
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		objects:    make(map[string]MemoryObject),
+		multiparts: make(map[string]*memoryMultipart),
+	}
+}
+
+func (s *MemoryStorage) UploadFile(fileInfo UploadFileInfo, expireTime time.Duration) (UploadLink, error) {
+	key := fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String())
+	link := s.addLink(MemoryLink{
+		Method:         "PUT",
+		Key:            key,
+		Metadata:       maps.Clone(fileInfo.Metadata),
+		ChecksumSHA256: fileInfo.ChecksumSHA256,
+		ExpiresAt:      time.Now().Add(expireTime),
+	})
+	return UploadLink{URL: link}, nil
+}
+
+func (s *MemoryStorage) UploadFileForm(fileInfo UploadFileInfo, expireTime time.Duration) (UploadForm, error) {
+	key := fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String())
+	link := s.addLink(MemoryLink{
+		Method:    "POST",
+		Key:       key,
+		Metadata:  maps.Clone(fileInfo.Metadata),
+		MaxSize:   fileInfo.MaxSize,
+		ExpiresAt: time.Now().Add(expireTime),
+	})
+	return UploadForm{link, map[string]string{"key": key}}, nil
+}
+
+func (s *MemoryStorage) DownloadFile(fileInfo DownloadFileInfo, expireTime time.Duration) (url.URL, error) {
+	md, err := s.GetMetadata(fileInfo.FileName)
+	if err != nil {
+		return url.URL{}, fmt.Errorf("failed to create downloading link: %w", err)
+	}
+	return s.addLink(MemoryLink{
+		Method:             "GET",
+		Key:                fileInfo.FileName,
+		ContentDisposition: contentDisposition(fileInfo, md),
+		ContentType:        contentType(fileInfo.FileName),
+		ExpiresAt:          time.Now().Add(expireTime),
+	}), nil
+}
+
+func (s *MemoryStorage) addLink(link MemoryLink) url.URL {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link.URL = url.URL{
+		Scheme:   "memory",
+		Host:     "storage",
+		Path:     "/" + link.Key,
+		RawQuery: url.Values{"id": {strconv.Itoa(len(s.links))}}.Encode(),
+	}
+	s.links = append(s.links, link)
+	return link.URL
+}
+
+// Find the link that is created by the storage
+func (s *MemoryStorage) findLink(link url.URL) (MemoryLink, error) {
+	id, err := strconv.Atoi(link.Query().Get("id"))
+	if err != nil || link.Scheme != "memory" || id < 0 || id >= len(s.links) {
+		return MemoryLink{}, fmt.Errorf("unknown link %s", link.String())
+	}
+	found := s.links[id]
+	if time.Now().After(found.ExpiresAt) {
+		return MemoryLink{}, fmt.Errorf("link %s has expired", link.String())
+	}
+	return found, nil
+}
+
+// Act like a client that uploads data with an upload link/form created by the storage.
+// The returned value is ETag of the data.
+func (s *MemoryStorage) Upload(link url.URL, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found, err := s.findLink(link)
+	if err != nil {
+		return "", err
+	}
+	if found.Method != "PUT" && found.Method != "POST" {
+		return "", fmt.Errorf("link %s is not an upload link", link.String())
+	}
+	if found.MaxSize > 0 && uint64(len(data)) > found.MaxSize {
+		return "", fmt.Errorf("file with %d bytes is larger than the allowed size %d", len(data), found.MaxSize)
+	}
+	if checksum := sha256.Sum256(data); found.ChecksumSHA256 != "" &&
+		base64.StdEncoding.EncodeToString(checksum[:]) != found.ChecksumSHA256 {
+		return "", fmt.Errorf("checksum of the file doesn't match")
+	}
+	if found.UploadID != "" {
+		multipart, ok := s.multiparts[found.UploadID]
+		if !ok {
+			return "", fmt.Errorf("multipart upload %s not found", found.UploadID)
+		}
+		multipart.parts[found.PartNumber] = append([]byte(nil), data...)
+		return memoryETag(data), nil
+	}
+	s.objects[found.Key] = MemoryObject{
+		Data:         append([]byte(nil), data...),
+		Metadata:     maps.Clone(found.Metadata),
+		LastModified: time.Now(),
+	}
+	return memoryETag(data), nil
+}
+
+func memoryETag(data []byte) string {
+	hash := md5.Sum(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Act like a client that downloads a file with a download link created by the storage.
+func (s *MemoryStorage) Download(link url.URL) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found, err := s.findLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if found.Method != "GET" {
+		return nil, fmt.Errorf("link %s is not a download link", link.String())
+	}
+	object, ok := s.objects[found.Key]
+	if !ok {
+		return nil, fmt.Errorf("file with key %s not found", found.Key)
+	}
+	return append([]byte(nil), object.Data...), nil
+}
+
+// Store a file directly without any link.
+func (s *MemoryStorage) PutObject(key string, data []byte, metadata metadata.Metadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = MemoryObject{
+		Data:         append([]byte(nil), data...),
+		Metadata:     maps.Clone(metadata),
+		LastModified: time.Now(),
+	}
+}
+
+// Return the stored file with the key.
+func (s *MemoryStorage) Object(key string) (MemoryObject, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	object, ok := s.objects[key]
+	return object, ok
+}
+
+// Return all links created by the storage in order of creation.
+func (s *MemoryStorage) Links() []MemoryLink {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]MemoryLink(nil), s.links...)
+}
+
+func (s *MemoryStorage) CreateMultipartUpload(fileInfo UploadFileInfo) (MultipartUpload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.multipartCount++
+	upload := MultipartUpload{
+		Key:      fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String()),
+		UploadID: strconv.Itoa(s.multipartCount),
+	}
+	s.multiparts[upload.UploadID] = &memoryMultipart{
+		key:      upload.Key,
+		Metadata: maps.Clone(fileInfo.Metadata),
+		parts:    make(map[int32][]byte),
+	}
+	return upload, nil
+}
+
+func (s *MemoryStorage) UploadParts(upload MultipartUpload, partNumbers []int32, expireTime time.Duration) ([]url.URL, error) {
+	s.mu.RLock()
+	_, err := s.findMultipart(upload)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]url.URL, 0, len(partNumbers))
+	for _, partNumber := range partNumbers {
+		urls = append(urls, s.addLink(MemoryLink{
+			Method:     "PUT",
+			Key:        upload.Key,
+			UploadID:   upload.UploadID,
+			PartNumber: partNumber,
+			ExpiresAt:  time.Now().Add(expireTime),
+		}))
+	}
+	return urls, nil
+}
+
+func (s *MemoryStorage) CompleteMultipartUpload(upload MultipartUpload, parts []CompletedPart) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	multipart, err := s.findMultipart(upload)
+	if err != nil {
+		return err
+	}
+	parts = slices.Clone(parts)
+	slices.SortFunc(parts, func(a, b CompletedPart) int {
+		return int(a.PartNumber - b.PartNumber)
+	})
+	var data []byte
+	for _, part := range parts {
+		partData, ok := multipart.parts[part.PartNumber]
+		if !ok {
+			return fmt.Errorf("part %d of multipart upload %s not found", part.PartNumber, upload.UploadID)
+		}
+		if memoryETag(partData) != strings.Trim(part.ETag, "\"") {
+			return fmt.Errorf("ETag of part %d of multipart upload %s doesn't match", part.PartNumber, upload.UploadID)
+		}
+		data = append(data, partData...)
+	}
+	s.objects[upload.Key] = MemoryObject{Data: data, Metadata: multipart.Metadata, LastModified: time.Now()}
+	delete(s.multiparts, upload.UploadID)
+	return nil
+}
+
+func (s *MemoryStorage) AbortMultipartUpload(upload MultipartUpload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.findMultipart(upload); err != nil {
+		return err
+	}
+	delete(s.multiparts, upload.UploadID)
+	return nil
+}
+
+func (s *MemoryStorage) MultipartMetadata(upload MultipartUpload) (metadata.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	multipart, err := s.findMultipart(upload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata of multipart upload %s: %w", upload.UploadID, ErrNotFound)
+	}
+	return maps.Clone(multipart.Metadata), nil
+}
+
+func (s *MemoryStorage) findMultipart(upload MultipartUpload) (*memoryMultipart, error) {
+	multipart, ok := s.multiparts[upload.UploadID]
+	if !ok || multipart.key != upload.Key {
+		return nil, fmt.Errorf("multipart upload %s with key name %s not found", upload.UploadID, upload.Key)
+	}
+	return multipart, nil
+}
+
+func (s *MemoryStorage) StatFile(key string) (FileStat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	object, ok := s.objects[key]
+	if !ok {
+		return FileStat{}, fmt.Errorf("failed to get details of file with key name %s: %w", key, ErrNotFound)
+	}
+	checksum := sha256.Sum256(object.Data)
+	return FileStat{
+		Key:            key,
+		Size:           uint64(len(object.Data)),
+		ETag:           memoryETag(object.Data),
+		ChecksumSHA256: base64.StdEncoding.EncodeToString(checksum[:]),
+		Metadata:       maps.Clone(object.Metadata),
+		LastModified:   object.LastModified,
+		Finalized:      object.Finalized,
+	}, nil
+}
+
+func (s *MemoryStorage) GetMetadata(key string) (metadata.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	object, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("failed to get metadata of file with key name %s: %w", key, ErrNotFound)
+	}
+	return maps.Clone(object.Metadata), nil
+}
+
+func (s *MemoryStorage) FinalizeFile(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[key]
+	if !ok {
+		return fmt.Errorf("failed to finalize file with key name %s: %w", key, ErrNotFound)
+	}
+	object.Finalized = true
+	s.objects[key] = object
+	return nil
+}
+
+func (s *MemoryStorage) DeleteFile(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *MemoryStorage) CopyFile(srcKey, dstKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[srcKey]
+	if !ok {
+		return fmt.Errorf("failed to copy file with key name %s: %w", srcKey, ErrNotFound)
+	}
+	object.Metadata = maps.Clone(object.Metadata)
+	object.LastModified = time.Now()
+	s.objects[dstKey] = object
+	return nil
+}
+
+func (s *MemoryStorage) MoveFile(srcKey, dstKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[srcKey]
+	if !ok {
+		return fmt.Errorf("failed to move file with key name %s: %w", srcKey, ErrNotFound)
+	}
+	delete(s.objects, srcKey)
+	s.objects[dstKey] = object
+	return nil
+}
+
+func (s *MemoryStorage) ListFiles(prefix, cursor string, limit int32) (FileList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []string
+	for key := range s.objects {
+		// The cursor is the last key of the previous page
+		if strings.HasPrefix(key, prefix) && key > cursor {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	var list FileList
+	if len(keys) > int(limit) {
+		keys = keys[:limit]
+		list.NextCursor = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		object := s.objects[key]
+		list.Files = append(list.Files, FileStat{
+			Key:          key,
+			Size:         uint64(len(object.Data)),
+			ETag:         memoryETag(object.Data),
+			Metadata:     maps.Clone(object.Metadata),
+			LastModified: object.LastModified,
+			Finalized:    object.Finalized,
+		})
+	}
+	return list, nil
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
+)
+
+func TestMemoryStorageUpload(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    bool
+		maxSize uint64
+		expire  time.Duration
+		wantErr bool
+	}{
+		{"PUT link", false, 0, time.Minute, false},
+		{"POST form", true, 0, time.Minute, false},
+		{"smaller than maximum size", true, 7, time.Minute, false},
+		{"larger than maximum size", true, 6, time.Minute, true},
+		{"expired link", false, 0, -time.Second, true},
+		{"expired form", true, 0, -time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStorage()
+			fileInfo := UploadFileInfo{FileName: "a", FileExtension: "pdf", MaxSize: tt.maxSize,
+				Metadata: metadata.Metadata{"RealName": "report"}}
+			var link url.URL
+			var err error
+			if tt.form {
+				var form UploadForm
+				form, err = s.UploadFileForm(fileInfo, tt.expire)
+				link = form.URL
+			} else {
+				var upload UploadLink
+				upload, err = s.UploadFile(fileInfo, tt.expire)
+				link = upload.URL
+			}
+			if err != nil {
+				t.Fatalf("creating the upload link error = %v", err)
+			}
+			_, err = s.Upload(link, []byte("content"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			object, ok := s.Object("a.pdf")
+			if ok == tt.wantErr {
+				t.Fatalf("file is stored = %v, want %v", ok, !tt.wantErr)
+			}
+			if ok && (string(object.Data) != "content" || object.Metadata["RealName"] != "report") {
+				t.Errorf("stored file = %q with metadata %v", object.Data, object.Metadata)
+			}
+		})
+	}
+}
+
+func TestMemoryStorageDownload(t *testing.T) {
+	s := NewMemoryStorage()
+	s.PutObject("a.pdf", []byte("content"), nil)
+	s.PutObject("b.pdf", []byte("content"), nil)
+	download, _ := s.DownloadFile(DownloadFileInfo{FileName: "a.pdf"}, time.Minute)
+	expired, _ := s.DownloadFile(DownloadFileInfo{FileName: "a.pdf"}, -time.Second)
+	deleted, _ := s.DownloadFile(DownloadFileInfo{FileName: "b.pdf"}, time.Minute)
+	s.DeleteFile("b.pdf")
+	upload, _ := s.UploadFile(UploadFileInfo{FileName: "a", FileExtension: "pdf"}, time.Minute)
+
+	tests := []struct {
+		name    string
+		link    url.URL
+		wantErr bool
+	}{
+		{"download link", download, false},
+		{"expired link", expired, true},
+		{"deleted file", deleted, true},
+		{"upload link", upload.URL, true},
+		{"unknown link", url.URL{Scheme: "memory", Host: "storage", Path: "/a.pdf", RawQuery: "id=100"}, true},
+		{"link of another storage", url.URL{Scheme: "https", Host: "storage", Path: "/a.pdf", RawQuery: "id=0"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := s.Download(tt.link)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Download() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(data) != "content" {
+				t.Errorf("Download() = %q, want %q", data, "content")
+			}
+		})
+	}
+
+	links := s.Links()
+	if len(links) != 4 || links[0].Method != "GET" || links[0].Key != "a.pdf" || links[3].Method != "PUT" {
+		t.Errorf("Links() = %+v, want the created links in order", links)
+	}
+	if _, err := s.DownloadFile(DownloadFileInfo{FileName: "missing.pdf"}, time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DownloadFile() of a missing file error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStorageMultipart(t *testing.T) {
+	s := NewMemoryStorage()
+	upload, err := s.CreateMultipartUpload(UploadFileInfo{FileName: "a", FileExtension: "pdf",
+		Metadata: metadata.Metadata{"RealName": "report"}})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+	if _, err := s.UploadParts(MultipartUpload{Key: "b.pdf", UploadID: upload.UploadID}, []int32{1}, time.Minute); err == nil {
+		t.Error("UploadParts() with another key error = nil")
+	}
+	if md, err := s.MultipartMetadata(upload); err != nil || md["RealName"] != "report" {
+		t.Errorf("MultipartMetadata() = %v, %v, want metadata of the upload", md, err)
+	}
+
+	links, err := s.UploadParts(upload, []int32{1, 2}, time.Minute)
+	if err != nil {
+		t.Fatalf("UploadParts() error = %v", err)
+	}
+	var parts []CompletedPart
+	for i, data := range []string{"first-", "second"} {
+		etag, err := s.Upload(links[i], []byte(data))
+		if err != nil {
+			t.Fatalf("Upload() of part %d error = %v", i+1, err)
+		}
+		parts = append(parts, CompletedPart{PartNumber: int32(i + 1), ETag: fmt.Sprintf("%q", etag)})
+	}
+	if _, ok := s.Object("a.pdf"); ok {
+		t.Fatal("file is stored before completing the upload")
+	}
+
+	wrongETag := []CompletedPart{parts[0], {PartNumber: 2, ETag: "wrong"}}
+	if err := s.CompleteMultipartUpload(upload, wrongETag); err == nil {
+		t.Error("CompleteMultipartUpload() with a wrong ETag error = nil")
+	}
+	missingPart := []CompletedPart{parts[0], {PartNumber: 3, ETag: parts[1].ETag}}
+	if err := s.CompleteMultipartUpload(upload, missingPart); err == nil {
+		t.Error("CompleteMultipartUpload() with a missing part error = nil")
+	}
+	// Parts are joined in order of their numbers.
+	if err := s.CompleteMultipartUpload(upload, []CompletedPart{parts[1], parts[0]}); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	object, ok := s.Object("a.pdf")
+	if !ok || string(object.Data) != "first-second" || object.Metadata["RealName"] != "report" {
+		t.Errorf("completed file = %q with metadata %v", object.Data, object.Metadata)
+	}
+	if err := s.AbortMultipartUpload(upload); err == nil {
+		t.Error("AbortMultipartUpload() of a completed upload error = nil")
+	}
+	if _, err := s.MultipartMetadata(upload); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MultipartMetadata() of a completed upload error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStorageFinalizeAndDelete(t *testing.T) {
+	s := NewMemoryStorage()
+	if _, err := s.StatFile("a.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("StatFile() of a missing file error = %v, want ErrNotFound", err)
+	}
+	s.PutObject("a.pdf", []byte("content"), metadata.Metadata{"RealName": "report"})
+	if err := s.FinalizeFile("a.pdf"); err != nil {
+		t.Fatalf("FinalizeFile() error = %v", err)
+	}
+	stat, err := s.StatFile("a.pdf")
+	if err != nil || stat.Size != 7 || !stat.Finalized || stat.Metadata["RealName"] != "report" {
+		t.Errorf("StatFile() = %+v, %v, want a finalized file with its metadata", stat, err)
+	}
+	if err := s.DeleteFile("a.pdf"); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if err := s.FinalizeFile("a.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FinalizeFile() of a deleted file error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStorageTransfer(t *testing.T) {
+	s := NewMemoryStorage()
+	s.PutObject("a.pdf", []byte("content"), metadata.Metadata{"RealName": "report"})
+	if err := s.CopyFile("a.pdf", "b.pdf"); err != nil {
+		t.Fatalf("CopyFile() error = %v", err)
+	}
+	if err := s.MoveFile("a.pdf", "c.pdf"); err != nil {
+		t.Fatalf("MoveFile() error = %v", err)
+	}
+	if _, ok := s.Object("a.pdf"); ok {
+		t.Error("moved file is stored")
+	}
+	for _, key := range []string{"b.pdf", "c.pdf"} {
+		object, ok := s.Object(key)
+		if !ok || string(object.Data) != "content" || object.Metadata["RealName"] != "report" {
+			t.Errorf("Object(%q) = %+v, %v, want the source with its metadata", key, object, ok)
+		}
+	}
+	if err := s.CopyFile("a.pdf", "d.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CopyFile() of a missing file error = %v, want ErrNotFound", err)
+	}
+	if err := s.MoveFile("a.pdf", "d.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MoveFile() of a missing file error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStorageListFiles(t *testing.T) {
+	s := NewMemoryStorage()
+	for _, key := range []string{"u1/c.pdf", "u1/a.pdf", "u1/b.pdf", "u2/a.pdf"} {
+		s.PutObject(key, []byte(key), nil)
+	}
+	tests := []struct {
+		name       string
+		prefix     string
+		cursor     string
+		limit      int32
+		wantKeys   []string
+		wantCursor string
+	}{
+		{"first page", "u1/", "", 2, []string{"u1/a.pdf", "u1/b.pdf"}, "u1/b.pdf"},
+		{"last page", "u1/", "u1/b.pdf", 2, []string{"u1/c.pdf"}, ""},
+		{"exact page", "u1/", "", 3, []string{"u1/a.pdf", "u1/b.pdf", "u1/c.pdf"}, ""},
+		{"another prefix", "u2/", "", 10, []string{"u2/a.pdf"}, ""},
+		{"no files", "u3/", "", 10, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := s.ListFiles(tt.prefix, tt.cursor, tt.limit)
+			if err != nil {
+				t.Fatalf("ListFiles() error = %v", err)
+			}
+			var keys []string
+			for _, f := range list.Files {
+				keys = append(keys, f.Key)
+				if f.Size != uint64(len(f.Key)) {
+					t.Errorf("size of %s = %d, want %d", f.Key, f.Size, len(f.Key))
+				}
+			}
+			if !slices.Equal(keys, tt.wantKeys) || list.NextCursor != tt.wantCursor {
+				t.Errorf("ListFiles() = %v, %q, want %v, %q", keys, list.NextCursor, tt.wantKeys, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestMemoryStorageNotFound(t *testing.T) {
+	s := NewMemoryStorage()
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"StatFile", func() error { _, err := s.StatFile("a.pdf"); return err }},
+		{"GetMetadata", func() error { _, err := s.GetMetadata("a.pdf"); return err }},
+		{"FinalizeFile", func() error { return s.FinalizeFile("a.pdf") }},
+		{"CopyFile", func() error { return s.CopyFile("a.pdf", "b.pdf") }},
+		{"MoveFile", func() error { return s.MoveFile("a.pdf", "b.pdf") }},
+		{"DownloadFile", func() error { _, err := s.DownloadFile(DownloadFileInfo{FileName: "a.pdf"}, time.Minute); return err }},
+		{"MultipartMetadata", func() error {
+			_, err := s.MultipartMetadata(MultipartUpload{Key: "a.pdf", UploadID: "1"})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Clients get 404 for the missing files
+			err := tt.call()
+			if !errors.Is(err, ErrNotFound) || e.CodeOf(err) != e.NotFound {
+				t.Errorf("%s() error = %v, want ErrNotFound", tt.name, err)
+			}
+		})
+	}
+}
@@ -2,14 +2,21 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
 	l "github.com/q-sharafian/file-transfer/pkg/logger"
 )
 
@@ -47,31 +54,86 @@ func NewS3Storage(logger l.Logger) Storage {
 	}
 }
 
-func (s *S3Storage) UploadFile(fileInfo UploadFileInfo, expireTime time.Duration) (url.URL, error) {
-	presignPutObject, err := s.presignS3.PresignPutObject(context.TODO(), &s3.PutObjectInput{
+func (s *S3Storage) UploadFile(fileInfo UploadFileInfo, expireTime time.Duration) (UploadLink, error) {
+	input := &s3.PutObjectInput{
 		Bucket:   &s.bucketName,
 		Key:      aws.String(fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String())),
 		Metadata: fileInfo.Metadata,
-	}, func(opts *s3.PresignOptions) {
+	}
+	if fileInfo.ChecksumSHA256 != "" {
+		input.ChecksumSHA256 = &fileInfo.ChecksumSHA256
+	}
+	presignPutObject, err := s.presignS3.PresignPutObject(context.TODO(), input, func(opts *s3.PresignOptions) {
 		opts.Expires = expireTime
 	})
 
 	if err != nil {
-		return url.URL{}, fmt.Errorf("failed to create presign uploading link with key name %s: %s",
+		return UploadLink{}, fmt.Errorf("failed to create presign uploading link with key name %s: %s",
 			fileInfo.FileName, err.Error())
 	}
+	// Host header is set by HTTP clients themselves
+	headers := make(map[string]string)
+	for k := range presignPutObject.SignedHeader {
+		if !strings.EqualFold(k, "Host") {
+			headers[k] = presignPutObject.SignedHeader.Get(k)
+		}
+	}
 	if newURL, err2 := url.Parse(presignPutObject.URL); err2 == nil {
-		return *newURL, nil
+		return UploadLink{*newURL, headers}, nil
 	} else {
-		return url.URL{}, fmt.Errorf("failed to create presign uploading link with key name %s: parsing URL error: %s",
+		return UploadLink{}, fmt.Errorf("failed to create presign uploading link with key name %s: parsing URL error: %s",
 			fileInfo.FileName, err2.Error())
 	}
 }
 
+func (s *S3Storage) UploadFileForm(fileInfo UploadFileInfo, expireTime time.Duration) (UploadForm, error) {
+	// Unlike PUT requests, metadata of POST requests aren't added to the form
+	// automatically, so they must be added to both the fields and the policy.
+	fields := make(map[string]string, len(fileInfo.Metadata))
+	var conditions []interface{}
+	for k, v := range fileInfo.Metadata {
+		field := "x-amz-meta-" + strings.ToLower(k)
+		fields[field] = v
+		conditions = append(conditions, map[string]string{field: v})
+	}
+	if fileInfo.MaxSize > 0 {
+		conditions = append(conditions, []interface{}{"content-length-range", 0, fileInfo.MaxSize})
+	}
+
+	key := fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String())
+	presignPostObject, err := s.presignS3.PresignPostObject(context.TODO(), &s3.PutObjectInput{
+		Bucket: &s.bucketName,
+		Key:    aws.String(key),
+	}, func(opts *s3.PresignPostOptions) {
+		opts.Expires = expireTime
+		opts.Conditions = conditions
+	})
+	if err != nil {
+		return UploadForm{}, fmt.Errorf("failed to create presign uploading form with key name %s: %s",
+			fileInfo.FileName, err.Error())
+	}
+	newURL, err := url.Parse(presignPostObject.URL)
+	if err != nil {
+		return UploadForm{}, fmt.Errorf("failed to create presign uploading form with key name %s: parsing URL error: %s",
+			fileInfo.FileName, err.Error())
+	}
+	for k, v := range presignPostObject.Values {
+		fields[k] = v
+	}
+	return UploadForm{*newURL, fields}, nil
+}
+
 func (s *S3Storage) DownloadFile(fileInfo DownloadFileInfo, expireTime time.Duration) (url.URL, error) {
+	// Presigning doesn't check the file, so links of missing files would be valid
+	md, err := s.GetMetadata(fileInfo.FileName)
+	if err != nil {
+		return url.URL{}, fmt.Errorf("failed to create presign downloading link: %w", err)
+	}
 	presignGetObject, err := s.presignS3.PresignGetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: &s.bucketName,
-		Key:    &fileInfo.FileName,
+		Bucket:                     &s.bucketName,
+		Key:                        &fileInfo.FileName,
+		ResponseContentDisposition: aws.String(contentDisposition(fileInfo, md)),
+		ResponseContentType:        aws.String(contentType(fileInfo.FileName)),
 	}, func(opts *s3.PresignOptions) {
 		opts.Expires = expireTime
 	})
@@ -86,3 +148,360 @@ func (s *S3Storage) DownloadFile(fileInfo DownloadFileInfo, expireTime time.Dura
 			fileInfo.FileName, err2.Error())
 	}
 }
+
+// Prefix of the empty objects that keep metadata of the multipart uploads. S3 doesn't
+// return metadata of the uploads until they're completed.
+const s3MultipartPrefix = ".multipart/"
+
+func (s *S3Storage) CreateMultipartUpload(fileInfo UploadFileInfo) (MultipartUpload, error) {
+	key := fmt.Sprintf("%s.%s", fileInfo.FileName, fileInfo.FileExtension.String())
+	output, err := s.s3.CreateMultipartUpload(context.TODO(), &s3.CreateMultipartUploadInput{
+		Bucket:   &s.bucketName,
+		Key:      &key,
+		Metadata: fileInfo.Metadata,
+	})
+	if err != nil {
+		return MultipartUpload{}, fmt.Errorf("failed to create multipart upload with key name %s: %s", key, err.Error())
+	}
+	upload := MultipartUpload{Key: key, UploadID: aws.ToString(output.UploadId)}
+	_, err = s.s3.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:   &s.bucketName,
+		Key:      aws.String(multipartMarkerKey(upload)),
+		Metadata: fileInfo.Metadata,
+	})
+	if err != nil {
+		if err2 := s.AbortMultipartUpload(upload); err2 != nil {
+			s.logger.Warnf("Failed to abort multipart upload %s: %s", upload.UploadID, err2.Error())
+		}
+		return MultipartUpload{}, fmt.Errorf("failed to create multipart upload with key name %s: storing its metadata error: %s",
+			key, err.Error())
+	}
+	return upload, nil
+}
+
+func (s *S3Storage) MultipartMetadata(upload MultipartUpload) (metadata.Metadata, error) {
+	output, err := s.s3.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: &s.bucketName,
+		Key:    aws.String(multipartMarkerKey(upload)),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, fmt.Errorf("failed to get metadata of multipart upload %s: %w", upload.UploadID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get metadata of multipart upload %s: %s", upload.UploadID, err.Error())
+	}
+	return output.Metadata, nil
+}
+
+// Key of the object that keeps metadata of the multipart upload
+func multipartMarkerKey(upload MultipartUpload) string {
+	return s3MultipartPrefix + upload.UploadID + "/" + upload.Key
+}
+
+// Remove the object that keeps metadata of the multipart upload after it's completed
+// or aborted. Failures are just logged, because the upload itself is done.
+func (s *S3Storage) removeMultipartMarker(upload MultipartUpload) {
+	_, err := s.s3.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: &s.bucketName,
+		Key:    aws.String(multipartMarkerKey(upload)),
+	})
+	if err != nil {
+		s.logger.Warnf("Failed to remove metadata of multipart upload %s: %s", upload.UploadID, err.Error())
+	}
+}
+
+func (s *S3Storage) UploadParts(upload MultipartUpload, partNumbers []int32, expireTime time.Duration) ([]url.URL, error) {
+	urls := make([]url.URL, 0, len(partNumbers))
+	for _, partNumber := range partNumbers {
+		presignUploadPart, err := s.presignS3.PresignUploadPart(context.TODO(), &s3.UploadPartInput{
+			Bucket:     &s.bucketName,
+			Key:        &upload.Key,
+			UploadId:   &upload.UploadID,
+			PartNumber: aws.Int32(partNumber),
+		}, func(opts *s3.PresignOptions) {
+			opts.Expires = expireTime
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create presign link of part %d with key name %s: %s",
+				partNumber, upload.Key, err.Error())
+		}
+		newURL, err := url.Parse(presignUploadPart.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create presign link of part %d with key name %s: parsing URL error: %s",
+				partNumber, upload.Key, err.Error())
+		}
+		urls = append(urls, *newURL)
+	}
+	return urls, nil
+}
+
+func (s *S3Storage) CompleteMultipartUpload(upload MultipartUpload, parts []CompletedPart) error {
+	completedParts := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completedParts = append(completedParts, types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+	slices.SortFunc(completedParts, func(a, b types.CompletedPart) int {
+		return int(*a.PartNumber - *b.PartNumber)
+	})
+	_, err := s.s3.CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:          &s.bucketName,
+		Key:             &upload.Key,
+		UploadId:        &upload.UploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload with key name %s: %s", upload.Key, err.Error())
+	}
+	s.removeMultipartMarker(upload)
+	return nil
+}
+
+func (s *S3Storage) AbortMultipartUpload(upload MultipartUpload) error {
+	_, err := s.s3.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+		Bucket:   &s.bucketName,
+		Key:      &upload.Key,
+		UploadId: &upload.UploadID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload with key name %s: %s", upload.Key, err.Error())
+	}
+	s.removeMultipartMarker(upload)
+	return nil
+}
+
+// Maximum number of parts of a multipart upload
+const maxS3Parts = 10000
+
+// Name of the object tag that marks finalized files
+const s3FinalizedTag = "finalized"
+
+func (s *S3Storage) StatFile(key string) (FileStat, error) {
+	output, err := s.s3.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket:       &s.bucketName,
+		Key:          &key,
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return FileStat{}, fmt.Errorf("failed to get details of file with key name %s: %w", key, ErrNotFound)
+		}
+		return FileStat{}, fmt.Errorf("failed to get details of file with key name %s: %s", key, err.Error())
+	}
+	stat := FileStat{
+		Key:          key,
+		Size:         uint64(aws.ToInt64(output.ContentLength)),
+		Metadata:     output.Metadata,
+		LastModified: aws.ToTime(output.LastModified),
+	}
+	// ETag and checksum of multipart uploads are not hashes of the whole file and end with "-<parts count>"
+	if etag := strings.Trim(aws.ToString(output.ETag), "\""); !strings.Contains(etag, "-") {
+		stat.ETag = etag
+	}
+	if checksum := aws.ToString(output.ChecksumSHA256); !strings.Contains(checksum, "-") {
+		stat.ChecksumSHA256 = checksum
+	}
+
+	tagging, err := s.s3.GetObjectTagging(context.TODO(), &s3.GetObjectTaggingInput{
+		Bucket: &s.bucketName,
+		Key:    &key,
+	})
+	if err != nil {
+		return FileStat{}, fmt.Errorf("failed to get tags of file with key name %s: %s", key, err.Error())
+	}
+	for _, tag := range tagging.TagSet {
+		if aws.ToString(tag.Key) == s3FinalizedTag && aws.ToString(tag.Value) == "true" {
+			stat.Finalized = true
+		}
+	}
+	return stat, nil
+}
+
+func (s *S3Storage) GetMetadata(key string) (metadata.Metadata, error) {
+	output, err := s.s3.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: &s.bucketName,
+		Key:    &key,
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, fmt.Errorf("failed to get metadata of file with key name %s: %w", key, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get metadata of file with key name %s: %s", key, err.Error())
+	}
+	return output.Metadata, nil
+}
+
+// Files are marked by a tag, because changing metadata needs copying the file that
+// isn't possible for files larger than 5GB in one request.
+func (s *S3Storage) FinalizeFile(key string) error {
+	_, err := s.s3.PutObjectTagging(context.TODO(), &s3.PutObjectTaggingInput{
+		Bucket: &s.bucketName,
+		Key:    &key,
+		Tagging: &types.Tagging{TagSet: []types.Tag{
+			{Key: aws.String(s3FinalizedTag), Value: aws.String("true")},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to finalize file with key name %s: %s", key, err.Error())
+	}
+	return nil
+}
+
+func (s *S3Storage) DeleteFile(key string) error {
+	_, err := s.s3.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: &s.bucketName,
+		Key:    &key,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file with key name %s: %s", key, err.Error())
+	}
+	return nil
+}
+
+// Maximum size of a file that CopyObject could copy. Larger files are copied in parts.
+const s3MaxCopySize = 5 << 30
+
+func (s *S3Storage) CopyFile(srcKey, dstKey string) error {
+	stat, err := s.StatFile(srcKey)
+	if err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %w", srcKey, err)
+	}
+	if stat.Size > s3MaxCopySize {
+		return s.copyFileInParts(stat, dstKey)
+	}
+	// Metadata and tags are copied too
+	_, err = s.s3.CopyObject(context.TODO(), &s3.CopyObjectInput{
+		Bucket:     &s.bucketName,
+		Key:        &dstKey,
+		CopySource: aws.String(s.copySource(srcKey)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %s", srcKey, err.Error())
+	}
+	return nil
+}
+
+// Copy the file by a multipart upload that each part of it is copied from the source
+func (s *S3Storage) copyFileInParts(src FileStat, dstKey string) error {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:   &s.bucketName,
+		Key:      &dstKey,
+		Metadata: src.Metadata,
+	}
+	if src.Finalized {
+		input.Tagging = aws.String(s3FinalizedTag + "=true")
+	}
+	output, err := s.s3.CreateMultipartUpload(context.TODO(), input)
+	if err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %s", src.Key, err.Error())
+	}
+	upload := MultipartUpload{Key: dstKey, UploadID: aws.ToString(output.UploadId)}
+
+	partSize := max(uint64(512<<20), (src.Size+maxS3Parts-1)/maxS3Parts)
+	var parts []CompletedPart
+	for start := uint64(0); start < src.Size; start += partSize {
+		end := min(start+partSize, src.Size) - 1
+		partNumber := int32(len(parts) + 1)
+		partOutput, err := s.s3.UploadPartCopy(context.TODO(), &s3.UploadPartCopyInput{
+			Bucket:          &s.bucketName,
+			Key:             &dstKey,
+			UploadId:        &upload.UploadID,
+			PartNumber:      aws.Int32(partNumber),
+			CopySource:      aws.String(s.copySource(src.Key)),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+		})
+		if err != nil {
+			if err2 := s.AbortMultipartUpload(upload); err2 != nil {
+				s.logger.Warnf("Failed to abort copying file with key name %s: %s", src.Key, err2.Error())
+			}
+			return fmt.Errorf("failed to copy part %d of file with key name %s: %s", partNumber, src.Key, err.Error())
+		}
+		parts = append(parts, CompletedPart{PartNumber: partNumber, ETag: aws.ToString(partOutput.CopyPartResult.ETag)})
+	}
+	if err := s.CompleteMultipartUpload(upload, parts); err != nil {
+		return fmt.Errorf("failed to copy file with key name %s: %s", src.Key, err.Error())
+	}
+	return nil
+}
+
+func (s *S3Storage) copySource(key string) string {
+	return s.bucketName + "/" + url.PathEscape(key)
+}
+
+func (s *S3Storage) MoveFile(srcKey, dstKey string) error {
+	// S3 can't rename files
+	if err := s.CopyFile(srcKey, dstKey); err != nil {
+		return fmt.Errorf("failed to move file with key name %s: %w", srcKey, err)
+	}
+	if err := s.DeleteFile(srcKey); err != nil {
+		return fmt.Errorf("failed to move file with key name %s: %s", srcKey, err.Error())
+	}
+	return nil
+}
+
+// Maximum number of HeadObject requests that are sent at the same time to list files
+const s3ListConcurrency = 8
+
+func (s *S3Storage) ListFiles(prefix, cursor string, limit int32) (FileList, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  &s.bucketName,
+		Prefix:  &prefix,
+		MaxKeys: aws.Int32(limit),
+	}
+	if cursor != "" {
+		input.ContinuationToken = &cursor
+	}
+	output, err := s.s3.ListObjectsV2(context.TODO(), input)
+	if err != nil {
+		return FileList{}, fmt.Errorf("failed to list files with prefix %s: %s", prefix, err.Error())
+	}
+
+	files := make([]FileStat, len(output.Contents))
+	errs := make([]error, len(output.Contents))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s3ListConcurrency)
+	for i, object := range output.Contents {
+		files[i] = FileStat{
+			Key:          aws.ToString(object.Key),
+			Size:         uint64(aws.ToInt64(object.Size)),
+			LastModified: aws.ToTime(object.LastModified),
+		}
+		if etag := strings.Trim(aws.ToString(object.ETag), "\""); !strings.Contains(etag, "-") {
+			files[i].ETag = etag
+		}
+		// Listing doesn't return metadata of the files
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			files[i].Metadata, errs[i] = s.GetMetadata(files[i].Key)
+		}()
+	}
+	wg.Wait()
+	list := FileList{Files: make([]FileStat, 0, len(files))}
+	for i := range files {
+		// The file is deleted after listing it
+		if errors.Is(errs[i], ErrNotFound) {
+			continue
+		}
+		if errs[i] != nil {
+			return FileList{}, fmt.Errorf("failed to list files with prefix %s: %s", prefix, errs[i].Error())
+		}
+		list.Files = append(list.Files, files[i])
+	}
+	if aws.ToBool(output.IsTruncated) {
+		list.NextCursor = aws.ToString(output.NextContinuationToken)
+	}
+	return list, nil
+}
+
+func isS3NotFound(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey"
+	}
+	return false
+}
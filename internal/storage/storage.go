@@ -1,12 +1,16 @@
 package storage
 
 import (
+	"mime"
 	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/q-sharafian/file-transfer/internal/common/file"
 	"github.com/q-sharafian/file-transfer/internal/common/metadata"
 	"github.com/q-sharafian/file-transfer/internal/common/token"
+	e "github.com/q-sharafian/file-transfer/pkg/error"
 )
 
 type DownloadFileInfo struct {
@@ -16,6 +20,11 @@ type DownloadFileInfo struct {
 	DownloadedBy token.Token
 	// Time the file is downloaded
 	DownloadedAt time.Time
+	// Name of the file with its extension that browsers save the file with it. If it's
+	// empty, the real name in metadata of the file or its name in the storage is used.
+	RealName string
+	// If it's true, browsers display the file instead of saving it.
+	Inline bool
 }
 type UploadFileInfo struct {
 	// Filename without extension. The name of the file in the storage will be renamed to this name
@@ -26,6 +35,115 @@ type UploadFileInfo struct {
 	UploadedBy token.Token
 	// Time the file is uploaded
 	UploadedAt time.Time
+	// Maximum size of the file in bytes. Zero means there's no limit.
+	MaxSize uint64
+	// SHA-256 checksum of the file in base64. If it's set, the storage rejects the file
+	// with another content. (Just for UploadFile)
+	ChecksumSHA256 string
+}
+
+// A link to upload a file by PUT method.
+type UploadLink struct {
+	URL url.URL
+	// Headers that are signed in the link. The upload request must contain all of them
+	// with the same values. (e.g. metadata of the file)
+	Headers map[string]string
+}
+
+// An HTML form that a file is uploaded with it. The form must be sent to the URL by
+// a multipart/form-data POST request that contains all fields and then the file in
+// a field named "file". (The file must be the last field)
+type UploadForm struct {
+	URL    url.URL
+	Fields map[string]string
+}
+
+// It's returned (wrapped) if the file doesn't exist in the storage. Its code is
+// e.NotFound.
+var ErrNotFound error = e.NewErrorP("file not found", e.NotFound)
+
+// Details of a stored file
+type FileStat struct {
+	// The name of the file in the storage, including its extension.
+	Key string
+	// Size of the file in bytes
+	Size uint64
+	// MD5 hash of the file in hex. It's empty if the storage doesn't know it. (e.g. S3
+	// files that are uploaded in multiple parts)
+	ETag string
+	// SHA-256 checksum of the file in base64. It's empty if the storage doesn't know it.
+	ChecksumSHA256 string
+	metadata.Metadata
+	LastModified time.Time
+	// Whether the file is checked after uploading and marked as finalized
+	Finalized bool
+}
+
+// A file that is being uploaded in multiple parts
+type MultipartUpload struct {
+	// The name of the file in the storage, including its extension.
+	Key string
+	// ID of the upload that the storage creates
+	UploadID string
+}
+
+// A part of a multipart upload that is uploaded
+type CompletedPart struct {
+	// Number of the part. Part numbers begin from 1
+	PartNumber int32
+	// ETag header the storage returned after uploading the part
+	ETag string
+}
+
+// Return Content-Disposition header the file must be downloaded with it. Files that
+// browsers run scripts in them are always saved, even if Inline is set.
+func contentDisposition(fileInfo DownloadFileInfo, md metadata.Metadata) string {
+	disposition := "attachment"
+	if fileInfo.Inline && !isActiveContent(mime.TypeByExtension(path.Ext(fileInfo.FileName))) {
+		disposition = "inline"
+	}
+	name := fileInfo.RealName
+	if name == "" {
+		name = md.RealFileName(file.ExtensionOf(fileInfo.FileName))
+	}
+	if name == "" {
+		name = path.Base(fileInfo.FileName)
+	}
+	return mime.FormatMediaType(disposition, map[string]string{"filename": name})
+}
+
+// Return Content-Type header of the file based on its extension. Files that browsers
+// run scripts in them (e.g. .html and .svg) are served as binary files, because users
+// could upload them and they may be served on the origin of the app. (XSS)
+func contentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" && !isActiveContent(t) {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Return whether browsers could run scripts in the content type.
+func isActiveContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType != ""
+	}
+	switch mediaType {
+	case "text/html", "text/xml", "application/xml", "text/xsl", "text/javascript", "application/javascript",
+		"application/x-javascript", "application/ecmascript", "text/ecmascript":
+		return true
+	}
+	// e.g. image/svg+xml and application/xhtml+xml
+	return strings.HasSuffix(mediaType, "+xml")
+}
+
+// A page of the files that their keys begin with a prefix
+type FileList struct {
+	// Details of the files in order of their keys. Their ETag, checksum and Finalized may
+	// not be set.
+	Files []FileStat
+	// It's passed to ListFiles to read the next page. It's empty if it's the last page.
+	NextCursor string
 }
 
 // Each implementation must create a one-time link to download/upload file with
@@ -33,7 +151,45 @@ type UploadFileInfo struct {
 // Also manage file metadata. (e.g. removing sensitive metadata during downloading)
 type Storage interface {
 	// Create a link to upload one file and expire the link after the expiration time
-	UploadFile(fileInfo UploadFileInfo, expireTime time.Duration) (url.URL, error)
+	UploadFile(fileInfo UploadFileInfo, expireTime time.Duration) (UploadLink, error)
+	// Create a form to upload one file and expire the form after the expiration time.
+	// Unlike UploadFile, the storage itself rejects the file if it's larger than MaxSize.
+	UploadFileForm(fileInfo UploadFileInfo, expireTime time.Duration) (UploadForm, error)
 	// Create a link to download one file and expire the link after the expiration time.
+	// The file is checked to exist before creating the link. If it doesn't exist, the
+	// returned error wraps ErrNotFound.
 	DownloadFile(fileInfo DownloadFileInfo, expireTime time.Duration) (url.URL, error)
+
+	// Start uploading one file in multiple parts. Parts could be uploaded in parallel and
+	// the file is stored only after completing the upload.
+	CreateMultipartUpload(fileInfo UploadFileInfo) (MultipartUpload, error)
+	// Create a link to upload each part of the multipart upload and expire the links after
+	// the expiration time. Links are in the same order as the part numbers.
+	UploadParts(upload MultipartUpload, partNumbers []int32, expireTime time.Duration) ([]url.URL, error)
+	// Store the file by joining its uploaded parts in order of their part numbers.
+	CompleteMultipartUpload(upload MultipartUpload, parts []CompletedPart) error
+	// Cancel the multipart upload and remove its uploaded parts.
+	AbortMultipartUpload(upload MultipartUpload) error
+	// Return metadata of the file of a multipart upload that isn't completed or aborted
+	// yet. If the upload doesn't exist, the returned error wraps ErrNotFound.
+	MultipartMetadata(upload MultipartUpload) (metadata.Metadata, error)
+
+	// Return details of the file without downloading it. If the file doesn't exist,
+	// the returned error wraps ErrNotFound.
+	StatFile(key string) (FileStat, error)
+	// Return metadata of the file without downloading it. If the file doesn't exist,
+	// the returned error wraps ErrNotFound.
+	GetMetadata(key string) (metadata.Metadata, error)
+	// Mark the file as finalized.
+	FinalizeFile(key string) error
+	// Remove the file and its metadata.
+	DeleteFile(key string) error
+	// Copy the file with its metadata to another key without downloading it. If the
+	// source file doesn't exist, the returned error wraps ErrNotFound.
+	CopyFile(srcKey, dstKey string) error
+	// Same as CopyFile, but the source file is removed.
+	MoveFile(srcKey, dstKey string) error
+	// Return at most limit files that their keys begin with the prefix, in order of their
+	// keys. To read the first page, cursor must be empty.
+	ListFiles(prefix, cursor string, limit int32) (FileList, error)
 }
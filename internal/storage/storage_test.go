@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/q-sharafian/file-transfer/internal/common/metadata"
+)
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"a.png", "image/png"},
+		{"a.pdf", "application/pdf"},
+		{"dir/a.jpg", "image/jpeg"},
+		{"a", "application/octet-stream"},
+		{"a.unknown-ext", "application/octet-stream"},
+		// Browsers run scripts of these files.
+		{"a.html", "application/octet-stream"},
+		{"a.htm", "application/octet-stream"},
+		{"a.svg", "application/octet-stream"},
+		{"a.xml", "application/octet-stream"},
+		{"a.xhtml", "application/octet-stream"},
+		{"a.js", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := contentType(tt.key); got != tt.want {
+				t.Errorf("contentType(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	md := metadata.Metadata{}
+	md.PrepareUploadMetadata("alice", "alice", "report 2024")
+
+	tests := []struct {
+		name     string
+		fileInfo DownloadFileInfo
+		md       metadata.Metadata
+		want     string
+	}{
+		{"name in the storage", DownloadFileInfo{FileName: "dir/a.pdf"}, nil, `attachment; filename=a.pdf`},
+		{"real name", DownloadFileInfo{FileName: "dir/a.pdf"}, md, `attachment; filename="report 2024.pdf"`},
+		{"requested name", DownloadFileInfo{FileName: "dir/a.pdf", RealName: "b.pdf"}, md, `attachment; filename=b.pdf`},
+		{"non-ASCII name", DownloadFileInfo{FileName: "a.pdf", RealName: "گزارش.pdf"}, nil,
+			`attachment; filename*=utf-8''%DA%AF%D8%B2%D8%A7%D8%B1%D8%B4.pdf`},
+		{"inline", DownloadFileInfo{FileName: "a.png", Inline: true}, nil, `inline; filename=a.png`},
+		{"inline HTML", DownloadFileInfo{FileName: "a.html", Inline: true}, nil, `attachment; filename=a.html`},
+		{"inline SVG", DownloadFileInfo{FileName: "a.svg", Inline: true}, nil, `attachment; filename=a.svg`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentDisposition(tt.fileInfo, tt.md); got != tt.want {
+				t.Errorf("contentDisposition() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsActiveContent(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/html; charset=utf-8", true},
+		{"image/svg+xml", true},
+		{"application/xhtml+xml", true},
+		{"text/javascript", true},
+		{"application/xml", true},
+		{"image/png", false},
+		{"application/pdf", false},
+		{"text/plain; charset=utf-8", false},
+		{"", false},
+		{"invalid;;", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			if got := isActiveContent(tt.contentType); got != tt.want {
+				t.Errorf("isActiveContent(%q) = %v, want %v", tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
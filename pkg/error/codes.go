@@ -0,0 +1,147 @@
+package error
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Kinds of errors that are shared by the packages, so each kind is reported to the
+// clients with the same HTTP and gRPC status. (e.g. an invalid auth token is 401 in
+// all endpoints, and clients could tell it apart from an outage)
+type Code int
+
+const (
+	// An unexpected error, like a database or network error. It's the default code.
+	Internal Code = iota
+	// The request is invalid. (e.g. a missing field)
+	InvalidArgument
+	// There's not any matched user with the credentials. (e.g. an expired session)
+	Unauthorized
+	// The user exists but isn't allowed to do the action.
+	Forbidden
+	NotFound
+	AlreadyExists
+	// The request or the file is larger than the allowed size.
+	TooLarge
+	// A service that is needed is down or overloaded. The request could be retried later.
+	Unavailable
+	// A service that is needed didn't respond in time.
+	Timeout
+	NotImplemented
+)
+
+var codeNames = map[Code]string{
+	Internal:        "internal",
+	InvalidArgument: "invalid_argument",
+	Unauthorized:    "unauthorized",
+	Forbidden:       "forbidden",
+	NotFound:        "not_found",
+	AlreadyExists:   "already_exists",
+	TooLarge:        "too_large",
+	Unavailable:     "unavailable",
+	Timeout:         "timeout",
+	NotImplemented:  "not_implemented",
+}
+
+// Codes are errors too, so errors.Is(err, NotFound) checks whether any Error in the
+// chain of err has the code.
+func (c Code) Error() string {
+	return c.String()
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return codeNames[Internal]
+}
+
+// Return the HTTP status that the code is reported with it
+func (c Code) HTTPStatus() int {
+	switch c {
+	case InvalidArgument:
+		return http.StatusBadRequest
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case NotFound:
+		return http.StatusNotFound
+	case AlreadyExists:
+		return http.StatusConflict
+	case TooLarge:
+		return http.StatusRequestEntityTooLarge
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case Timeout:
+		return http.StatusGatewayTimeout
+	case NotImplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Return the gRPC status code that the code is reported with it
+func (c Code) GRPCCode() codes.Code {
+	switch c {
+	case InvalidArgument:
+		return codes.InvalidArgument
+	case Unauthorized:
+		return codes.Unauthenticated
+	case Forbidden:
+		return codes.PermissionDenied
+	case NotFound:
+		return codes.NotFound
+	case AlreadyExists:
+		return codes.AlreadyExists
+	case TooLarge:
+		return codes.ResourceExhausted
+	case Unavailable:
+		return codes.Unavailable
+	case Timeout:
+		return codes.DeadlineExceeded
+	case NotImplemented:
+		return codes.Unimplemented
+	default:
+		return codes.Internal
+	}
+}
+
+// Return the code of a gRPC status code. ResourceExhausted is Unavailable, because
+// servers return it when they're overloaded.
+func CodeFromGRPC(code codes.Code) Code {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return InvalidArgument
+	case codes.Unauthenticated:
+		return Unauthorized
+	case codes.PermissionDenied:
+		return Forbidden
+	case codes.NotFound:
+		return NotFound
+	case codes.AlreadyExists:
+		return AlreadyExists
+	case codes.Unavailable, codes.ResourceExhausted:
+		return Unavailable
+	case codes.DeadlineExceeded:
+		return Timeout
+	case codes.Unimplemented:
+		return NotImplemented
+	default:
+		return Internal
+	}
+}
+
+// Return the code of the first Error in the chain of err. If there isn't any Error,
+// it's Internal.
+func CodeOf(err error) Code {
+	// Errors are matched by value and by pointer
+	var coded interface{ GetCode() Code }
+	if errors.As(err, &coded) {
+		return coded.GetCode()
+	}
+	return Internal
+}
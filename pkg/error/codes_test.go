@@ -0,0 +1,91 @@
+package error
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestCodeStatuses(t *testing.T) {
+	tests := []struct {
+		code     Code
+		name     string
+		http     int
+		grpc     codes.Code
+		fromGRPC Code
+	}{
+		{Internal, "internal", http.StatusInternalServerError, codes.Internal, Internal},
+		{InvalidArgument, "invalid_argument", http.StatusBadRequest, codes.InvalidArgument, InvalidArgument},
+		{Unauthorized, "unauthorized", http.StatusUnauthorized, codes.Unauthenticated, Unauthorized},
+		{Forbidden, "forbidden", http.StatusForbidden, codes.PermissionDenied, Forbidden},
+		{NotFound, "not_found", http.StatusNotFound, codes.NotFound, NotFound},
+		{AlreadyExists, "already_exists", http.StatusConflict, codes.AlreadyExists, AlreadyExists},
+		// ResourceExhausted of the servers means they're overloaded.
+		{TooLarge, "too_large", http.StatusRequestEntityTooLarge, codes.ResourceExhausted, Unavailable},
+		{Unavailable, "unavailable", http.StatusServiceUnavailable, codes.Unavailable, Unavailable},
+		{Timeout, "timeout", http.StatusGatewayTimeout, codes.DeadlineExceeded, Timeout},
+		{NotImplemented, "not_implemented", http.StatusNotImplemented, codes.Unimplemented, NotImplemented},
+		{Code(100), "internal", http.StatusInternalServerError, codes.Internal, Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.code.String(); got != tt.name {
+				t.Errorf("String() = %q, want %q", got, tt.name)
+			}
+			if got := tt.code.HTTPStatus(); got != tt.http {
+				t.Errorf("HTTPStatus() = %d, want %d", got, tt.http)
+			}
+			if got := tt.code.GRPCCode(); got != tt.grpc {
+				t.Errorf("GRPCCode() = %v, want %v", got, tt.grpc)
+			}
+			if got := CodeFromGRPC(tt.code.GRPCCode()); got != tt.fromGRPC {
+				t.Errorf("CodeFromGRPC(GRPCCode()) = %v, want %v", got, tt.fromGRPC)
+			}
+		})
+	}
+}
+
+func TestCodeFromGRPC(t *testing.T) {
+	tests := []struct {
+		grpc codes.Code
+		want Code
+	}{
+		{codes.OutOfRange, InvalidArgument},
+		{codes.FailedPrecondition, InvalidArgument},
+		{codes.Canceled, Internal},
+		{codes.Unknown, Internal},
+		{codes.DataLoss, Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.grpc.String(), func(t *testing.T) {
+			if got := CodeFromGRPC(tt.grpc); got != tt.want {
+				t.Errorf("CodeFromGRPC(%v) = %v, want %v", tt.grpc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, Internal},
+		{"plain error", fmt.Errorf("failed"), Internal},
+		{"pointer", NewErrorP("missing", NotFound), NotFound},
+		{"value", NewError("missing", NotFound), NotFound},
+		{"wrapped by fmt", fmt.Errorf("context: %w", NewErrorP("denied", Forbidden)), Forbidden},
+		{"outer code wins", Wrap(NewErrorP("missing", NotFound), "failed", Unavailable), Unavailable},
+		{"code is kept", Wrap(NewErrorP("denied", Forbidden), "failed", CodeOf(NewErrorP("denied", Forbidden))), Forbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.want {
+				t.Errorf("CodeOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
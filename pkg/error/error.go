@@ -1,66 +1,240 @@
 package error
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
 
 const SeparatorMsg = ":"
 
-type ErrorCode any
+// Maximum number of frames of the captured stacks
+const maxStackDepth = 32
 
+// Whether stacks are captured when the errors are created
+var captureStacks atomic.Bool
+
+// Capture stack of the errors that are created after it, so they show where they're
+// created. It's disabled by default, because capturing the stacks is expensive.
+func CaptureStacks(enable bool) {
+	captureStacks.Store(enable)
+}
+
+// An error with a code that shows its kind. It could wrap the error that caused it and
+// have fields that describe it, so they're logged separately instead of being
+// concatenated to the message.
 type Error struct {
 	message string
-	code    ErrorCode
+	code    Code
+	// The error that caused this error. (Optional)
+	cause error
+	// Key-value pairs that describe the error. It's copied on write, so copies of the
+	// error don't share their fields.
+	fields map[string]any
+	// Program counters of the stack that the error is created in. (Optional)
+	stack []uintptr
 }
 
-// Return all error message
+// Return all error message. Message of the cause is appended to it.
 func (e Error) Error() string {
-	return fmt.Sprintf(e.message)
+	if e.cause == nil {
+		return e.message
+	}
+	if e.message == "" {
+		return e.cause.Error()
+	}
+	return fmt.Sprintf("%s%s %s", e.message, SeparatorMsg, e.cause.Error())
 }
 
-func (e *Error) AppendEnd(msg string, args ...any) *Error {
-	if len(args) > 0 {
-		msg = fmt.Sprintf(msg, args...)
+// Return the error that caused this error, so errors.Is and errors.As check it too.
+func (e Error) Unwrap() error {
+	return e.cause
+}
+
+// The error is a Code if it has the same code, so errors.Is(err, NotFound) checks the
+// kind of the error.
+func (e Error) Is(target error) bool {
+	code, ok := target.(Code)
+	return ok && e.code == code
+}
+
+// Print the error. %+v prints its fields and stack too.
+func (e Error) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		io.WriteString(s, e.Error())
+		for _, k := range slices.Sorted(maps.Keys(e.fields)) {
+			fmt.Fprintf(s, " %s=%v", k, e.fields[k])
+		}
+		if len(e.stack) > 0 {
+			io.WriteString(s, "\n")
+			io.WriteString(s, e.StackTrace())
+		}
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		io.WriteString(s, e.Error())
 	}
-	e.message = fmt.Sprintf("%s%s %s", e.message, SeparatorMsg, msg)
+}
+
+// Add a field to the error and return it
+func (e *Error) With(key string, value any) *Error {
+	fields := maps.Clone(e.fields)
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+	fields[key] = value
+	e.fields = fields
 	return e
 }
 
-func (e *Error) AppendBegin(msg string, args ...any) *Error {
-	if len(args) > 0 {
-		msg = fmt.Sprintf(msg, args...)
+// Return fields of the error, without fields of its cause
+func (e Error) Fields() map[string]any {
+	return maps.Clone(e.fields)
+}
+
+// Return the stack that the error is created in, one frame per line. It's empty if
+// stacks weren't captured.
+func (e Error) StackTrace() string {
+	if len(e.stack) == 0 {
+		return ""
+	}
+	var trace strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&trace, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
 	}
-	e.message = fmt.Sprintf("%s%s %s", msg, SeparatorMsg, e.message)
+	return strings.TrimSuffix(trace.String(), "\n")
+}
+
+// Add the message to end of the message of the error.
+//
+// Deprecated: add the details by With, or wrap the error by Wrap.
+func (e *Error) AppendEnd(msg string, args ...any) *Error {
+	e.message = fmt.Sprintf("%s%s %s", e.message, SeparatorMsg, format(msg, args))
 	return e
 }
 
-func (e *Error) SetCode(code ErrorCode) *Error {
+// Add the message to beginning of the message of the error.
+//
+// Deprecated: wrap the error by Wrap, so the original error could be checked by errors.Is.
+func (e *Error) AppendBegin(msg string, args ...any) *Error {
+	e.message = fmt.Sprintf("%s%s %s", format(msg, args), SeparatorMsg, e.message)
+	return e
+}
+
+func (e *Error) SetCode(code Code) *Error {
 	e.code = code
 	return e
 }
 
-func (e *Error) GetCode() ErrorCode {
+func (e Error) GetCode() Code {
 	return e.code
 }
 
-func NewError(msg string, code ErrorCode, args ...any) Error {
+// Create an error. The message is formatted by the args like fmt.Sprintf.
+func NewError(msg string, code Code, args ...any) Error {
 	return Error{
-		message: fmt.Sprintf(msg, args...),
+		message: format(msg, args),
 		code:    code,
+		stack:   callers(),
 	}
 }
 
 // Create an error and return pointer to that
-func NewErrorP(msg string, code ErrorCode, args ...any) *Error {
+func NewErrorP(msg string, code Code, args ...any) *Error {
 	return &Error{
-		message: fmt.Sprintf(msg, args...),
+		message: format(msg, args),
 		code:    code,
+		stack:   callers(),
 	}
 }
 
-func NewErrorFmt(msg string, code ErrorCode, args ...any) Error {
+// Same as NewError.
+//
+// Deprecated: use NewError.
+func NewErrorFmt(msg string, code Code, args ...any) Error {
 	return Error{
-		message: fmt.Sprintf(msg, args),
+		message: format(msg, args),
+		code:    code,
+		stack:   callers(),
+	}
+}
+
+// Create an error that is caused by the cause. Its message is the message and then the
+// message of the cause. To keep code of the cause, pass CodeOf(cause).
+func Wrap(cause error, msg string, code Code, args ...any) *Error {
+	return &Error{
+		message: format(msg, args),
 		code:    code,
+		cause:   cause,
+		stack:   callers(),
+	}
+}
+
+// Return fields of all errors in the chain of err, to log them by WithFields of the
+// logger. Code of the error and its stack (if it's captured) are added too. Fields of
+// the outer errors win over the same fields of their causes.
+func LogFields(err error) map[string]any {
+	fields := make(map[string]any)
+	if err == nil {
+		return fields
+	}
+	var stack string
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		var current Error
+		switch c := cause.(type) {
+		case *Error:
+			current = *c
+		case Error:
+			current = c
+		default:
+			continue
+		}
+		for k, v := range current.fields {
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+		// The innermost stack shows where the error happened.
+		if s := current.StackTrace(); s != "" {
+			stack = s
+		}
+	}
+	fields["error-code"] = CodeOf(err).String()
+	if stack != "" {
+		fields["stack"] = stack
+	}
+	return fields
+}
+
+// Format the message just if there's any args, so messages that contain "%" are kept.
+func format(msg string, args []any) string {
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Return the stack of the caller of the function that creates the error, if stacks are
+// captured.
+func callers() []uintptr {
+	if !captureStacks.Load() {
+		return nil
 	}
+	pcs := make([]uintptr, maxStackDepth)
+	// Skip runtime.Callers, callers and the function that creates the error
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
 }
 
 // A simple error implementation
@@ -0,0 +1,116 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"formatted", NewErrorP("file %s of %d", NotFound, "a.pdf", 2), "file a.pdf of 2"},
+		{"percent without args", NewErrorP("100% done", Internal), "100% done"},
+		{"wrapped", Wrap(io.EOF, "Failed to read %s", Internal, "a.pdf"), "Failed to read a.pdf: EOF"},
+		{"wrapped without message", Wrap(io.EOF, "", Internal), "EOF"},
+		{"appended", NewErrorP("b", Internal).AppendBegin("a").AppendEnd("c"), "a: b: c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	missing := NewErrorP("file not found", NotFound)
+	wrapped := Wrap(fmt.Errorf("context: %w", missing), "Failed to get the file", Internal)
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"own code", missing, NotFound, true},
+		{"other code", missing, Forbidden, false},
+		{"code of the wrapper", wrapped, Internal, true},
+		{"code of the cause", wrapped, NotFound, true},
+		{"cause", wrapped, missing, true},
+		{"value error", NewError("denied", Forbidden), Forbidden, true},
+		{"plain cause", Wrap(io.EOF, "failed", Internal), io.EOF, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorWithCopiesFields(t *testing.T) {
+	original := NewErrorP("failed", Internal).With("key", "a.pdf")
+	copied := *original
+	copied.With("key", "b.pdf").With("size", 10)
+	if got := original.Fields(); len(got) != 1 || got["key"] != "a.pdf" {
+		t.Errorf("fields of the original error = %v, want just key=a.pdf", got)
+	}
+	if got := copied.Fields(); got["key"] != "b.pdf" || got["size"] != 10 {
+		t.Errorf("fields of the copy = %v", got)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	cause := NewErrorP("file not found", NotFound).With("key", "a.pdf").With("bucket", "files")
+	err := Wrap(fmt.Errorf("context: %w", cause), "Failed to download", CodeOf(cause)).With("key", "outer.pdf")
+
+	tests := []struct {
+		name string
+		err  error
+		want map[string]any
+	}{
+		{"nil", nil, map[string]any{}},
+		{"plain error", io.EOF, map[string]any{"error-code": "internal"}},
+		{"chain", err, map[string]any{"key": "outer.pdf", "bucket": "files", "error-code": "not_found"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LogFields(tt.err)
+			if len(got) != len(tt.want) {
+				t.Fatalf("LogFields() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("LogFields()[%q] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestCaptureStacks(t *testing.T) {
+	CaptureStacks(true)
+	defer CaptureStacks(false)
+	err := NewErrorP("failed", Internal)
+	if trace := err.StackTrace(); !strings.Contains(trace, "TestCaptureStacks") {
+		t.Errorf("StackTrace() = %q, want it to contain the test function", trace)
+	}
+	if _, ok := LogFields(err)["stack"]; !ok {
+		t.Error("LogFields() doesn't have the stack")
+	}
+	if !strings.Contains(fmt.Sprintf("%+v", err), "TestCaptureStacks") {
+		t.Errorf("formatting by %s doesn't print the stack", "%+v")
+	}
+
+	CaptureStacks(false)
+	if trace := NewErrorP("failed", Internal).StackTrace(); trace != "" {
+		t.Errorf("StackTrace() = %q, want it empty when stacks aren't captured", trace)
+	}
+}
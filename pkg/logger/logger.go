@@ -3,7 +3,9 @@ package logger
 import (
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"slices"
 	"sync"
 )
 
@@ -108,7 +110,7 @@ type SLogger struct {
 func NewSLogger(minLogLevel LogLevel, fields map[string]any, writer io.Writer) Logger {
 	return &SLogger{
 		minLevel: minLogLevel,
-		fields:   make(map[string]any),
+		fields:   maps.Clone(fields),
 		writer:   writer,
 	}
 }
@@ -197,7 +199,7 @@ func (l *SLogger) WithFields(fields map[string]any) Logger {
 	for k, v := range fields {
 		newFields[k] = v
 	}
-	return &SLogger{minLevel: l.minLevel, fields: newFields}
+	return &SLogger{minLevel: l.minLevel, fields: newFields, writer: l.writer}
 }
 
 func (l *SLogger) log(level string, args ...any) {
@@ -223,8 +225,9 @@ func (l *SLogger) printLog(level, message string) {
 		fmt.Fprintf(l.writer, "[%s] %s", level, message)
 	}
 
-	for k, v := range l.fields {
-		fmt.Fprintf(l.writer, " %s=%v", k, v)
+	// Fields are sorted, so the logs are the same for the same fields.
+	for _, k := range slices.Sorted(maps.Keys(l.fields)) {
+		fmt.Fprintf(l.writer, " %s=%v", k, l.fields[k])
 	}
 	fmt.Fprintln(l.writer)
 }
@@ -181,6 +181,207 @@ func (x *UploadAccessReq) GetObjectTypes() map[string]int64 {
 	return nil
 }
 
+type DeleteAccessReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AuthToken     string                 `protobuf:"bytes,1,opt,name=AuthToken,proto3" json:"AuthToken,omitempty"`
+	ObjectTokens  []string               `protobuf:"bytes,2,rep,name=ObjectTokens,proto3" json:"ObjectTokens,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteAccessReq) Reset() {
+	*x = DeleteAccessReq{}
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteAccessReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteAccessReq) ProtoMessage() {}
+
+func (x *DeleteAccessReq) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteAccessReq.ProtoReflect.Descriptor instead.
+func (*DeleteAccessReq) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DeleteAccessReq) GetAuthToken() string {
+	if x != nil {
+		return x.AuthToken
+	}
+	return ""
+}
+
+func (x *DeleteAccessReq) GetObjectTokens() []string {
+	if x != nil {
+		return x.ObjectTokens
+	}
+	return nil
+}
+
+// Copying or moving a file to another token
+type ObjectTransfer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=Source,proto3" json:"Source,omitempty"`
+	Destination   string                 `protobuf:"bytes,2,opt,name=Destination,proto3" json:"Destination,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ObjectTransfer) Reset() {
+	*x = ObjectTransfer{}
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ObjectTransfer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ObjectTransfer) ProtoMessage() {}
+
+func (x *ObjectTransfer) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ObjectTransfer.ProtoReflect.Descriptor instead.
+func (*ObjectTransfer) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ObjectTransfer) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *ObjectTransfer) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+type TransferAccessReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AuthToken     string                 `protobuf:"bytes,1,opt,name=AuthToken,proto3" json:"AuthToken,omitempty"`
+	Objects       []*ObjectTransfer      `protobuf:"bytes,2,rep,name=Objects,proto3" json:"Objects,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferAccessReq) Reset() {
+	*x = TransferAccessReq{}
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferAccessReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferAccessReq) ProtoMessage() {}
+
+func (x *TransferAccessReq) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferAccessReq.ProtoReflect.Descriptor instead.
+func (*TransferAccessReq) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TransferAccessReq) GetAuthToken() string {
+	if x != nil {
+		return x.AuthToken
+	}
+	return ""
+}
+
+func (x *TransferAccessReq) GetObjects() []*ObjectTransfer {
+	if x != nil {
+		return x.Objects
+	}
+	return nil
+}
+
+type IdentifyReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AuthToken     string                 `protobuf:"bytes,1,opt,name=AuthToken,proto3" json:"AuthToken,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IdentifyReq) Reset() {
+	*x = IdentifyReq{}
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IdentifyReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IdentifyReq) ProtoMessage() {}
+
+func (x *IdentifyReq) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IdentifyReq.ProtoReflect.Descriptor instead.
+func (*IdentifyReq) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *IdentifyReq) GetAuthToken() string {
+	if x != nil {
+		return x.AuthToken
+	}
+	return ""
+}
+
 type AcceptableType struct {
 	state    protoimpl.MessageState `protogen:"open.v1"`
 	FileType string                 `protobuf:"bytes,1,opt,name=FileType,proto3" json:"FileType,omitempty"`
@@ -193,7 +394,7 @@ type AcceptableType struct {
 
 func (x *AcceptableType) Reset() {
 	*x = AcceptableType{}
-	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[2]
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -205,7 +406,7 @@ func (x *AcceptableType) String() string {
 func (*AcceptableType) ProtoMessage() {}
 
 func (x *AcceptableType) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[2]
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -218,7 +419,7 @@ func (x *AcceptableType) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AcceptableType.ProtoReflect.Descriptor instead.
 func (*AcceptableType) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{2}
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *AcceptableType) GetFileType() string {
@@ -253,7 +454,7 @@ type AllowDownloadResult struct {
 
 func (x *AllowDownloadResult) Reset() {
 	*x = AllowDownloadResult{}
-	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[3]
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -265,7 +466,7 @@ func (x *AllowDownloadResult) String() string {
 func (*AllowDownloadResult) ProtoMessage() {}
 
 func (x *AllowDownloadResult) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[3]
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -278,7 +479,7 @@ func (x *AllowDownloadResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AllowDownloadResult.ProtoReflect.Descriptor instead.
 func (*AllowDownloadResult) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{3}
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *AllowDownloadResult) GetStatusCode() StatusCode {
@@ -313,7 +514,7 @@ type AllowUploadResult struct {
 
 func (x *AllowUploadResult) Reset() {
 	*x = AllowUploadResult{}
-	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[4]
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -325,7 +526,7 @@ func (x *AllowUploadResult) String() string {
 func (*AllowUploadResult) ProtoMessage() {}
 
 func (x *AllowUploadResult) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[4]
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -338,7 +539,7 @@ func (x *AllowUploadResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AllowUploadResult.ProtoReflect.Descriptor instead.
 func (*AllowUploadResult) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{4}
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *AllowUploadResult) GetStatusCode() StatusCode {
@@ -362,6 +563,188 @@ func (x *AllowUploadResult) GetFileTypes() []*AcceptableType {
 	return nil
 }
 
+type AllowDeleteResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StatusCode    StatusCode             `protobuf:"varint,1,opt,name=StatusCode,proto3,enum=auth.StatusCode" json:"StatusCode,omitempty"`
+	Errmsg        string                 `protobuf:"bytes,2,opt,name=Errmsg,proto3" json:"Errmsg,omitempty"`
+	Files         map[string]bool        `protobuf:"bytes,3,rep,name=Files,proto3" json:"Files,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllowDeleteResult) Reset() {
+	*x = AllowDeleteResult{}
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllowDeleteResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllowDeleteResult) ProtoMessage() {}
+
+func (x *AllowDeleteResult) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllowDeleteResult.ProtoReflect.Descriptor instead.
+func (*AllowDeleteResult) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *AllowDeleteResult) GetStatusCode() StatusCode {
+	if x != nil {
+		return x.StatusCode
+	}
+	return StatusCode_ErrInternal
+}
+
+func (x *AllowDeleteResult) GetErrmsg() string {
+	if x != nil {
+		return x.Errmsg
+	}
+	return ""
+}
+
+func (x *AllowDeleteResult) GetFiles() map[string]bool {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type AllowTransferResult struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	StatusCode StatusCode             `protobuf:"varint,1,opt,name=StatusCode,proto3,enum=auth.StatusCode" json:"StatusCode,omitempty"`
+	Errmsg     string                 `protobuf:"bytes,2,opt,name=Errmsg,proto3" json:"Errmsg,omitempty"`
+	// Whether each transfer is allowed, in the same order as the request
+	Allowed       []bool `protobuf:"varint,3,rep,packed,name=Allowed,proto3" json:"Allowed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllowTransferResult) Reset() {
+	*x = AllowTransferResult{}
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllowTransferResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllowTransferResult) ProtoMessage() {}
+
+func (x *AllowTransferResult) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllowTransferResult.ProtoReflect.Descriptor instead.
+func (*AllowTransferResult) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AllowTransferResult) GetStatusCode() StatusCode {
+	if x != nil {
+		return x.StatusCode
+	}
+	return StatusCode_ErrInternal
+}
+
+func (x *AllowTransferResult) GetErrmsg() string {
+	if x != nil {
+		return x.Errmsg
+	}
+	return ""
+}
+
+func (x *AllowTransferResult) GetAllowed() []bool {
+	if x != nil {
+		return x.Allowed
+	}
+	return nil
+}
+
+type IdentifyResult struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	StatusCode StatusCode             `protobuf:"varint,1,opt,name=StatusCode,proto3,enum=auth.StatusCode" json:"StatusCode,omitempty"`
+	Errmsg     string                 `protobuf:"bytes,2,opt,name=Errmsg,proto3" json:"Errmsg,omitempty"`
+	// A stable ID of the user. It couldn't contain any slash or backslash.
+	UserID        string `protobuf:"bytes,3,opt,name=UserID,proto3" json:"UserID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IdentifyResult) Reset() {
+	*x = IdentifyResult{}
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IdentifyResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IdentifyResult) ProtoMessage() {}
+
+func (x *IdentifyResult) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_auth_auth_service_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IdentifyResult.ProtoReflect.Descriptor instead.
+func (*IdentifyResult) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_auth_auth_service_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *IdentifyResult) GetStatusCode() StatusCode {
+	if x != nil {
+		return x.StatusCode
+	}
+	return StatusCode_ErrInternal
+}
+
+func (x *IdentifyResult) GetErrmsg() string {
+	if x != nil {
+		return x.Errmsg
+	}
+	return ""
+}
+
+func (x *IdentifyResult) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
 var File_pkg_pb_auth_auth_service_proto protoreflect.FileDescriptor
 
 const file_pkg_pb_auth_auth_service_proto_rawDesc = "" +
@@ -375,7 +758,18 @@ const file_pkg_pb_auth_auth_service_proto_rawDesc = "" +
 	"\vObjectTypes\x18\x02 \x03(\v2&.auth.UploadAccessReq.ObjectTypesEntryR\vObjectTypes\x1a>\n" +
 	"\x10ObjectTypesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"`\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"S\n" +
+	"\x0fDeleteAccessReq\x12\x1c\n" +
+	"\tAuthToken\x18\x01 \x01(\tR\tAuthToken\x12\"\n" +
+	"\fObjectTokens\x18\x02 \x03(\tR\fObjectTokens\"J\n" +
+	"\x0eObjectTransfer\x12\x16\n" +
+	"\x06Source\x18\x01 \x01(\tR\x06Source\x12 \n" +
+	"\vDestination\x18\x02 \x01(\tR\vDestination\"a\n" +
+	"\x11TransferAccessReq\x12\x1c\n" +
+	"\tAuthToken\x18\x01 \x01(\tR\tAuthToken\x12.\n" +
+	"\aObjects\x18\x02 \x03(\v2\x14.auth.ObjectTransferR\aObjects\"+\n" +
+	"\vIdentifyReq\x12\x1c\n" +
+	"\tAuthToken\x18\x01 \x01(\tR\tAuthToken\"`\n" +
 	"\x0eAcceptableType\x12\x1a\n" +
 	"\bFileType\x18\x01 \x01(\tR\bFileType\x12\x18\n" +
 	"\aIsAllow\x18\x02 \x01(\bR\aIsAllow\x12\x18\n" +
@@ -395,16 +789,42 @@ const file_pkg_pb_auth_auth_service_proto_rawDesc = "" +
 	"StatusCode\x18\x01 \x01(\x0e2\x10.auth.statusCodeR\n" +
 	"StatusCode\x12\x16\n" +
 	"\x06Errmsg\x18\x02 \x01(\tR\x06Errmsg\x122\n" +
-	"\tFileTypes\x18\x03 \x03(\v2\x14.auth.AcceptableTypeR\tFileTypes*L\n" +
+	"\tFileTypes\x18\x03 \x03(\v2\x14.auth.AcceptableTypeR\tFileTypes\"\xd1\x01\n" +
+	"\x11AllowDeleteResult\x120\n" +
+	"\n" +
+	"StatusCode\x18\x01 \x01(\x0e2\x10.auth.statusCodeR\n" +
+	"StatusCode\x12\x16\n" +
+	"\x06Errmsg\x18\x02 \x01(\tR\x06Errmsg\x128\n" +
+	"\x05Files\x18\x03 \x03(\v2\".auth.AllowDeleteResult.FilesEntryR\x05Files\x1a8\n" +
+	"\n" +
+	"FilesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\bR\x05value:\x028\x01\"y\n" +
+	"\x13AllowTransferResult\x120\n" +
+	"\n" +
+	"StatusCode\x18\x01 \x01(\x0e2\x10.auth.statusCodeR\n" +
+	"StatusCode\x12\x16\n" +
+	"\x06Errmsg\x18\x02 \x01(\tR\x06Errmsg\x12\x18\n" +
+	"\aAllowed\x18\x03 \x03(\bR\aAllowed\"r\n" +
+	"\x0eIdentifyResult\x120\n" +
+	"\n" +
+	"StatusCode\x18\x01 \x01(\x0e2\x10.auth.statusCodeR\n" +
+	"StatusCode\x12\x16\n" +
+	"\x06Errmsg\x18\x02 \x01(\tR\x06Errmsg\x12\x16\n" +
+	"\x06UserID\x18\x03 \x01(\tR\x06UserID*L\n" +
 	"\n" +
 	"statusCode\x12\x0f\n" +
 	"\vErrInternal\x10\x00\x12\x06\n" +
 	"\x02OK\x10\x01\x12\x13\n" +
 	"\x0fErrUnauthorized\x10\x02\x12\x10\n" +
-	"\fErrForbidden\x10\x032\x96\x01\n" +
+	"\fErrForbidden\x10\x032\xa0\x03\n" +
 	"\x04Auth\x12I\n" +
 	"\x11IsAllowedDownload\x12\x17.auth.DownloadAccessReq\x1a\x19.auth.AllowDownloadResult\"\x00\x12C\n" +
-	"\x0fIsAllowedUpload\x12\x15.auth.UploadAccessReq\x1a\x17.auth.AllowUploadResult\"\x00B4Z2github.com/q-sharafian/file-transfer/internal/authb\x06proto3"
+	"\x0fIsAllowedUpload\x12\x15.auth.UploadAccessReq\x1a\x17.auth.AllowUploadResult\"\x00\x12C\n" +
+	"\x0fIsAllowedDelete\x12\x15.auth.DeleteAccessReq\x1a\x17.auth.AllowDeleteResult\"\x00\x12E\n" +
+	"\rIsAllowedCopy\x12\x17.auth.TransferAccessReq\x1a\x19.auth.AllowTransferResult\"\x00\x12E\n" +
+	"\rIsAllowedMove\x12\x17.auth.TransferAccessReq\x1a\x19.auth.AllowTransferResult\"\x00\x125\n" +
+	"\bIdentify\x12\x11.auth.IdentifyReq\x1a\x14.auth.IdentifyResult\"\x00B4Z2github.com/q-sharafian/file-transfer/internal/authb\x06proto3"
 
 var (
 	file_pkg_pb_auth_auth_service_proto_rawDescOnce sync.Once
@@ -419,32 +839,53 @@ func file_pkg_pb_auth_auth_service_proto_rawDescGZIP() []byte {
 }
 
 var file_pkg_pb_auth_auth_service_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_pkg_pb_auth_auth_service_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_pkg_pb_auth_auth_service_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_pkg_pb_auth_auth_service_proto_goTypes = []any{
 	(StatusCode)(0),             // 0: auth.statusCode
 	(*DownloadAccessReq)(nil),   // 1: auth.DownloadAccessReq
 	(*UploadAccessReq)(nil),     // 2: auth.UploadAccessReq
-	(*AcceptableType)(nil),      // 3: auth.AcceptableType
-	(*AllowDownloadResult)(nil), // 4: auth.AllowDownloadResult
-	(*AllowUploadResult)(nil),   // 5: auth.AllowUploadResult
-	nil,                         // 6: auth.UploadAccessReq.ObjectTypesEntry
-	nil,                         // 7: auth.AllowDownloadResult.FilesEntry
+	(*DeleteAccessReq)(nil),     // 3: auth.DeleteAccessReq
+	(*ObjectTransfer)(nil),      // 4: auth.ObjectTransfer
+	(*TransferAccessReq)(nil),   // 5: auth.TransferAccessReq
+	(*IdentifyReq)(nil),         // 6: auth.IdentifyReq
+	(*AcceptableType)(nil),      // 7: auth.AcceptableType
+	(*AllowDownloadResult)(nil), // 8: auth.AllowDownloadResult
+	(*AllowUploadResult)(nil),   // 9: auth.AllowUploadResult
+	(*AllowDeleteResult)(nil),   // 10: auth.AllowDeleteResult
+	(*AllowTransferResult)(nil), // 11: auth.AllowTransferResult
+	(*IdentifyResult)(nil),      // 12: auth.IdentifyResult
+	nil,                         // 13: auth.UploadAccessReq.ObjectTypesEntry
+	nil,                         // 14: auth.AllowDownloadResult.FilesEntry
+	nil,                         // 15: auth.AllowDeleteResult.FilesEntry
 }
 var file_pkg_pb_auth_auth_service_proto_depIdxs = []int32{
-	6, // 0: auth.UploadAccessReq.ObjectTypes:type_name -> auth.UploadAccessReq.ObjectTypesEntry
-	0, // 1: auth.AllowDownloadResult.StatusCode:type_name -> auth.statusCode
-	7, // 2: auth.AllowDownloadResult.Files:type_name -> auth.AllowDownloadResult.FilesEntry
-	0, // 3: auth.AllowUploadResult.StatusCode:type_name -> auth.statusCode
-	3, // 4: auth.AllowUploadResult.FileTypes:type_name -> auth.AcceptableType
-	1, // 5: auth.Auth.IsAllowedDownload:input_type -> auth.DownloadAccessReq
-	2, // 6: auth.Auth.IsAllowedUpload:input_type -> auth.UploadAccessReq
-	4, // 7: auth.Auth.IsAllowedDownload:output_type -> auth.AllowDownloadResult
-	5, // 8: auth.Auth.IsAllowedUpload:output_type -> auth.AllowUploadResult
-	7, // [7:9] is the sub-list for method output_type
-	5, // [5:7] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	13, // 0: auth.UploadAccessReq.ObjectTypes:type_name -> auth.UploadAccessReq.ObjectTypesEntry
+	4,  // 1: auth.TransferAccessReq.Objects:type_name -> auth.ObjectTransfer
+	0,  // 2: auth.AllowDownloadResult.StatusCode:type_name -> auth.statusCode
+	14, // 3: auth.AllowDownloadResult.Files:type_name -> auth.AllowDownloadResult.FilesEntry
+	0,  // 4: auth.AllowUploadResult.StatusCode:type_name -> auth.statusCode
+	7,  // 5: auth.AllowUploadResult.FileTypes:type_name -> auth.AcceptableType
+	0,  // 6: auth.AllowDeleteResult.StatusCode:type_name -> auth.statusCode
+	15, // 7: auth.AllowDeleteResult.Files:type_name -> auth.AllowDeleteResult.FilesEntry
+	0,  // 8: auth.AllowTransferResult.StatusCode:type_name -> auth.statusCode
+	0,  // 9: auth.IdentifyResult.StatusCode:type_name -> auth.statusCode
+	1,  // 10: auth.Auth.IsAllowedDownload:input_type -> auth.DownloadAccessReq
+	2,  // 11: auth.Auth.IsAllowedUpload:input_type -> auth.UploadAccessReq
+	3,  // 12: auth.Auth.IsAllowedDelete:input_type -> auth.DeleteAccessReq
+	5,  // 13: auth.Auth.IsAllowedCopy:input_type -> auth.TransferAccessReq
+	5,  // 14: auth.Auth.IsAllowedMove:input_type -> auth.TransferAccessReq
+	6,  // 15: auth.Auth.Identify:input_type -> auth.IdentifyReq
+	8,  // 16: auth.Auth.IsAllowedDownload:output_type -> auth.AllowDownloadResult
+	9,  // 17: auth.Auth.IsAllowedUpload:output_type -> auth.AllowUploadResult
+	10, // 18: auth.Auth.IsAllowedDelete:output_type -> auth.AllowDeleteResult
+	11, // 19: auth.Auth.IsAllowedCopy:output_type -> auth.AllowTransferResult
+	11, // 20: auth.Auth.IsAllowedMove:output_type -> auth.AllowTransferResult
+	12, // 21: auth.Auth.Identify:output_type -> auth.IdentifyResult
+	16, // [16:22] is the sub-list for method output_type
+	10, // [10:16] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_pkg_pb_auth_auth_service_proto_init() }
@@ -458,7 +899,7 @@ func file_pkg_pb_auth_auth_service_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_pb_auth_auth_service_proto_rawDesc), len(file_pkg_pb_auth_auth_service_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   7,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
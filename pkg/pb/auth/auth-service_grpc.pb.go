@@ -21,6 +21,10 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	Auth_IsAllowedDownload_FullMethodName = "/auth.Auth/IsAllowedDownload"
 	Auth_IsAllowedUpload_FullMethodName   = "/auth.Auth/IsAllowedUpload"
+	Auth_IsAllowedDelete_FullMethodName   = "/auth.Auth/IsAllowedDelete"
+	Auth_IsAllowedCopy_FullMethodName     = "/auth.Auth/IsAllowedCopy"
+	Auth_IsAllowedMove_FullMethodName     = "/auth.Auth/IsAllowedMove"
+	Auth_Identify_FullMethodName          = "/auth.Auth/Identify"
 )
 
 // AuthClient is the client API for Auth service.
@@ -31,6 +35,11 @@ const (
 type AuthClient interface {
 	IsAllowedDownload(ctx context.Context, in *DownloadAccessReq, opts ...grpc.CallOption) (*AllowDownloadResult, error)
 	IsAllowedUpload(ctx context.Context, in *UploadAccessReq, opts ...grpc.CallOption) (*AllowUploadResult, error)
+	IsAllowedDelete(ctx context.Context, in *DeleteAccessReq, opts ...grpc.CallOption) (*AllowDeleteResult, error)
+	IsAllowedCopy(ctx context.Context, in *TransferAccessReq, opts ...grpc.CallOption) (*AllowTransferResult, error)
+	IsAllowedMove(ctx context.Context, in *TransferAccessReq, opts ...grpc.CallOption) (*AllowTransferResult, error)
+	// Return ID of the user that owns the auth token. Files of each user are stored under its ID.
+	Identify(ctx context.Context, in *IdentifyReq, opts ...grpc.CallOption) (*IdentifyResult, error)
 }
 
 type authClient struct {
@@ -61,6 +70,46 @@ func (c *authClient) IsAllowedUpload(ctx context.Context, in *UploadAccessReq, o
 	return out, nil
 }
 
+func (c *authClient) IsAllowedDelete(ctx context.Context, in *DeleteAccessReq, opts ...grpc.CallOption) (*AllowDeleteResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllowDeleteResult)
+	err := c.cc.Invoke(ctx, Auth_IsAllowedDelete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) IsAllowedCopy(ctx context.Context, in *TransferAccessReq, opts ...grpc.CallOption) (*AllowTransferResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllowTransferResult)
+	err := c.cc.Invoke(ctx, Auth_IsAllowedCopy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) IsAllowedMove(ctx context.Context, in *TransferAccessReq, opts ...grpc.CallOption) (*AllowTransferResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllowTransferResult)
+	err := c.cc.Invoke(ctx, Auth_IsAllowedMove_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) Identify(ctx context.Context, in *IdentifyReq, opts ...grpc.CallOption) (*IdentifyResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IdentifyResult)
+	err := c.cc.Invoke(ctx, Auth_Identify_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServer is the server API for Auth service.
 // All implementations must embed UnimplementedAuthServer
 // for forward compatibility.
@@ -69,6 +118,11 @@ func (c *authClient) IsAllowedUpload(ctx context.Context, in *UploadAccessReq, o
 type AuthServer interface {
 	IsAllowedDownload(context.Context, *DownloadAccessReq) (*AllowDownloadResult, error)
 	IsAllowedUpload(context.Context, *UploadAccessReq) (*AllowUploadResult, error)
+	IsAllowedDelete(context.Context, *DeleteAccessReq) (*AllowDeleteResult, error)
+	IsAllowedCopy(context.Context, *TransferAccessReq) (*AllowTransferResult, error)
+	IsAllowedMove(context.Context, *TransferAccessReq) (*AllowTransferResult, error)
+	// Return ID of the user that owns the auth token. Files of each user are stored under its ID.
+	Identify(context.Context, *IdentifyReq) (*IdentifyResult, error)
 	mustEmbedUnimplementedAuthServer()
 }
 
@@ -85,6 +139,18 @@ func (UnimplementedAuthServer) IsAllowedDownload(context.Context, *DownloadAcces
 func (UnimplementedAuthServer) IsAllowedUpload(context.Context, *UploadAccessReq) (*AllowUploadResult, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method IsAllowedUpload not implemented")
 }
+func (UnimplementedAuthServer) IsAllowedDelete(context.Context, *DeleteAccessReq) (*AllowDeleteResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IsAllowedDelete not implemented")
+}
+func (UnimplementedAuthServer) IsAllowedCopy(context.Context, *TransferAccessReq) (*AllowTransferResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IsAllowedCopy not implemented")
+}
+func (UnimplementedAuthServer) IsAllowedMove(context.Context, *TransferAccessReq) (*AllowTransferResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IsAllowedMove not implemented")
+}
+func (UnimplementedAuthServer) Identify(context.Context, *IdentifyReq) (*IdentifyResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Identify not implemented")
+}
 func (UnimplementedAuthServer) mustEmbedUnimplementedAuthServer() {}
 func (UnimplementedAuthServer) testEmbeddedByValue()              {}
 
@@ -142,6 +208,78 @@ func _Auth_IsAllowedUpload_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Auth_IsAllowedDelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAccessReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).IsAllowedDelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_IsAllowedDelete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).IsAllowedDelete(ctx, req.(*DeleteAccessReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_IsAllowedCopy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferAccessReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).IsAllowedCopy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_IsAllowedCopy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).IsAllowedCopy(ctx, req.(*TransferAccessReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_IsAllowedMove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferAccessReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).IsAllowedMove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_IsAllowedMove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).IsAllowedMove(ctx, req.(*TransferAccessReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_Identify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdentifyReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Identify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_Identify_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Identify(ctx, req.(*IdentifyReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Auth_ServiceDesc is the grpc.ServiceDesc for Auth service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -157,6 +295,22 @@ var Auth_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "IsAllowedUpload",
 			Handler:    _Auth_IsAllowedUpload_Handler,
 		},
+		{
+			MethodName: "IsAllowedDelete",
+			Handler:    _Auth_IsAllowedDelete_Handler,
+		},
+		{
+			MethodName: "IsAllowedCopy",
+			Handler:    _Auth_IsAllowedCopy_Handler,
+		},
+		{
+			MethodName: "IsAllowedMove",
+			Handler:    _Auth_IsAllowedMove_Handler,
+		},
+		{
+			MethodName: "Identify",
+			Handler:    _Auth_Identify_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/pb/auth/auth-service.proto",